@@ -0,0 +1,280 @@
+// Package blp decodes the BLP1 and BLP2 textures used by Warcraft III, only the first mipmap is read
+package blp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	blp1HeaderSize = 156
+	blp2HeaderSize = 148
+	paletteSize    = 1024
+
+	blp1Jpeg    = 0
+	blp1Palette = 1
+
+	blp2Jpeg         = 0
+	blp2Palette      = 1
+	blp2Dxt          = 2
+	blp2Uncompressed = 3
+
+	// Even a JPEG of a single color takes about a byte for every 8x8 block, anything that claims far more
+	// pixels than that is not decoded
+	maxJpegPixelsPerByte = 1024
+)
+
+var ErrNotBlp = fmt.Errorf("not a blp texture")
+
+// header holds what BLP1 and BLP2 have in common after the version specific fields have been sorted out
+type header struct {
+	jpeg          bool
+	encoding      uint8
+	alphaBits     uint32
+	width         uint32
+	height        uint32
+	offset        uint32
+	size          uint32
+	paletteOffset int
+}
+
+func init() {
+	image.RegisterFormat("blp", "BLP1", Decode, DecodeConfig)
+	image.RegisterFormat("blp", "BLP2", Decode, DecodeConfig)
+}
+
+// DecodeConfig returns the dimensions of a BLP texture without decoding it
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	data := make([]byte, blp1HeaderSize)
+	n, err := io.ReadFull(r, data)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return image.Config{}, err
+	}
+
+	h, err := readHeader(data[:n])
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	return image.Config{ColorModel: color.NRGBAModel, Width: int(h.width), Height: int(h.height)}, nil
+}
+
+// Decode reads a BLP texture and returns its largest mipmap
+func Decode(r io.Reader) (image.Image, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := readHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.width == 0 || h.height == 0 || h.width > 65535 || h.height > 65535 {
+		return nil, fmt.Errorf("blp texture has invalid dimensions %dx%d", h.width, h.height)
+	}
+
+	if uint64(h.offset)+uint64(h.size) > uint64(len(data)) {
+		return nil, fmt.Errorf("blp mipmap runs past the end of the file")
+	}
+
+	mipmap := data[h.offset : h.offset+h.size]
+	if h.jpeg {
+		return decodeJpeg(data, h, mipmap)
+	}
+
+	switch h.encoding {
+	case blp2Palette:
+		return decodePalette(data, h, mipmap)
+	case blp2Uncompressed:
+		return decodeUncompressed(h, mipmap)
+	}
+
+	return nil, fmt.Errorf("unsupported blp encoding %d", h.encoding)
+}
+
+func readHeader(data []byte) (*header, error) {
+	if len(data) < 4 {
+		return nil, ErrNotBlp
+	}
+
+	h := &header{}
+	switch string(data[:4]) {
+	case "BLP1":
+		if len(data) < blp1HeaderSize {
+			return nil, fmt.Errorf("blp header is truncated")
+		}
+
+		compression := binary.LittleEndian.Uint32(data[4:])
+		h.jpeg = compression == blp1Jpeg
+		h.encoding = blp2Palette
+		if compression != blp1Jpeg && compression != blp1Palette {
+			return nil, fmt.Errorf("unsupported blp compression %d", compression)
+		}
+
+		h.alphaBits = binary.LittleEndian.Uint32(data[8:])
+		h.width = binary.LittleEndian.Uint32(data[12:])
+		h.height = binary.LittleEndian.Uint32(data[16:])
+		h.offset = binary.LittleEndian.Uint32(data[28:])
+		h.size = binary.LittleEndian.Uint32(data[92:])
+		h.paletteOffset = blp1HeaderSize
+	case "BLP2":
+		if len(data) < blp2HeaderSize {
+			return nil, fmt.Errorf("blp header is truncated")
+		}
+
+		h.jpeg = binary.LittleEndian.Uint32(data[4:]) == blp2Jpeg
+		h.encoding = data[8]
+		h.alphaBits = uint32(data[9])
+		h.width = binary.LittleEndian.Uint32(data[12:])
+		h.height = binary.LittleEndian.Uint32(data[16:])
+		h.offset = binary.LittleEndian.Uint32(data[20:])
+		h.size = binary.LittleEndian.Uint32(data[84:])
+		h.paletteOffset = blp2HeaderSize
+		if h.encoding == blp2Dxt {
+			return nil, fmt.Errorf("dxt compressed blp textures are not supported")
+		}
+	default:
+		return nil, ErrNotBlp
+	}
+
+	return h, nil
+}
+
+// decodeJpeg decodes a mipmap that is stored as a JPEG with a shared header. The JPEG holds BGRA
+// channels without any color transform, so we tell the decoder as much through an Adobe marker and
+// then put the channels back in order ourselves
+func decodeJpeg(data []byte, h *header, mipmap []byte) (image.Image, error) {
+	if len(data) < h.paletteOffset+4 {
+		return nil, fmt.Errorf("blp jpeg header is truncated")
+	}
+
+	jpegHeaderSize := binary.LittleEndian.Uint32(data[h.paletteOffset:])
+	jpegHeaderStart := h.paletteOffset + 4
+	if uint64(jpegHeaderStart)+uint64(jpegHeaderSize) > uint64(len(data)) || jpegHeaderSize < 2 {
+		return nil, fmt.Errorf("blp jpeg header runs past the end of the file")
+	}
+
+	jpegHeader := data[jpegHeaderStart : jpegHeaderStart+int(jpegHeaderSize)]
+	adobeMarker := []byte{0xFF, 0xEE, 0x00, 0x0E, 'A', 'd', 'o', 'b', 'e', 0x00, 0x64, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	if uint64(h.width)*uint64(h.height) > maxJpegPixelsPerByte*uint64(len(jpegHeader)+len(mipmap)) {
+		return nil, fmt.Errorf("blp mipmap is too small for a %dx%d texture", h.width, h.height)
+	}
+
+	var buffer bytes.Buffer
+	buffer.Write(jpegHeader[:2])
+	buffer.Write(adobeMarker)
+	buffer.Write(jpegHeader[2:])
+	buffer.Write(mipmap)
+
+	// The decoder allocates whatever the JPEG claims, which has to be what the header claims
+	config, err := jpeg.DecodeConfig(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Width != int(h.width) || config.Height != int(h.height) {
+		return nil, fmt.Errorf("blp jpeg is %dx%d but the header says %dx%d", config.Width, config.Height, h.width, h.height)
+	}
+
+	decoded, err := jpeg.Decode(&buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := decoded.Bounds()
+	img := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			var b, g, r, a uint8
+			switch decoded := decoded.(type) {
+			case *image.CMYK:
+				// Adobe CMYK is stored inverted and the decoder undoes that, so we invert it back
+				i := decoded.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+				b, g, r, a = 255-decoded.Pix[i], 255-decoded.Pix[i+1], 255-decoded.Pix[i+2], 255-decoded.Pix[i+3]
+			case *image.RGBA:
+				i := decoded.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+				b, g, r, a = decoded.Pix[i], decoded.Pix[i+1], decoded.Pix[i+2], 255
+			default:
+				c := color.NRGBAModel.Convert(decoded.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+				b, g, r, a = c.B, c.G, c.R, 255
+			}
+
+			if h.alphaBits == 0 {
+				a = 255
+			}
+
+			img.SetNRGBA(x, y, color.NRGBA{r, g, b, a})
+		}
+	}
+
+	return img, nil
+}
+
+// decodePalette decodes a mipmap made up of palette indices followed by the alpha values
+func decodePalette(data []byte, h *header, mipmap []byte) (image.Image, error) {
+	if len(data) < h.paletteOffset+paletteSize {
+		return nil, fmt.Errorf("blp palette is truncated")
+	}
+
+	palette := data[h.paletteOffset : h.paletteOffset+paletteSize]
+	pixels := int(h.width) * int(h.height)
+	alphaSize := (pixels*int(h.alphaBits) + 7) / 8
+	if len(mipmap) < pixels+alphaSize {
+		return nil, fmt.Errorf("blp mipmap is too small for a %dx%d texture", h.width, h.height)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, int(h.width), int(h.height)))
+	alpha := mipmap[pixels:]
+	for i := 0; i < pixels; i++ {
+		entry := palette[int(mipmap[i])*4:]
+
+		var a uint8 = 255
+		switch h.alphaBits {
+		case 1:
+			if alpha[i/8]&(1<<uint(i%8)) == 0 {
+				a = 0
+			}
+		case 4:
+			a = (alpha[i/2] >> uint(4*(i%2)) & 0x0F) * 17
+		case 8:
+			a = alpha[i]
+		}
+
+		img.Pix[i*4] = entry[2]
+		img.Pix[i*4+1] = entry[1]
+		img.Pix[i*4+2] = entry[0]
+		img.Pix[i*4+3] = a
+	}
+
+	return img, nil
+}
+
+// decodeUncompressed decodes a BLP2 mipmap that holds plain BGRA pixels
+func decodeUncompressed(h *header, mipmap []byte) (image.Image, error) {
+	pixels := int(h.width) * int(h.height)
+	if len(mipmap) < pixels*4 {
+		return nil, fmt.Errorf("blp mipmap is too small for a %dx%d texture", h.width, h.height)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, int(h.width), int(h.height)))
+	for i := 0; i < pixels; i++ {
+		img.Pix[i*4] = mipmap[i*4+2]
+		img.Pix[i*4+1] = mipmap[i*4+1]
+		img.Pix[i*4+2] = mipmap[i*4]
+		img.Pix[i*4+3] = mipmap[i*4+3]
+		if h.alphaBits == 0 {
+			img.Pix[i*4+3] = 255
+		}
+	}
+
+	return img, nil
+}
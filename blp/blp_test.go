@@ -0,0 +1,159 @@
+package blp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+// blp1 builds a BLP1 texture with a single mipmap, content is the palette or the JPEG header that comes
+// before the mipmap
+func blp1(compression uint32, alphaBits uint32, width uint32, height uint32, content []byte, mipmap []byte) []byte {
+	data := make([]byte, blp1HeaderSize)
+	copy(data, "BLP1")
+	binary.LittleEndian.PutUint32(data[4:], compression)
+	binary.LittleEndian.PutUint32(data[8:], alphaBits)
+	binary.LittleEndian.PutUint32(data[12:], width)
+	binary.LittleEndian.PutUint32(data[16:], height)
+	binary.LittleEndian.PutUint32(data[28:], uint32(blp1HeaderSize+len(content)))
+	binary.LittleEndian.PutUint32(data[92:], uint32(len(mipmap)))
+
+	return append(append(data, content...), mipmap...)
+}
+
+// jpegTexture builds a BLP1 texture whose shared JPEG header is the start of image marker and whose mipmap
+// is the rest of an encoded JPEG
+func jpegTexture(t *testing.T, width uint32, height uint32, img image.Image) []byte {
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := buffer.Bytes()
+	content := make([]byte, 4)
+	binary.LittleEndian.PutUint32(content, 2)
+
+	return blp1(blp1Jpeg, 0, width, height, append(content, encoded[:2]...), encoded[2:])
+}
+
+func uniformImage(width int, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 128
+	}
+
+	return img
+}
+
+func TestDecodeJpeg(t *testing.T) {
+	img, err := Decode(bytes.NewReader(jpegTexture(t, 16, 8, uniformImage(16, 8))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bounds := img.Bounds(); bounds.Dx() != 16 || bounds.Dy() != 8 {
+		t.Errorf("got a %dx%d image, expected 16x8", bounds.Dx(), bounds.Dy())
+	}
+
+	if _, _, _, a := img.At(3, 3).RGBA(); a != 0xFFFF {
+		t.Errorf("a texture without alpha bits has alpha %d", a)
+	}
+}
+
+func TestDecodePalette(t *testing.T) {
+	palette := make([]byte, paletteSize)
+	copy(palette, []byte{0x10, 0x20, 0x30, 0, 0x40, 0x50, 0x60, 0})
+
+	// Two pixels using the two palette entries followed by their 8 bit alpha
+	img, err := Decode(bytes.NewReader(blp1(blp1Palette, 8, 2, 1, palette, []byte{0, 1, 0xFF, 0x80})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []color.NRGBA{{0x30, 0x20, 0x10, 0xFF}, {0x60, 0x50, 0x40, 0x80}}
+	for x, c := range expected {
+		if got := img.(*image.NRGBA).NRGBAAt(x, 0); got != c {
+			t.Errorf("pixel %d is %v, expected %v", x, got, c)
+		}
+	}
+}
+
+func TestDecodeUncompressed(t *testing.T) {
+	data := make([]byte, blp2HeaderSize)
+	copy(data, "BLP2")
+	binary.LittleEndian.PutUint32(data[4:], 1)
+	data[8] = blp2Uncompressed
+	data[9] = 8
+	binary.LittleEndian.PutUint32(data[12:], 1)
+	binary.LittleEndian.PutUint32(data[16:], 1)
+	binary.LittleEndian.PutUint32(data[20:], blp2HeaderSize)
+	binary.LittleEndian.PutUint32(data[84:], 4)
+	data = append(data, 0x10, 0x20, 0x30, 0x40)
+
+	img, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := img.(*image.NRGBA).NRGBAAt(0, 0), (color.NRGBA{0x30, 0x20, 0x10, 0x40}); got != expected {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	config, err := DecodeConfig(bytes.NewReader(jpegTexture(t, 16, 8, uniformImage(16, 8))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Width != 16 || config.Height != 8 {
+		t.Errorf("got %dx%d, expected 16x8", config.Width, config.Height)
+	}
+
+	if _, err = DecodeConfig(strings.NewReader("PNG")); err != ErrNotBlp {
+		t.Errorf("got %v, expected %v", err, ErrNotBlp)
+	}
+}
+
+func TestDecodeRejectsMalformedTextures(t *testing.T) {
+	jpegBlp := jpegTexture(t, 16, 8, uniformImage(16, 8))
+
+	// The header claims the largest texture there can be while the JPEG is only 16x8
+	huge := append([]byte{}, jpegBlp...)
+	binary.LittleEndian.PutUint32(huge[12:], 65535)
+	binary.LittleEndian.PutUint32(huge[16:], 65535)
+
+	smaller := append([]byte{}, jpegBlp...)
+	binary.LittleEndian.PutUint32(smaller[12:], 8)
+
+	pastTheEnd := append([]byte{}, jpegBlp...)
+	binary.LittleEndian.PutUint32(pastTheEnd[92:], uint32(len(jpegBlp)))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		message string
+	}{
+		{"not a blp", []byte("PNG image"), ErrNotBlp.Error()},
+		{"truncated header", []byte("BLP1\x00\x00"), "blp header is truncated"},
+		{"no pixels", blp1(blp1Palette, 0, 0, 4, make([]byte, paletteSize), nil), "invalid dimensions 0x4"},
+		{"too large", blp1(blp1Palette, 0, 65536, 1, make([]byte, paletteSize), nil), "invalid dimensions 65536x1"},
+		{"huge jpeg header", huge, "too small for a 65535x65535 texture"},
+		{"header disagrees with the jpeg", smaller, "blp jpeg is 16x8 but the header says 8x8"},
+		{"mipmap past the end", pastTheEnd, "runs past the end of the file"},
+		{"palette mipmap too small", blp1(blp1Palette, 8, 64, 64, make([]byte, paletteSize), make([]byte, 64)), "too small for a 64x64 texture"},
+		{"unknown compression", blp1(7, 0, 1, 1, nil, nil), "unsupported blp compression 7"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Decode(bytes.NewReader(test.data)); err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("got %v, expected an error about %q", err, test.message)
+			}
+		})
+	}
+}
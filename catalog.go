@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	MODEL_CATALOG_FILENAME = "model-catalog.json"
+	MODEL_CATALOG_FORMAT   = 1
+	MODEL_GROUP_UNITS      = "Units"
+	MODEL_GROUP_ABILITIES  = "Abilities"
+	MODEL_GROUP_MISSILES   = "Missiles"
+	MODEL_GROUP_ITEMS      = "Items"
+)
+
+var (
+	// Cached catalogs by resource version so that we only ever touch the disk once per version
+	modelCatalogs = make(map[string]*modelCatalog)
+
+	defaultModelCategories = []*ModelCategory{
+		{"units", MODEL_GROUP_UNITS, "units"},
+		{"buildings", MODEL_GROUP_UNITS, "buildings"},
+		{"spells", MODEL_GROUP_ABILITIES, "abilities/spells"},
+		{"weapons", MODEL_GROUP_MISSILES, "abilities/weapons"},
+		{"inventoryitems", MODEL_GROUP_ITEMS, "objects/inventoryitems"},
+	}
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+// ModelCategory maps a folder inside the resources onto one of the model groups shown by the editor
+type ModelCategory struct {
+	Name   string
+	Group  string
+	Folder string
+}
+
+type ModelSearch struct {
+	Query    string
+	Category string
+	Group    string
+}
+
+type ModelCatalogRefresh struct {
+	Models  *GroupedModels
+	Added   int
+	Removed int
+}
+
+/**
+*    PRIVATE STRUCTURES
+ */
+type modelCatalog struct {
+	Format     int
+	Key        string
+	Categories []*ModelCategory
+	Entries    []*modelCatalogEntry
+	// Directories holds what we last saw in every directory, keyed by path relative to the resource root
+	Directories map[string]*modelCatalogDirectory
+}
+
+type modelCatalogEntry struct {
+	Name     string
+	Path     string
+	Category string
+	Group    string
+}
+
+type modelCatalogDirectory struct {
+	ModTime     int64
+	Models      []string
+	Directories []string
+}
+
+// getModelCategories returns the configured model categories or the default ones when none have been configured
+func getModelCategories() []*ModelCategory {
+	if len(configuration.ModelCategories) > 0 {
+		return configuration.ModelCategories
+	}
+
+	return defaultModelCategories
+}
+
+func validateModelCategories(categories []*ModelCategory) error {
+	names := make(map[string]bool)
+	for _, category := range categories {
+		if category == nil || category.Name == "" {
+			return fmt.Errorf("model categories need a name")
+		}
+
+		if names[category.Name] {
+			return fmt.Errorf("model category %q is listed more than once", category.Name)
+		}
+		names[category.Name] = true
+
+		switch category.Group {
+		case MODEL_GROUP_UNITS, MODEL_GROUP_ABILITIES, MODEL_GROUP_MISSILES, MODEL_GROUP_ITEMS:
+		default:
+			return fmt.Errorf("model category %q has unknown group %q", category.Name, category.Group)
+		}
+
+		folder := filepath.ToSlash(filepath.Clean(filepath.FromSlash(category.Folder)))
+		if category.Folder == "" || filepath.IsAbs(category.Folder) || folder == ".." || strings.HasPrefix(folder, "../") {
+			return fmt.Errorf("model category %q must point at a folder inside the resources", category.Name)
+		}
+	}
+
+	return nil
+}
+
+// getModelCatalogKey identifies the resources a catalog was built from, a new download or a different
+// source gives us a new key and therefore a fresh catalog
+func getModelCatalogKey(name string) string {
+	version := getResourceVersion(name)
+	source := getResourceSource(version)
+	if version != nil && version.ETag != nil {
+		return source.Kind + ":" + *version.ETag
+	}
+
+	return source.Kind + ":" + source.Location
+}
+
+func getModelCatalogPath(name string) (string, error) {
+	path, err := getResourceVersionDirectory(name)
+	if err != nil {
+		return "", err
+	}
+
+	return path + string(filepath.Separator) + MODEL_CATALOG_FILENAME, nil
+}
+
+// getModelCatalog returns the catalog for the selected resource version, it is read from disk or built
+// when we haven't seen this version yet
+func getModelCatalog() (*modelCatalog, error) {
+	name := getResourceVersionName()
+	key := getModelCatalogKey(name)
+	categories := getModelCategories()
+
+	catalog, ok := modelCatalogs[name]
+	if ok && catalog.matches(key, categories) {
+		return catalog, nil
+	}
+
+	catalogPath, err := getModelCatalogPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog = &modelCatalog{}
+	if fileData, err := ioutil.ReadFile(catalogPath); err == nil {
+		if err = json.Unmarshal(fileData, catalog); err != nil {
+			catalog = &modelCatalog{}
+		}
+	}
+
+	if !catalog.matches(key, categories) {
+		catalog = &modelCatalog{Format: MODEL_CATALOG_FORMAT, Key: key}
+		_, _, err = catalog.refresh(name, categories)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	modelCatalogs[name] = catalog
+	return catalog, nil
+}
+
+// refreshModelCatalog brings the catalog of the selected resource version up to date with what's on disk
+func refreshModelCatalog() (*ModelCatalogRefresh, error) {
+	catalog, err := getModelCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	added, removed, err := catalog.refresh(getResourceVersionName(), getModelCategories())
+	if err != nil {
+		return nil, err
+	}
+
+	return &ModelCatalogRefresh{catalog.grouped(), added, removed}, nil
+}
+
+// forgetModelCatalogs drops the catalogs that are kept in memory, for instance after the categories changed
+func forgetModelCatalogs() {
+	modelCatalogs = make(map[string]*modelCatalog)
+}
+
+func (catalog *modelCatalog) matches(key string, categories []*ModelCategory) bool {
+	if catalog.Format != MODEL_CATALOG_FORMAT || catalog.Key != key || len(catalog.Categories) != len(categories) {
+		return false
+	}
+
+	for i, category := range categories {
+		if *catalog.Categories[i] != *category {
+			return false
+		}
+	}
+
+	return true
+}
+
+// refresh walks the category folders, directories that haven't been modified since the last walk are taken
+// from the catalog instead of being read again
+func (catalog *modelCatalog) refresh(name string, categories []*ModelCategory) (int, int, error) {
+	root, err := getResourceVersionRoot(name)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	previousPaths := make(map[string]bool)
+	for _, entry := range catalog.Entries {
+		previousPaths[entry.Path] = true
+	}
+
+	previousDirectories := catalog.Directories
+	catalog.Directories = make(map[string]*modelCatalogDirectory)
+	catalog.Entries = []*modelCatalogEntry{}
+	for _, category := range categories {
+		err = catalog.walk(root, filepath.FromSlash(category.Folder), category, previousDirectories)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	added := 0
+	for _, entry := range catalog.Entries {
+		if previousPaths[entry.Path] {
+			delete(previousPaths, entry.Path)
+		} else {
+			added++
+		}
+	}
+
+	catalog.Categories = categories
+	sort.SliceStable(catalog.Entries, func(i, j int) bool {
+		return catalog.Entries[i].Name < catalog.Entries[j].Name
+	})
+
+	return added, len(previousPaths), catalog.save(name)
+}
+
+func (catalog *modelCatalog) walk(root string, relativePath string, category *ModelCategory, previousDirectories map[string]*modelCatalogDirectory) error {
+	fileStat, err := os.Stat(root + string(filepath.Separator) + relativePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !fileStat.IsDir() {
+		return nil
+	}
+
+	directory, ok := previousDirectories[relativePath]
+	if !ok || directory.ModTime != fileStat.ModTime().UnixNano() {
+		directory, err = readModelCatalogDirectory(root+string(filepath.Separator)+relativePath, fileStat.ModTime().UnixNano())
+		if err != nil {
+			return err
+		}
+	}
+
+	catalog.Directories[relativePath] = directory
+	for _, model := range directory.Models {
+		index := strings.LastIndex(model, ".")
+		catalog.Entries = append(catalog.Entries, &modelCatalogEntry{model[:index], relativePath + string(filepath.Separator) + model, category.Name, category.Group})
+	}
+
+	for _, subdirectory := range directory.Directories {
+		err = catalog.walk(root, relativePath+string(filepath.Separator)+subdirectory, category, previousDirectories)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readModelCatalogDirectory(path string, modTime int64) (*modelCatalogDirectory, error) {
+	filesInDirectory, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	directory := &modelCatalogDirectory{ModTime: modTime}
+	for _, file := range filesInDirectory {
+		if file.IsDir() {
+			directory.Directories = append(directory.Directories, file.Name())
+		} else if strings.HasSuffix(file.Name(), ".mdx") {
+			directory.Models = append(directory.Models, file.Name())
+		}
+	}
+
+	return directory, nil
+}
+
+func (catalog *modelCatalog) save(name string) error {
+	catalogPath, err := getModelCatalogPath(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(catalogPath), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	catalogInBytes, err := json.Marshal(catalog)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(catalogPath, catalogInBytes, 0644)
+}
+
+// grouped returns the catalog in the shape the model selection expects
+func (catalog *modelCatalog) grouped() *GroupedModels {
+	groupedModels := &GroupedModels{Models{}, Models{}, Models{}, Models{}}
+	for _, entry := range catalog.Entries {
+		model := Model{entry.Name, entry.Path}
+		switch entry.Group {
+		case MODEL_GROUP_UNITS:
+			groupedModels.Units = append(groupedModels.Units, model)
+		case MODEL_GROUP_ABILITIES:
+			groupedModels.Abilities = append(groupedModels.Abilities, model)
+		case MODEL_GROUP_MISSILES:
+			groupedModels.Missiles = append(groupedModels.Missiles, model)
+		case MODEL_GROUP_ITEMS:
+			groupedModels.Items = append(groupedModels.Items, model)
+		}
+	}
+
+	return groupedModels
+}
+
+// search finds models by name, optionally only within one category or group
+func (catalog *modelCatalog) search(modelSearch *ModelSearch) (Models, error) {
+	var reg *regexp.Regexp
+	var err error
+	if configuration.IsRegexSearch {
+		reg, err = regexp.Compile("(?i)" + modelSearch.Query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query := strings.ToLower(modelSearch.Query)
+	result := Models{}
+	for _, entry := range catalog.Entries {
+		if modelSearch.Category != "" && entry.Category != modelSearch.Category {
+			continue
+		}
+
+		if modelSearch.Group != "" && entry.Group != modelSearch.Group {
+			continue
+		}
+
+		if reg != nil && !reg.MatchString(entry.Name) {
+			continue
+		} else if reg == nil && !strings.Contains(strings.ToLower(entry.Name), query) {
+			continue
+		}
+
+		result = append(result, Model{entry.Name, entry.Path})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+const (
+	GENERATE_CODE_COMMAND  = "generate-code"
+	CODE_LANGUAGE_JASS     = "jass"
+	CODE_LANGUAGE_LUA      = "lua"
+	CODE_JASS_FILENAME     = "ObjectIds.j"
+	CODE_LUA_FILENAME      = "ObjectIds.lua"
+	CODE_LUA_MODULE        = "ObjectIds"
+	DEFAULT_UNIT_PREFIX    = "UNIT_"
+	DEFAULT_ITEM_PREFIX    = "ITEM_"
+	DEFAULT_ABILITY_PREFIX = "ABILITY_"
+)
+
+var (
+	// The ids of the units and items that come with the game, abilities have baseAbilityMap
+	baseUnitIds = make(map[string]bool)
+	baseItemIds = make(map[string]bool)
+
+	errBaseObjectsMissing = fmt.Errorf("the objects that come with the game have not been loaded, so custom objects can't be told apart")
+
+	colorCodePattern      = regexp.MustCompile(`(?i)\|c[0-9a-f]{8}|\|r|\|n`)
+	identifierPartPattern = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type CodeGeneration struct {
+	Languages     []string
+	CustomOnly    bool
+	UnitPrefix    null.String
+	ItemPrefix    null.String
+	AbilityPrefix null.String
+}
+
+type ObjectConstant struct {
+	Name    string
+	RawCode string
+}
+
+// loadBaseObjectIds reads the ids of the units and items in the base data
+func loadBaseObjectIds(dataDirectory string) {
+	baseUnitIds = readSlkIds(filepath.Join(dataDirectory, "UnitData.slk"))
+	baseItemIds = readSlkIds(filepath.Join(dataDirectory, "ItemData.slk"))
+}
+
+func readSlkIds(path string) map[string]bool {
+	ids := make(map[string]bool)
+
+	fileBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ids
+	}
+
+	table, err := sylk.Read(fileBytes)
+	if err != nil {
+		log.Println(err)
+		return ids
+	}
+
+	for _, row := range table.Rows {
+		if id := sylk.Unquote(row[0]); id != "" {
+			ids[id] = true
+		}
+	}
+
+	return ids
+}
+
+func isCustomObject(category string, id string) bool {
+	switch category {
+	case CATEGORY_UNITS:
+		return !baseUnitIds[id]
+	case CATEGORY_ITEMS:
+		return !baseItemIds[id]
+	case CATEGORY_ABILITIES:
+		_, isBase := baseAbilityMap[id]
+		return !isBase
+	}
+
+	return true
+}
+
+// sanitizeIdentifier turns an object name into something both JASS and Lua accept as a name, color codes are
+// dropped and everything that isn't a letter or a digit becomes an underscore
+func sanitizeIdentifier(name string) string {
+	name = colorCodePattern.ReplaceAllString(sylk.Unquote(strings.TrimSpace(name)), " ")
+	name = strings.Trim(identifierPartPattern.ReplaceAllString(name, "_"), "_")
+
+	return strings.ToUpper(name)
+}
+
+// codePrefix returns the prefix to put in front of the constant names, everything that isn't a letter or a digit
+// becomes an underscore like it does in the names themselves
+func codePrefix(prefix null.String, defaultPrefix string) string {
+	if prefix.Valid {
+		return strings.TrimLeft(identifierPartPattern.ReplaceAllString(prefix.String, "_"), "_")
+	}
+
+	return defaultPrefix
+}
+
+// getObjectConstants returns a constant per object sorted by rawcode. Objects that end up with the same name as one
+// that comes before them get their rawcode added to it
+func getObjectConstants(options *CodeGeneration, category string) []ObjectConstant {
+	var prefix, defaultPrefix string
+	var ids []string
+	names := make(map[string]string)
+	switch category {
+	case CATEGORY_UNITS:
+		defaultPrefix = DEFAULT_UNIT_PREFIX
+		prefix = codePrefix(options.UnitPrefix, defaultPrefix)
+		ids = sortedObjectKeys(unitMap)
+		for id, unit := range unitMap {
+			if unit.UnitString != nil {
+				names[id] = unit.UnitString.Name.String
+			}
+		}
+	case CATEGORY_ITEMS:
+		defaultPrefix = DEFAULT_ITEM_PREFIX
+		prefix = codePrefix(options.ItemPrefix, defaultPrefix)
+		ids = sortedObjectKeys(itemMap)
+		for id, item := range itemMap {
+			if item.ItemString != nil {
+				names[id] = item.ItemString.Name.String
+			}
+		}
+	case CATEGORY_ABILITIES:
+		defaultPrefix = DEFAULT_ABILITY_PREFIX
+		prefix = codePrefix(options.AbilityPrefix, defaultPrefix)
+		ids = sortedObjectKeys(abilityMap)
+		for id, ability := range abilityMap {
+			if ability.AbilityString != nil {
+				names[id] = ability.AbilityString.Name.String
+			}
+		}
+	}
+
+	var constants []ObjectConstant
+	used := make(map[string]bool)
+	for _, id := range ids {
+		if options.CustomOnly && !isCustomObject(category, id) {
+			continue
+		}
+
+		// Rawcodes are always four characters that fit in a JASS integer literal
+		if len(id) != 4 || strings.ContainsAny(id, "'\"\\") {
+			continue
+		}
+
+		name := sanitizeIdentifier(names[id])
+		if name == "" {
+			name = sanitizeIdentifier(id)
+		}
+
+		// Names have to start with a letter
+		name = prefix + name
+		if (name[0] < 'A' || name[0] > 'Z') && (name[0] < 'a' || name[0] > 'z') {
+			name = defaultPrefix + name
+		}
+
+		if used[name] {
+			name = name + "_" + identifierPartPattern.ReplaceAllString(id, "_")
+		}
+
+		used[name] = true
+		constants = append(constants, ObjectConstant{name, id})
+	}
+
+	return constants
+}
+
+func generateJass(sections []string, constants map[string][]ObjectConstant) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("// Generated by " + VENDOR_NAME + ", changes are lost the next time the file is generated\r\n")
+	buffer.WriteString("globals\r\n")
+	for i, section := range sections {
+		if i > 0 {
+			buffer.WriteString("\r\n")
+		}
+
+		fmt.Fprintf(&buffer, "    // %s\r\n", strings.Title(section))
+		for _, constant := range constants[section] {
+			fmt.Fprintf(&buffer, "    constant integer %s = '%s'\r\n", constant.Name, constant.RawCode)
+		}
+	}
+
+	buffer.WriteString("endglobals\r\n")
+
+	return buffer.Bytes()
+}
+
+func generateLua(sections []string, constants map[string][]ObjectConstant) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("-- Generated by " + VENDOR_NAME + ", changes are lost the next time the file is generated\r\n")
+	fmt.Fprintf(&buffer, "local %s = {}\r\n", CODE_LUA_MODULE)
+	for _, section := range sections {
+		fmt.Fprintf(&buffer, "\r\n-- %s\r\n", strings.Title(section))
+		for _, constant := range constants[section] {
+			fmt.Fprintf(&buffer, "%s.%s = FourCC(\"%s\")\r\n", CODE_LUA_MODULE, constant.Name, constant.RawCode)
+		}
+	}
+
+	fmt.Fprintf(&buffer, "\r\nreturn %s\r\n", CODE_LUA_MODULE)
+
+	return buffer.Bytes()
+}
+
+// generateCode writes the JASS and Lua constants to the folder and returns the files that were written
+func generateCode(options *CodeGeneration, location string) ([]string, error) {
+	// Without the base data every object would count as custom
+	if options.CustomOnly && (len(baseUnitIds) == 0 || len(baseItemIds) == 0 || len(baseAbilityMap) == 0) {
+		return nil, errBaseObjectsMissing
+	}
+
+	languages := options.Languages
+	if len(languages) < 1 {
+		languages = []string{CODE_LANGUAGE_JASS, CODE_LANGUAGE_LUA}
+	}
+
+	sections := []string{CATEGORY_UNITS, CATEGORY_ITEMS, CATEGORY_ABILITIES}
+	constants := make(map[string][]ObjectConstant)
+	for _, section := range sections {
+		constants[section] = getObjectConstants(options, section)
+	}
+
+	var files []string
+	for _, language := range languages {
+		language = strings.TrimSpace(language)
+
+		var path string
+		var data []byte
+		switch strings.ToLower(language) {
+		case CODE_LANGUAGE_JASS:
+			path, data = filepath.Join(location, CODE_JASS_FILENAME), generateJass(sections, constants)
+		case CODE_LANGUAGE_LUA:
+			path, data = filepath.Join(location, CODE_LUA_FILENAME), generateLua(sections, constants)
+		default:
+			return nil, fmt.Errorf("%s is not a supported language", language)
+		}
+
+		if err := writeFileAtomically(path, data); err != nil {
+			return nil, err
+		}
+
+		files = append(files, path)
+	}
+
+	return files, nil
+}
+
+// runGenerateCodeCommand generates the constants without opening the editor, the folders default to the ones
+// the editor was last configured with
+func runGenerateCodeCommand(args []string) error {
+	commandFlags := flag.NewFlagSet(GENERATE_CODE_COMMAND, flag.ContinueOnError)
+	commandInput := commandFlags.String("input", "", "sets the input folder where the SLK files are stored")
+	commandOutput := commandFlags.String("output", "", "sets the output folder the code is written to")
+	commandResources := commandFlags.String("resources", "", "sets where base data comes from, either a URL, a zip file or an already extracted folder")
+	languages := commandFlags.String("languages", CODE_LANGUAGE_JASS+","+CODE_LANGUAGE_LUA, "comma separated languages to generate code for")
+	customOnly := commandFlags.Bool("custom-only", false, "leaves out the objects that come with the game")
+	unitPrefix := commandFlags.String("unit-prefix", DEFAULT_UNIT_PREFIX, "prefix for unit constants")
+	itemPrefix := commandFlags.String("item-prefix", DEFAULT_ITEM_PREFIX, "prefix for item constants")
+	abilityPrefix := commandFlags.String("ability-prefix", DEFAULT_ABILITY_PREFIX, "prefix for ability constants")
+	if err := commandFlags.Parse(args); err != nil {
+		return err
+	}
+
+	if config := loadConfigFile(CONFIG_FILENAME); config != nil {
+		if fileData, err := ioutil.ReadFile(filepath.Join(config.Path, CONFIG_FILENAME)); err == nil {
+			if err = json.Unmarshal(fileData, &configuration); err != nil {
+				return err
+			}
+		}
+	}
+
+	if *commandInput != "" {
+		configuration.InDir = commandInput
+	}
+
+	if *commandOutput != "" {
+		configuration.OutDir = commandOutput
+	}
+
+	if configuration.InDir == nil || configuration.OutDir == nil {
+		return fmt.Errorf("both the input and the output folder have to be set")
+	}
+
+	if err := migrateResourceVersions(); err != nil {
+		return err
+	}
+
+	if *commandResources != "" {
+		useCommandLineResources(commandResources)
+	}
+
+	// Base data is only needed to tell custom objects apart
+	if *customOnly {
+		if err := loadData(); err != nil {
+			return err
+		}
+	}
+
+	loadSLK()
+
+	options := &CodeGeneration{
+		Languages:     strings.Split(*languages, ","),
+		CustomOnly:    *customOnly,
+		UnitPrefix:    null.StringFrom(*unitPrefix),
+		ItemPrefix:    null.StringFrom(*itemPrefix),
+		AbilityPrefix: null.StringFrom(*abilityPrefix),
+	}
+
+	files, err := generateCode(options, *configuration.OutDir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		fmt.Println(file)
+	}
+
+	return nil
+}
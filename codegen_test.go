@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/runi95/wts-parser/models"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+// useCodeObjects swaps in a base and a custom unit, the returned function puts the originals back
+func useCodeObjects() func() {
+	previousUnits, previousItems, previousAbilities := unitMap, itemMap, abilityMap
+	previousBaseUnits, previousBaseItems, previousBaseAbilities := baseUnitIds, baseItemIds, baseAbilityMap
+
+	unitMap = map[string]*models.SLKUnit{"hfoo": newTestUnit("420"), "h000": newTestUnit("425")}
+	unitMap["hfoo"].UnitString.Name.SetValid("Footman")
+	unitMap["h000"].UnitString.Name.SetValid("|cffffcc00Royal|r Guard")
+	itemMap = map[string]*models.SLKItem{}
+	abilityMap = map[string]*models.SLKAbility{}
+	baseUnitIds = map[string]bool{"hfoo": true}
+	baseItemIds = map[string]bool{"ratc": true}
+	baseAbilityMap = map[string]*models.SLKAbility{"AHbz": {}}
+
+	return func() {
+		unitMap, itemMap, abilityMap = previousUnits, previousItems, previousAbilities
+		baseUnitIds, baseItemIds, baseAbilityMap = previousBaseUnits, previousBaseItems, previousBaseAbilities
+	}
+}
+
+func TestGetObjectConstantsCustomOnly(t *testing.T) {
+	defer useCodeObjects()()
+
+	constants := getObjectConstants(&CodeGeneration{CustomOnly: true}, CATEGORY_UNITS)
+	if expected := []ObjectConstant{{"UNIT_ROYAL_GUARD", "h000"}}; !reflect.DeepEqual(constants, expected) {
+		t.Errorf("got %v, expected %v", constants, expected)
+	}
+}
+
+func TestGenerateCodeCustomOnlyWithoutBaseData(t *testing.T) {
+	defer useCodeObjects()()
+
+	baseUnitIds = make(map[string]bool)
+
+	directory := t.TempDir()
+	if _, err := generateCode(&CodeGeneration{Languages: []string{CODE_LANGUAGE_JASS}, CustomOnly: true}, directory); err != errBaseObjectsMissing {
+		t.Errorf("got %v, expected %v", err, errBaseObjectsMissing)
+	}
+
+	if files, _ := ioutil.ReadDir(directory); len(files) > 0 {
+		t.Errorf("%s was written without the base data", filepath.Join(directory, files[0].Name()))
+	}
+
+	// Every object is written when custom objects don't have to be told apart
+	if _, err := generateCode(&CodeGeneration{Languages: []string{CODE_LANGUAGE_JASS}}, directory); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetObjectConstantsSanitizesPrefixes(t *testing.T) {
+	defer useCodeObjects()()
+
+	tests := []struct {
+		prefix string
+		name   string
+	}{
+		{"my-", "my_ROYAL_GUARD"},
+		{"Hero Units.", "Hero_Units_ROYAL_GUARD"},
+		{"-x", "xROYAL_GUARD"},
+		{"", "ROYAL_GUARD"},
+		{"1st_", "UNIT_1st_ROYAL_GUARD"},
+	}
+
+	for _, test := range tests {
+		options := &CodeGeneration{CustomOnly: true, UnitPrefix: null.StringFrom(test.prefix)}
+		if constants := getObjectConstants(options, CATEGORY_UNITS); len(constants) != 1 || constants[0].Name != test.name {
+			t.Errorf("prefix %q gave %v, expected %s", test.prefix, constants, test.name)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"github.com/runi95/wts-parser/models"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+const (
+	// ARMOR_REDUCTION is the DefenseArmor gameplay constant, the damage taken is divided by
+	// 1 + armor * ARMOR_REDUCTION for positive armor
+	ARMOR_REDUCTION     = 0.06
+	NEGATIVE_ARMOR_BASE = 0.94
+)
+
+// The damage an attack type deals to an armor type as a fraction of its damage, as it comes with the game.
+// The SLK files call light armor small, heavy armor large, fortified armor fort and unarmored none
+var defaultDamageTable = DamageTable{
+	"normal": {"small": 1, "medium": 1.5, "large": 1, "fort": 0.7, "normal": 1, "hero": 1, "divine": 0.05, "none": 1},
+	"pierce": {"small": 2, "medium": 0.75, "large": 1, "fort": 0.35, "normal": 1, "hero": 0.5, "divine": 0.05, "none": 1.5},
+	"siege":  {"small": 1, "medium": 0.5, "large": 1, "fort": 1.5, "normal": 1, "hero": 0.5, "divine": 0.05, "none": 1.5},
+	"magic":  {"small": 1.25, "medium": 0.75, "large": 2, "fort": 0.35, "normal": 1, "hero": 0.5, "divine": 0.05, "none": 1},
+	"chaos":  {"small": 1, "medium": 1, "large": 1, "fort": 1, "normal": 1, "hero": 1, "divine": 1, "none": 1},
+	"spells": {"small": 1, "medium": 1, "large": 1, "fort": 1, "normal": 1, "hero": 0.7, "divine": 0.05, "none": 1},
+	"hero":   {"small": 1, "medium": 1, "large": 1, "fort": 0.5, "normal": 1, "hero": 1, "divine": 0.05, "none": 1},
+}
+
+// The unit fields the damage of a weapon is worked out from, the derived fields are set whenever one of them
+// changes
+var damageInputFields = []string{"Dice1", "Sides1", "Dmgplus1", "Cool1", "Dice2", "Sides2", "Dmgplus2", "Cool2"}
+
+// The inputs of the derived fields, they're disabled by default but lists of disabled inputs written before they
+// were don't have them
+var derivedDamageInputs = []string{"Unit-Mindmg1", "Unit-Avgdmg1", "Unit-Maxdmg1", "Unit-Mindmg2", "Unit-Avgdmg2", "Unit-Maxdmg2", "Unit-DPS"}
+
+/**
+*    PUBLIC STRUCTURES
+ */
+// DamageTable holds the fraction of the damage of an attack type that an armor type takes, by attack type and
+// armor type
+type DamageTable map[string]map[string]float64
+
+type AttackStats struct {
+	Weapon        int
+	AttackType    string
+	MinDamage     float64
+	AverageDamage float64
+	MaxDamage     float64
+	Cooldown      float64
+	Dps           float64
+	// DpsAgainst is the DPS against every armor type before armor is taken into account
+	DpsAgainst map[string]float64
+}
+
+// UnitStats are the combat stats of a unit. Effective HP is the damage of an attack type it takes to kill the
+// unit with its armor taken into account, the costs per stat are gold and lumber together divided by the stat
+type UnitStats struct {
+	Id                 string
+	Name               string
+	Hp                 float64
+	Armor              float64
+	ArmorType          string
+	EffectiveHp        map[string]float64
+	GoldCost           float64
+	LumberCost         float64
+	Cost               float64
+	Attacks            []*AttackStats
+	CostPerHp          float64
+	CostPerEffectiveHp map[string]float64
+	CostPerDps         float64
+}
+
+func getDamageTable() DamageTable {
+	if configuration.DamageTable != nil {
+		return configuration.DamageTable
+	}
+
+	return defaultDamageTable
+}
+
+// validateDamageTable makes sure every attack type has a fraction for every armor type
+func validateDamageTable(table DamageTable) error {
+	if len(table) < 1 {
+		return fmt.Errorf("the damage table is empty")
+	}
+
+	var armorTypes map[string]float64
+	for attackType, fractions := range table {
+		if armorTypes == nil {
+			armorTypes = fractions
+		}
+
+		if len(fractions) != len(armorTypes) {
+			return fmt.Errorf("%s doesn't have a fraction for every armor type", attackType)
+		}
+
+		for armorType, fraction := range fractions {
+			if _, ok := armorTypes[armorType]; !ok {
+				return fmt.Errorf("%s has a fraction for %s which the other attack types don't have", attackType, armorType)
+			}
+
+			if fraction < 0 || math.IsNaN(fraction) || math.IsInf(fraction, 0) {
+				return fmt.Errorf("%s against %s has to be a number of at least 0", attackType, armorType)
+			}
+		}
+	}
+
+	return nil
+}
+
+// numberValue reads a number field, false means that it's empty or not a number
+func numberValue(value null.String) (float64, bool) {
+	if !value.Valid {
+		return 0, false
+	}
+
+	number, err := sylk.Number(sylk.Unquote(strings.TrimSpace(value.String)))
+
+	return number, err == nil
+}
+
+func textValue(value null.String) string {
+	return strings.ToLower(strings.TrimSpace(sylk.Unquote(value.String)))
+}
+
+// armorMultiplier is the fraction of damage a unit with the armor takes, negative armor makes it take more
+func armorMultiplier(armor float64) float64 {
+	if armor < 0 {
+		return 2 - math.Pow(NEGATIVE_ARMOR_BASE, -armor)
+	}
+
+	return 1 / (1 + armor*ARMOR_REDUCTION)
+}
+
+// weaponDamage works the damage of a weapon out the way the game rolls it, every die rolls at least one
+func weaponDamage(dice null.String, sides null.String, bonus null.String) (float64, float64, float64, bool) {
+	diceNumber, ok := numberValue(dice)
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	sidesNumber, ok := numberValue(sides)
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	bonusNumber, ok := numberValue(bonus)
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	return bonusNumber + diceNumber, bonusNumber + diceNumber*(sidesNumber+1)/2, bonusNumber + diceNumber*sidesNumber, true
+}
+
+// formatStat writes a derived number with the fifteen significant digits the game's own files use
+func formatStat(number float64) string {
+	return strconv.FormatFloat(number, 'g', 15, 64)
+}
+
+func isDamageInputField(field string) bool {
+	return containsString(damageInputFields, field)
+}
+
+// deriveDamageFields sets Mindmg, Avgdmg and Maxdmg of both weapons and the DPS of the first one from the dice,
+// sides, damage bonus and cooldown, weapons with values that aren't numbers are left alone
+func deriveDamageFields(unit *models.SLKUnit) {
+	if unit.UnitWeapons == nil {
+		return
+	}
+
+	weapons := unit.UnitWeapons
+	if minimum, average, maximum, ok := weaponDamage(weapons.Dice1, weapons.Sides1, weapons.Dmgplus1); ok {
+		weapons.Mindmg1.SetValid(formatStat(minimum))
+		weapons.Avgdmg1.SetValid(formatStat(average))
+		weapons.Maxdmg1.SetValid(formatStat(maximum))
+
+		if cooldown, ok := numberValue(weapons.Cool1); ok && cooldown > 0 {
+			weapons.DPS.SetValid(formatStat(average / cooldown))
+		}
+	}
+
+	if minimum, average, maximum, ok := weaponDamage(weapons.Dice2, weapons.Sides2, weapons.Dmgplus2); ok {
+		weapons.Mindmg2.SetValid(formatStat(minimum))
+		weapons.Avgdmg2.SetValid(formatStat(average))
+		weapons.Maxdmg2.SetValid(formatStat(maximum))
+	}
+}
+
+func attackStats(weapon int, attackType null.String, dice null.String, sides null.String, bonus null.String, cool null.String) *AttackStats {
+	minimum, average, maximum, ok := weaponDamage(dice, sides, bonus)
+	if !ok {
+		return nil
+	}
+
+	attack := &AttackStats{Weapon: weapon, AttackType: textValue(attackType), MinDamage: minimum, AverageDamage: average, MaxDamage: maximum, DpsAgainst: make(map[string]float64)}
+	if cooldown, ok := numberValue(cool); ok && cooldown > 0 {
+		attack.Cooldown = cooldown
+		attack.Dps = average / cooldown
+		for armorType, fraction := range getDamageTable()[attack.AttackType] {
+			attack.DpsAgainst[armorType] = attack.Dps * fraction
+		}
+	}
+
+	return attack
+}
+
+// getUnitStats works out the combat stats of a unit. Heroes are taken as they are at level one without their
+// attributes and attacks only count when WeapsOn enables them
+func getUnitStats(id string) (*UnitStats, error) {
+	unit, ok := unitMap[id]
+	if !ok {
+		return nil, fmt.Errorf("%s does not exist", id)
+	}
+
+	stats := &UnitStats{Id: id, EffectiveHp: make(map[string]float64), Attacks: []*AttackStats{}, CostPerEffectiveHp: make(map[string]float64)}
+	if unit.UnitString != nil {
+		stats.Name = sylk.Unquote(unit.UnitString.Name.String)
+	}
+
+	if unit.UnitBalance != nil {
+		stats.Hp, _ = numberValue(unit.UnitBalance.HP)
+		stats.Armor, _ = numberValue(unit.UnitBalance.Def)
+		stats.ArmorType = textValue(unit.UnitBalance.DefType)
+		stats.GoldCost, _ = numberValue(unit.UnitBalance.Goldcost)
+		stats.LumberCost, _ = numberValue(unit.UnitBalance.Lumbercost)
+		stats.Cost = stats.GoldCost + stats.LumberCost
+	}
+
+	// The damage every attack type has to deal before armor and armor type, fractions of 0 can't kill the unit
+	for attackType, fractions := range getDamageTable() {
+		fraction := fractions[stats.ArmorType]
+		if _, ok := fractions[stats.ArmorType]; !ok {
+			fraction = 1
+		}
+
+		if fraction > 0 && stats.Hp > 0 {
+			stats.EffectiveHp[attackType] = stats.Hp / armorMultiplier(stats.Armor) / fraction
+			if stats.Cost > 0 {
+				stats.CostPerEffectiveHp[attackType] = stats.Cost / stats.EffectiveHp[attackType]
+			}
+		}
+	}
+
+	if unit.UnitWeapons != nil {
+		weapons := unit.UnitWeapons
+		enabled, _ := numberValue(weapons.WeapsOn)
+		if int(enabled)&1 != 0 {
+			if attack := attackStats(1, weapons.AtkType1, weapons.Dice1, weapons.Sides1, weapons.Dmgplus1, weapons.Cool1); attack != nil {
+				stats.Attacks = append(stats.Attacks, attack)
+			}
+		}
+
+		if int(enabled)&2 != 0 {
+			if attack := attackStats(2, weapons.AtkType2, weapons.Dice2, weapons.Sides2, weapons.Dmgplus2, weapons.Cool2); attack != nil {
+				stats.Attacks = append(stats.Attacks, attack)
+			}
+		}
+	}
+
+	if stats.Cost > 0 {
+		if stats.Hp > 0 {
+			stats.CostPerHp = stats.Cost / stats.Hp
+		}
+
+		// Units only attack with one weapon at a time so the best one is what the unit is worth
+		var dps float64
+		for _, attack := range stats.Attacks {
+			dps = math.Max(dps, attack.Dps)
+		}
+
+		if dps > 0 {
+			stats.CostPerDps = stats.Cost / dps
+		}
+	}
+
+	return stats, nil
+}
+
+// getUnitsStats returns the stats of the units, every unit when no ids are given
+func getUnitsStats(ids []string) ([]*UnitStats, error) {
+	if len(ids) < 1 {
+		ids = sortedObjectKeys(unitMap)
+	}
+
+	unitsStats := make([]*UnitStats, 0, len(ids))
+	for _, id := range ids {
+		stats, err := getUnitStats(id)
+		if err != nil {
+			return nil, err
+		}
+
+		unitsStats = append(unitsStats, stats)
+	}
+
+	return unitsStats, nil
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+const (
+	COMMAND_CARD_MAIN    = "main"
+	COMMAND_CARD_BUILD   = "build"
+	COMMAND_CARD_LEARN   = "learn"
+	COMMAND_CARD_COLUMNS = 4
+	COMMAND_CARD_ROWS    = 3
+	CONFLICT_HOTKEY      = "hotkey"
+	CONFLICT_POSITION    = "position"
+)
+
+var (
+	// The order cards are reported in, the build card is what workers show after pressing build and the learn card
+	// what heroes show when they learn skills
+	commandCards = []string{COMMAND_CARD_MAIN, COMMAND_CARD_BUILD, COMMAND_CARD_LEARN}
+
+	// Upgrades are only read for the buttons of researches, by id and then by lowercase key
+	upgradeMap      = make(map[string]map[string]string)
+	upgradeTxtFiles = []string{
+		"CampaignUpgradeFunc.txt",
+		"CampaignUpgradeStrings.txt",
+		"HumanUpgradeFunc.txt",
+		"HumanUpgradeStrings.txt",
+		"NeutralUpgradeFunc.txt",
+		"NeutralUpgradeStrings.txt",
+		"NightElfUpgradeFunc.txt",
+		"NightElfUpgradeStrings.txt",
+		"OrcUpgradeFunc.txt",
+		"OrcUpgradeStrings.txt",
+		"UndeadUpgradeFunc.txt",
+		"UndeadUpgradeStrings.txt",
+	}
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type CommandButton struct {
+	Id       string
+	Category string
+	// Source is the field of the unit the button comes from, such as AbilList or Trains
+	Source   string
+	Name     string
+	Hotkey   string
+	Position string
+}
+
+type CommandCardConflict struct {
+	UnitId   string
+	UnitName string
+	Card     string
+	Kind     string
+	Value    string
+	Buttons  []*CommandButton
+}
+
+// loadUpgrades reads the names, hotkeys and button positions of the upgrades in the input folder
+func loadUpgrades(inputDirectory string) {
+	upgradeMap = make(map[string]map[string]string)
+	for _, fileName := range upgradeTxtFiles {
+		fileBytes := readInputFile(inputDirectory, &FileInfo{FileName: fileName})
+		if fileBytes == nil {
+			continue
+		}
+
+		for id, section := range readTxtSections(fileBytes) {
+			if upgradeMap[id] == nil {
+				upgradeMap[id] = make(map[string]string)
+			}
+
+			for key, value := range section {
+				upgradeMap[id][strings.ToLower(key)] = value
+			}
+		}
+	}
+}
+
+// upgradeValue returns a key of an upgrade, keys the upgrade doesn't have are null
+func upgradeValue(upgrade map[string]string, key string) null.String {
+	if value, ok := upgrade[strings.ToLower(key)]; ok {
+		return null.StringFrom(value)
+	}
+
+	return null.String{}
+}
+
+// splitObjectIds splits a comma separated list of ids such as AbilList or Trains
+func splitObjectIds(value null.String) []string {
+	var ids []string
+	if !value.Valid {
+		return ids
+	}
+
+	for _, id := range strings.Split(sylk.Unquote(value.String), ",") {
+		if id = strings.TrimSpace(id); id != "" && id != "_" && id != "-" {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// firstValue returns the first level of a value that can be set per level
+func firstValue(value null.String) string {
+	if !value.Valid {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.Split(sylk.Unquote(value.String), ",")[0])
+}
+
+// buttonPosition returns the column and row of a button as x,y, positions outside of the grid are used to hide
+// buttons and are left out. ButtonposX and ButtonposY take precedence over Buttonpos when both are set
+func buttonPosition(position null.String, positionX null.String, positionY null.String) string {
+	var x, y string
+	if positionX.Valid && positionY.Valid {
+		x, y = sylk.Unquote(positionX.String), sylk.Unquote(positionY.String)
+	} else if position.Valid {
+		split := strings.Split(sylk.Unquote(position.String), ",")
+		if len(split) != 2 {
+			return ""
+		}
+
+		x, y = split[0], split[1]
+	}
+
+	column, err := strconv.Atoi(strings.TrimSpace(x))
+	if err != nil {
+		return ""
+	}
+
+	row, err := strconv.Atoi(strings.TrimSpace(y))
+	if err != nil {
+		return ""
+	}
+
+	if column < 0 || column >= COMMAND_CARD_COLUMNS || row < 0 || row >= COMMAND_CARD_ROWS {
+		return ""
+	}
+
+	return fmt.Sprintf("%d,%d", column, row)
+}
+
+func unitButton(id string, source string) *CommandButton {
+	unit, ok := unitMap[id]
+	if !ok {
+		return nil
+	}
+
+	button := &CommandButton{Id: id, Category: CATEGORY_UNITS, Source: source}
+	if unit.UnitString != nil {
+		button.Name = sylk.Unquote(unit.UnitString.Name.String)
+		button.Hotkey = firstValue(unit.UnitString.Hotkey)
+	}
+
+	if unit.UnitFunc != nil {
+		button.Position = buttonPosition(unit.UnitFunc.Buttonpos, unit.UnitFunc.ButtonposX, unit.UnitFunc.ButtonposY)
+	}
+
+	return button
+}
+
+func itemButton(id string, source string) *CommandButton {
+	item, ok := itemMap[id]
+	if !ok {
+		return nil
+	}
+
+	button := &CommandButton{Id: id, Category: CATEGORY_ITEMS, Source: source}
+	if item.ItemString != nil {
+		button.Name = sylk.Unquote(item.ItemString.Name.String)
+		button.Hotkey = firstValue(item.ItemString.Hotkey)
+	}
+
+	if item.ItemFunc != nil {
+		button.Position = buttonPosition(item.ItemFunc.Buttonpos, item.ItemFunc.ButtonposX, item.ItemFunc.ButtonposY)
+	}
+
+	return button
+}
+
+// abilityButton returns the button of an ability, learn is the button heroes use to learn the ability
+func abilityButton(id string, source string, learn bool) *CommandButton {
+	ability, ok := abilityMap[id]
+	if !ok {
+		return nil
+	}
+
+	button := &CommandButton{Id: id, Category: CATEGORY_ABILITIES, Source: source}
+	if ability.AbilityString != nil {
+		button.Name = sylk.Unquote(ability.AbilityString.Name.String)
+		if learn {
+			button.Hotkey = firstValue(ability.AbilityString.Researchhotkey)
+		} else {
+			button.Hotkey = firstValue(ability.AbilityString.Hotkey)
+		}
+	}
+
+	if ability.AbilityFunc != nil {
+		if learn {
+			button.Position = buttonPosition(ability.AbilityFunc.Researchbuttonpos, null.String{}, null.String{})
+		} else {
+			button.Position = buttonPosition(ability.AbilityFunc.Buttonpos, null.String{}, null.String{})
+		}
+	}
+
+	return button
+}
+
+// upgradeButton returns the button of the first level of an upgrade
+func upgradeButton(id string, source string) *CommandButton {
+	upgrade, ok := upgradeMap[id]
+	if !ok {
+		return nil
+	}
+
+	return &CommandButton{
+		Id:       id,
+		Category: CATEGORY_UPGRADES,
+		Source:   source,
+		Name:     firstValue(upgradeValue(upgrade, "Name")),
+		Hotkey:   firstValue(upgradeValue(upgrade, "Hotkey")),
+		Position: buttonPosition(upgradeValue(upgrade, "Buttonpos"), upgradeValue(upgrade, "ButtonposX"), upgradeValue(upgrade, "ButtonposY")),
+	}
+}
+
+// getCommandCard returns the buttons a unit shows by card
+func getCommandCard(unitId string) map[string][]*CommandButton {
+	card := make(map[string][]*CommandButton)
+	unit, ok := unitMap[unitId]
+	if !ok {
+		return card
+	}
+
+	seen := make(map[string]bool)
+	add := func(name string, button *CommandButton) {
+		if button == nil || seen[name+button.Category+button.Id] {
+			return
+		}
+
+		seen[name+button.Category+button.Id] = true
+		card[name] = append(card[name], button)
+	}
+
+	if unit.UnitAbilities != nil {
+		for _, id := range splitObjectIds(unit.UnitAbilities.AbilList) {
+			add(COMMAND_CARD_MAIN, abilityButton(id, "AbilList", false))
+		}
+
+		for _, id := range splitObjectIds(unit.UnitAbilities.HeroAbilList) {
+			add(COMMAND_CARD_MAIN, abilityButton(id, "HeroAbilList", false))
+			add(COMMAND_CARD_LEARN, abilityButton(id, "HeroAbilList", true))
+		}
+	}
+
+	if unit.UnitFunc != nil {
+		unitSources := []struct {
+			name  string
+			field null.String
+		}{{"Trains", unit.UnitFunc.Trains}, {"Upgrade", unit.UnitFunc.Upgrade}, {"Sellunits", unit.UnitFunc.Sellunits}}
+		for _, source := range unitSources {
+			for _, id := range splitObjectIds(source.field) {
+				add(COMMAND_CARD_MAIN, unitButton(id, source.name))
+			}
+		}
+
+		itemSources := []struct {
+			name  string
+			field null.String
+		}{{"Sellitems", unit.UnitFunc.Sellitems}, {"Makeitems", unit.UnitFunc.Makeitems}}
+		for _, source := range itemSources {
+			for _, id := range splitObjectIds(source.field) {
+				add(COMMAND_CARD_MAIN, itemButton(id, source.name))
+			}
+		}
+
+		for _, id := range splitObjectIds(unit.UnitFunc.Researches) {
+			add(COMMAND_CARD_MAIN, upgradeButton(id, "Researches"))
+		}
+
+		for _, id := range splitObjectIds(unit.UnitFunc.Builds) {
+			add(COMMAND_CARD_BUILD, unitButton(id, "Builds"))
+		}
+	}
+
+	return card
+}
+
+// findConflicts groups the buttons of a card that share a hotkey or a position, hotkeys are compared without
+// regard to case
+func findConflicts(buttons []*CommandButton, kind string) map[string][]*CommandButton {
+	groups := make(map[string][]*CommandButton)
+	for _, button := range buttons {
+		value := button.Position
+		if kind == CONFLICT_HOTKEY {
+			value = strings.ToUpper(button.Hotkey)
+		}
+
+		if value != "" {
+			groups[value] = append(groups[value], button)
+		}
+	}
+
+	for value, group := range groups {
+		if len(group) < 2 {
+			delete(groups, value)
+		}
+	}
+
+	return groups
+}
+
+// checkCommandCards reports the buttons that share a hotkey or a position on the command cards of the units,
+// every unit is checked when no ids are given
+func checkCommandCards(unitIds []string) []*CommandCardConflict {
+	if len(unitIds) < 1 {
+		unitIds = sortedObjectKeys(unitMap)
+	}
+
+	conflicts := []*CommandCardConflict{}
+	for _, unitId := range unitIds {
+		unit, ok := unitMap[unitId]
+		if !ok {
+			continue
+		}
+
+		var unitName string
+		if unit.UnitString != nil {
+			unitName = sylk.Unquote(unit.UnitString.Name.String)
+		}
+
+		card := getCommandCard(unitId)
+		for _, name := range commandCards {
+			for _, kind := range []string{CONFLICT_HOTKEY, CONFLICT_POSITION} {
+				groups := findConflicts(card[name], kind)
+
+				values := make([]string, 0, len(groups))
+				for value := range groups {
+					values = append(values, value)
+				}
+
+				sort.Strings(values)
+				for _, value := range values {
+					conflicts = append(conflicts, &CommandCardConflict{unitId, unitName, name, kind, value, groups[value]})
+				}
+			}
+		}
+	}
+
+	return conflicts
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/runi95/wts-parser/models"
+)
+
+func TestResearchesAreOnTheMainCard(t *testing.T) {
+	defer func(previous map[string]*models.SLKUnit) { unitMap = previous }(unitMap)
+	defer func(previous map[string]map[string]string) { upgradeMap = previous }(upgradeMap)
+
+	inputDirectory := t.TempDir()
+	files := map[string]string{
+		"HumanUpgradeFunc.txt":    "[Rhme]\r\nButtonpos=0,0\r\n\r\n[Rhra]\r\nbuttonpos=1,0\r\n",
+		"HumanUpgradeStrings.txt": "[Rhme]\r\nName=Iron Forged Swords,Steel Forged Swords\r\nHotkey=S,S\r\n\r\n[Rhra]\r\nName=Black Gunpowder\r\nHotkey=G\r\n",
+	}
+
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(inputDirectory, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loadUpgrades(inputDirectory)
+
+	blacksmith := newObject(reflect.TypeOf(models.SLKUnit{})).Interface().(*models.SLKUnit)
+	blacksmith.UnitFunc.Researches.SetValid("Rhme,Rhra,Rhxx")
+	unitMap = map[string]*models.SLKUnit{"hbla": blacksmith}
+
+	expected := []*CommandButton{
+		{Id: "Rhme", Category: CATEGORY_UPGRADES, Source: "Researches", Name: "Iron Forged Swords", Hotkey: "S", Position: "0,0"},
+		{Id: "Rhra", Category: CATEGORY_UPGRADES, Source: "Researches", Name: "Black Gunpowder", Hotkey: "G", Position: "1,0"},
+	}
+
+	card := getCommandCard("hbla")
+	if !reflect.DeepEqual(card[COMMAND_CARD_MAIN], expected) {
+		t.Errorf("got the buttons:")
+		for _, button := range card[COMMAND_CARD_MAIN] {
+			t.Errorf("%+v", button)
+		}
+	}
+
+	// A research sharing a hotkey with another button is a conflict like any other
+	upgradeMap["Rhra"]["hotkey"] = "S"
+	conflicts := checkCommandCards([]string{"hbla"})
+	if len(conflicts) != 1 || conflicts[0].Kind != CONFLICT_HOTKEY || conflicts[0].Value != "S" {
+		t.Errorf("got %d conflicts", len(conflicts))
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+const (
+	CATEGORY_DESTRUCTABLES       = "destructables"
+	CATEGORY_WORLD_EDIT_STRINGS  = "worldeditstrings"
+	DESTRUCTABLE_DATA_FILENAME   = "DestructableData.slk"
+	WORLD_EDIT_STRINGS_FILENAME  = "WorldEditStrings.txt"
+	WORLD_EDIT_STRINGS_PREFIX    = "WESTRING_"
+	DEFAULT_BASE_DESTRUCTABLE_ID = "LTlt"
+)
+
+var (
+	errDestructablesNotLoaded = fmt.Errorf("destructables have not been loaded yet")
+
+	// destructableMap stays nil until the input folder has been loaded
+	destructableMap            map[string]*SLKDestructable
+	baseDestructableMap        = make(map[string]*SLKDestructable)
+	lastValidDestructableIndex int
+	dirtyDestructables         = make(map[string]bool)
+	worldEditStrings           = make(map[string]string)
+	baseWorldEditStrings       = make(map[string]string)
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type SLKDestructable struct {
+	DestructableID    null.String `slk:"DestructableID"`
+	Category          null.String `slk:"category"`
+	Tilesets          null.String `slk:"tilesets"`
+	TilesetSpecific   null.String `slk:"tilesetSpecific"`
+	File              null.String `slk:"file"`
+	Lightweight       null.String `slk:"lightweight"`
+	FatLOD            null.String `slk:"fatLOD"`
+	TexID             null.String `slk:"texID"`
+	TexFile           null.String `slk:"texFile"`
+	Comment           null.String `slk:"comment"`
+	Name              null.String `slk:"Name"`
+	DoodClass         null.String `slk:"doodClass"`
+	UseClickHelper    null.String `slk:"useClickHelper"`
+	OnCliffs          null.String `slk:"onCliffs"`
+	OnWater           null.String `slk:"onWater"`
+	CanPlaceDead      null.String `slk:"canPlaceDead"`
+	Walkable          null.String `slk:"walkable"`
+	CliffHeight       null.String `slk:"cliffHeight"`
+	TargType          null.String `slk:"targType"`
+	Armor             null.String `slk:"armor"`
+	NumVar            null.String `slk:"numVar"`
+	HP                null.String `slk:"HP"`
+	OccH              null.String `slk:"occH"`
+	FlyH              null.String `slk:"flyH"`
+	FixedRot          null.String `slk:"fixedRot"`
+	SelSize           null.String `slk:"selSize"`
+	MinScale          null.String `slk:"minScale"`
+	MaxScale          null.String `slk:"maxScale"`
+	CanPlaceRandScale null.String `slk:"canPlaceRandScale"`
+	MaxPitch          null.String `slk:"maxPitch"`
+	MaxRoll           null.String `slk:"maxRoll"`
+	Radius            null.String `slk:"radius"`
+	FogRadius         null.String `slk:"fogRadius"`
+	FogVis            null.String `slk:"fogVis"`
+	PathTex           null.String `slk:"pathTex"`
+	PathTexDeath      null.String `slk:"pathTexDeath"`
+	DeathSnd          null.String `slk:"deathSnd"`
+	Shadow            null.String `slk:"shadow"`
+	ShowInMM          null.String `slk:"showInMM"`
+	UseMMColor        null.String `slk:"useMMColor"`
+	MMRed             null.String `slk:"MMRed"`
+	MMGreen           null.String `slk:"MMGreen"`
+	MMBlue            null.String `slk:"MMBlue"`
+	InBeta            null.String `slk:"InBeta"`
+}
+
+type NewDestructable struct {
+	DestructableId     null.String
+	GenerateId         bool
+	Name               string
+	BaseDestructableId null.String
+}
+
+// loadDestructables reads the destructables of the input folder, WorldEditStrings.txt is only used to
+// show proper names for destructables that reference the World Editor strings
+func loadDestructables(inputDirectory string, destructableDataFileInfo *FileInfo, worldEditStringsFileInfo *FileInfo) {
+	destructableMap = make(map[string]*SLKDestructable)
+
+	if fileBytes := readInputFile(inputDirectory, destructableDataFileInfo); fileBytes != nil {
+		log.Println("Parsing destructableDataBytes...")
+		if err := populateObjectMapWithSlkFileData(fileBytes, destructableMap); err != nil {
+			log.Println(err)
+		} else {
+			destructableDataFileInfo.StatusClass = "text-success"
+			destructableDataFileInfo.StatusIconClass = "fa-check"
+		}
+	}
+
+	var inputStrings map[string]string
+	if fileBytes := readInputFile(inputDirectory, worldEditStringsFileInfo); fileBytes != nil {
+		log.Println("Parsing worldEditStringsBytes...")
+		worldEditStringsFileInfo.StatusClass = "text-success"
+		worldEditStringsFileInfo.StatusIconClass = "fa-check"
+		inputStrings = readWorldEditStrings(fileBytes)
+	}
+
+	applyWorldEditStrings(inputStrings)
+}
+
+// loadBaseDestructables reads the destructables that come with the game from the base data
+func loadBaseDestructables(dataDirectory string) {
+	baseDestructableMap = make(map[string]*SLKDestructable)
+	baseWorldEditStrings = make(map[string]string)
+
+	if fileBytes, err := ioutil.ReadFile(filepath.Join(dataDirectory, DESTRUCTABLE_DATA_FILENAME)); err == nil {
+		if err = populateObjectMapWithSlkFileData(fileBytes, baseDestructableMap); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if fileBytes, err := ioutil.ReadFile(filepath.Join(dataDirectory, WORLD_EDIT_STRINGS_FILENAME)); err == nil {
+		baseWorldEditStrings = readWorldEditStrings(fileBytes)
+	}
+
+	applyWorldEditStrings(nil)
+}
+
+// readInputFile reads the file described by fileInfo from the input folder regardless of its casing
+func readInputFile(inputDirectory string, fileInfo *FileInfo) []byte {
+	filesInDirectory, err := ioutil.ReadDir(inputDirectory)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	for _, file := range filesInDirectory {
+		if !strings.EqualFold(file.Name(), fileInfo.FileName) {
+			continue
+		}
+
+		log.Printf("Reading %s...\n", fileInfo.FileName)
+		fileBytes, err := ioutil.ReadFile(filepath.Join(inputDirectory, file.Name()))
+		if err != nil {
+			log.Println(err)
+			return nil
+		}
+
+		return fileBytes
+	}
+
+	return nil
+}
+
+func readWorldEditStrings(inputFileData []byte) map[string]string {
+	strings := make(map[string]string)
+	for _, section := range readTxtSections(inputFileData) {
+		for key, value := range section {
+			strings[key] = value
+		}
+	}
+
+	return strings
+}
+
+// applyWorldEditStrings puts the strings of the input folder on top of the ones of the base data
+func applyWorldEditStrings(inputStrings map[string]string) {
+	worldEditStrings = make(map[string]string, len(baseWorldEditStrings)+len(inputStrings))
+	for key, value := range baseWorldEditStrings {
+		worldEditStrings[key] = value
+	}
+
+	for key, value := range inputStrings {
+		worldEditStrings[key] = value
+	}
+}
+
+// resolveWorldEditString returns the text behind a WESTRING_ reference or the value itself for plain text
+func resolveWorldEditString(value string) string {
+	text := sylk.Unquote(value)
+	if strings.HasPrefix(text, WORLD_EDIT_STRINGS_PREFIX) {
+		if resolved, ok := worldEditStrings[text]; ok {
+			return sylk.Unquote(resolved)
+		}
+	}
+
+	return text
+}
+
+func getNextValidDestructableId(offset int) string {
+	str, ok := generatedId("B", offset)
+	if !ok {
+		log.Println("Ran out of valid generated destructable id's")
+		return ""
+	}
+
+	_, isCustom := destructableMap[str]
+	_, isBase := baseDestructableMap[str]
+	if !isCustom && !isBase {
+		lastValidDestructableIndex = offset
+		return str
+	}
+
+	return getNextValidDestructableId(offset + 1)
+}
+
+// createNewDestructable clones a custom or base destructable, the gate everyone starts out with
+// is used when no base destructable has been given
+func createNewDestructable(newDestructable *NewDestructable) (*SLKDestructable, error) {
+	if destructableMap == nil {
+		return nil, errDestructablesNotLoaded
+	}
+
+	baseDestructableId := DEFAULT_BASE_DESTRUCTABLE_ID
+	if newDestructable.BaseDestructableId.Valid && newDestructable.BaseDestructableId.String != "" {
+		baseDestructableId = newDestructable.BaseDestructableId.String
+	}
+
+	var destructable *SLKDestructable
+	if base, ok := destructableMap[baseDestructableId]; ok {
+		destructable = cloneObject(base).(*SLKDestructable)
+	} else if base, ok := baseDestructableMap[baseDestructableId]; ok {
+		destructable = cloneObject(base).(*SLKDestructable)
+	} else if newDestructable.BaseDestructableId.Valid {
+		return nil, fmt.Errorf("base destructable %s does not exist", baseDestructableId)
+	} else {
+		destructable = new(SLKDestructable)
+	}
+
+	var destructableId string
+	if newDestructable.GenerateId == true || !newDestructable.DestructableId.Valid {
+		destructableId = getNextValidDestructableId(lastValidDestructableIndex)
+	} else {
+		destructableId = newDestructable.DestructableId.String
+	}
+
+	if destructableId == "" {
+		return nil, fmt.Errorf("could not generate a destructable id")
+	}
+
+	if _, ok := destructableMap[destructableId]; ok {
+		return nil, fmt.Errorf("destructable %s already exists", destructableId)
+	}
+
+	destructable.DestructableID.SetValid(destructableId)
+	destructable.Name.SetValid(sylk.Quote(newDestructable.Name))
+
+	destructableMap[destructableId] = destructable
+	markDirty(CATEGORY_DESTRUCTABLES, destructableId)
+
+	return destructable, nil
+}
+
+func loadDestructableListData() []ListData {
+	var destructableListData = make([]ListData, len(destructableMap))
+
+	i := 0
+	for k, v := range destructableMap {
+		destructableListData[i] = ListData{k, resolveWorldEditString(v.Name.String), null.String{}}
+		i++
+	}
+
+	return destructableListData
+}
+
+func saveDestructablesToFile(location string) error {
+	return writeObjectsToSlkFile(destructableMap, filepath.Join(location, DESTRUCTABLE_DATA_FILENAME), CATEGORY_DESTRUCTABLES)
+}
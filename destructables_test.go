@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestGetNextValidDestructableIdRunsOut(t *testing.T) {
+	defer func(previous map[string]*SLKDestructable) { destructableMap = previous }(destructableMap)
+	defer func(previous map[string]*SLKDestructable) { baseDestructableMap = previous }(baseDestructableMap)
+	defer func(previous int) { lastValidDestructableIndex = previous }(lastValidDestructableIndex)
+
+	destructableMap = map[string]*SLKDestructable{"BFFE": {}}
+	baseDestructableMap = map[string]*SLKDestructable{}
+
+	if id := getNextValidDestructableId(4094); id != "BFFF" {
+		t.Errorf("got %q, expected BFFF", id)
+	}
+
+	destructableMap["BFFF"] = &SLKDestructable{}
+	if id := getNextValidDestructableId(4094); id != "" {
+		t.Errorf("got %q once every id was taken", id)
+	}
+}
+
+func TestCreateNewDestructableBeforeLoading(t *testing.T) {
+	defer func(previous map[string]*SLKDestructable) { destructableMap = previous }(destructableMap)
+
+	destructableMap = nil
+	if _, err := createNewDestructable(&NewDestructable{Name: "Gate"}); err != errDestructablesNotLoaded {
+		t.Errorf("got %v, expected %v", err, errDestructablesNotLoaded)
+	}
+}
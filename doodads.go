@@ -0,0 +1,431 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+const (
+	CATEGORY_DOODADS          = "doodads"
+	DOODAD_DATA_FILENAME      = "Doodads.slk"
+	DOODAD_FUNC_FILENAME      = "DoodadFunc.txt"
+	DOODAD_STRINGS_FILENAME   = "DoodadStrings.txt"
+	DOODAD_META_DATA_FILENAME = "DoodadMetaData.slk"
+	DOODAD_META_DATA_SLK      = "DoodadData"
+	DEFAULT_BASE_DOODAD_ID    = "APms"
+	MAX_DOODAD_VARIATIONS     = 10
+)
+
+var (
+	errDoodadsNotLoaded = fmt.Errorf("doodads have not been loaded yet")
+
+	// doodadMap stays nil until the input folder has been loaded
+	doodadMap            map[string]*SLKDoodad
+	baseDoodadMap        = make(map[string]*SLKDoodad)
+	doodadMetaDataMap    = make(map[string]*DoodadMetaData)
+	lastValidDoodadIndex int
+	dirtyDoodads         = make(map[string]bool)
+
+	// The keys every doodad got from the TXT files by file name, those are written back to the same file
+	doodadTxtKeys = make(map[string]map[string][]string)
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type SLKDoodad struct {
+	DoodID            null.String `slk:"doodID"`
+	Category          null.String `slk:"category"`
+	Tilesets          null.String `slk:"tilesets"`
+	TilesetSpecific   null.String `slk:"tilesetSpecific"`
+	File              null.String `slk:"file"`
+	Comment           null.String `slk:"comment"`
+	Name              null.String `slk:"Name"`
+	DoodClass         null.String `slk:"doodClass"`
+	SoundLoop         null.String `slk:"soundLoop"`
+	SelSize           null.String `slk:"selSize"`
+	DefScale          null.String `slk:"defScale"`
+	MinScale          null.String `slk:"minScale"`
+	MaxScale          null.String `slk:"maxScale"`
+	CanPlaceRandScale null.String `slk:"canPlaceRandScale"`
+	UseClickHelper    null.String `slk:"useClickHelper"`
+	IgnoreModelClick  null.String `slk:"ignoreModelClick"`
+	MaxPitch          null.String `slk:"maxPitch"`
+	MaxRoll           null.String `slk:"maxRoll"`
+	VisRadius         null.String `slk:"visRadius"`
+	Walkable          null.String `slk:"walkable"`
+	NumVar            null.String `slk:"numVar"`
+	OnCliffs          null.String `slk:"onCliffs"`
+	OnWater           null.String `slk:"onWater"`
+	Floats            null.String `slk:"floats"`
+	Shadow            null.String `slk:"shadow"`
+	ShowInFog         null.String `slk:"showInFog"`
+	AnimInFog         null.String `slk:"animInFog"`
+	FixedRot          null.String `slk:"fixedRot"`
+	PathTex           null.String `slk:"pathTex"`
+	ShowInMM          null.String `slk:"showInMM"`
+	UseMMColor        null.String `slk:"useMMColor"`
+	MMRed             null.String `slk:"MMRed"`
+	MMGreen           null.String `slk:"MMGreen"`
+	MMBlue            null.String `slk:"MMBlue"`
+	VertR01           null.String `slk:"vertR01"`
+	VertG01           null.String `slk:"vertG01"`
+	VertB01           null.String `slk:"vertB01"`
+	VertR02           null.String `slk:"vertR02"`
+	VertG02           null.String `slk:"vertG02"`
+	VertB02           null.String `slk:"vertB02"`
+	VertR03           null.String `slk:"vertR03"`
+	VertG03           null.String `slk:"vertG03"`
+	VertB03           null.String `slk:"vertB03"`
+	VertR04           null.String `slk:"vertR04"`
+	VertG04           null.String `slk:"vertG04"`
+	VertB04           null.String `slk:"vertB04"`
+	VertR05           null.String `slk:"vertR05"`
+	VertG05           null.String `slk:"vertG05"`
+	VertB05           null.String `slk:"vertB05"`
+	VertR06           null.String `slk:"vertR06"`
+	VertG06           null.String `slk:"vertG06"`
+	VertB06           null.String `slk:"vertB06"`
+	VertR07           null.String `slk:"vertR07"`
+	VertG07           null.String `slk:"vertG07"`
+	VertB07           null.String `slk:"vertB07"`
+	VertR08           null.String `slk:"vertR08"`
+	VertG08           null.String `slk:"vertG08"`
+	VertB08           null.String `slk:"vertB08"`
+	VertR09           null.String `slk:"vertR09"`
+	VertG09           null.String `slk:"vertG09"`
+	VertB09           null.String `slk:"vertB09"`
+	VertR10           null.String `slk:"vertR10"`
+	VertG10           null.String `slk:"vertG10"`
+	VertB10           null.String `slk:"vertB10"`
+	UserList          null.String `slk:"UserList"`
+	InBeta            null.String `slk:"InBeta"`
+	Version           null.String `slk:"version"`
+}
+
+type DoodadMetaData struct {
+	ID          null.String `slk:"ID"`
+	Field       null.String `slk:"field"`
+	Slk         null.String `slk:"slk"`
+	Index       null.String `slk:"index"`
+	Repeat      null.String `slk:"repeat"`
+	Category    null.String `slk:"category"`
+	DisplayName null.String `slk:"displayName"`
+	Sort        null.String `slk:"sort"`
+	Type        null.String `slk:"type"`
+	ChangeFlags null.String `slk:"changeFlags"`
+	ImportType  null.String `slk:"importType"`
+	StringExt   null.String `slk:"stringExt"`
+	CaseSens    null.String `slk:"caseSens"`
+	CanBeEmpty  null.String `slk:"canBeEmpty"`
+	MinVal      null.String `slk:"minVal"`
+	MaxVal      null.String `slk:"maxVal"`
+	ForceNonNeg null.String `slk:"forceNonNeg"`
+	Version     null.String `slk:"version"`
+	Section     null.String `slk:"section"`
+}
+
+type NewDoodad struct {
+	DoodadId     null.String
+	GenerateId   bool
+	Name         string
+	BaseDoodadId null.String
+}
+
+// DoodadVariation holds the vertex color of a single model variation, a doodad uses the first numVar of them
+type DoodadVariation struct {
+	Index int
+	Used  bool
+	Red   null.String
+	Green null.String
+	Blue  null.String
+}
+
+type FieldIssue struct {
+	Field   string
+	Value   string
+	Message string
+}
+
+type DoodadValidation struct {
+	DoodadID string
+	Issues   []*FieldIssue
+}
+
+// loadDoodads reads the doodads of the input folder, the TXT files are optional and add to what the SLK says
+func loadDoodads(inputDirectory string, doodadDataFileInfo *FileInfo, txtFileInfos ...*FileInfo) {
+	doodadMap = make(map[string]*SLKDoodad)
+	doodadTxtKeys = make(map[string]map[string][]string)
+
+	if fileBytes := readInputFile(inputDirectory, doodadDataFileInfo); fileBytes != nil {
+		log.Println("Parsing doodadDataBytes...")
+		if err := populateObjectMapWithSlkFileData(fileBytes, doodadMap); err != nil {
+			log.Println(err)
+		} else {
+			doodadDataFileInfo.StatusClass = "text-success"
+			doodadDataFileInfo.StatusIconClass = "fa-check"
+		}
+	}
+
+	for _, fileInfo := range txtFileInfos {
+		if fileBytes := readInputFile(inputDirectory, fileInfo); fileBytes != nil {
+			log.Printf("Parsing %s...\n", fileInfo.FileName)
+			fileInfo.StatusClass = "text-success"
+			fileInfo.StatusIconClass = "fa-check"
+			doodadTxtKeys[fileInfo.FileName] = populateObjectMapWithTxtFileData(fileBytes, doodadMap)
+		}
+	}
+}
+
+// loadBaseDoodads reads the doodads that come with the game and the meta data used to validate doodads
+func loadBaseDoodads(dataDirectory string) {
+	baseDoodadMap = make(map[string]*SLKDoodad)
+	doodadMetaDataMap = make(map[string]*DoodadMetaData)
+
+	if fileBytes, err := ioutil.ReadFile(filepath.Join(dataDirectory, DOODAD_DATA_FILENAME)); err == nil {
+		if err = populateObjectMapWithSlkFileData(fileBytes, baseDoodadMap); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if fileBytes, err := ioutil.ReadFile(filepath.Join(dataDirectory, DOODAD_META_DATA_FILENAME)); err == nil {
+		if err = populateObjectMapWithSlkFileData(fileBytes, doodadMetaDataMap); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func getNextValidDoodadId(offset int) string {
+	str, ok := generatedId("D", offset)
+	if !ok {
+		log.Println("Ran out of valid generated doodad id's")
+		return ""
+	}
+
+	_, isCustom := doodadMap[str]
+	_, isBase := baseDoodadMap[str]
+	if !isCustom && !isBase {
+		lastValidDoodadIndex = offset
+		return str
+	}
+
+	return getNextValidDoodadId(offset + 1)
+}
+
+// createNewDoodad clones a custom or base doodad, custom doodads take the keys they had in the TXT files along
+func createNewDoodad(newDoodad *NewDoodad) (*SLKDoodad, error) {
+	if doodadMap == nil {
+		return nil, errDoodadsNotLoaded
+	}
+
+	baseDoodadId := DEFAULT_BASE_DOODAD_ID
+	if newDoodad.BaseDoodadId.Valid && newDoodad.BaseDoodadId.String != "" {
+		baseDoodadId = newDoodad.BaseDoodadId.String
+	}
+
+	var doodad *SLKDoodad
+	if base, ok := doodadMap[baseDoodadId]; ok {
+		doodad = cloneObject(base).(*SLKDoodad)
+	} else if base, ok := baseDoodadMap[baseDoodadId]; ok {
+		doodad = cloneObject(base).(*SLKDoodad)
+	} else if newDoodad.BaseDoodadId.Valid {
+		return nil, fmt.Errorf("base doodad %s does not exist", baseDoodadId)
+	} else {
+		doodad = new(SLKDoodad)
+	}
+
+	var doodadId string
+	if newDoodad.GenerateId == true || !newDoodad.DoodadId.Valid {
+		doodadId = getNextValidDoodadId(lastValidDoodadIndex)
+	} else {
+		doodadId = newDoodad.DoodadId.String
+	}
+
+	if doodadId == "" {
+		return nil, fmt.Errorf("could not generate a doodad id")
+	}
+
+	if _, ok := doodadMap[doodadId]; ok {
+		return nil, fmt.Errorf("doodad %s already exists", doodadId)
+	}
+
+	doodad.DoodID.SetValid(doodadId)
+	doodad.Name.SetValid(sylk.Quote(newDoodad.Name))
+
+	for _, keys := range doodadTxtKeys {
+		if baseKeys, ok := keys[baseDoodadId]; ok {
+			keys[doodadId] = append([]string{}, baseKeys...)
+		}
+	}
+
+	doodadMap[doodadId] = doodad
+	markDirty(CATEGORY_DOODADS, doodadId)
+
+	return doodad, nil
+}
+
+func loadDoodadListData() []ListData {
+	var doodadListData = make([]ListData, len(doodadMap))
+
+	i := 0
+	for k, v := range doodadMap {
+		doodadListData[i] = ListData{k, resolveWorldEditString(v.Name.String), null.String{}}
+		i++
+	}
+
+	return doodadListData
+}
+
+// getDoodadVariations returns every variation a doodad can have, the ones beyond numVar aren't used by the game
+func getDoodadVariations(doodad *SLKDoodad) []*DoodadVariation {
+	numVar, err := strconv.Atoi(sylk.Unquote(doodad.NumVar.String))
+	if err != nil {
+		numVar = 1
+	}
+
+	value := reflect.ValueOf(doodad).Elem()
+	variations := make([]*DoodadVariation, MAX_DOODAD_VARIATIONS)
+	for i := range variations {
+		suffix := fmt.Sprintf("%02d", i+1)
+		variations[i] = &DoodadVariation{
+			Index: i + 1,
+			Used:  i < numVar,
+			Red:   value.FieldByName("VertR" + suffix).Interface().(null.String),
+			Green: value.FieldByName("VertG" + suffix).Interface().(null.String),
+			Blue:  value.FieldByName("VertB" + suffix).Interface().(null.String),
+		}
+	}
+
+	return variations
+}
+
+// validateDoodad checks the fields of a doodad against the types and limits in DoodadMetaData.slk
+func validateDoodad(doodadId string, doodad *SLKDoodad) *DoodadValidation {
+	validation := &DoodadValidation{DoodadID: doodadId, Issues: []*FieldIssue{}}
+
+	// The game data fills in every variation, only the ones the doodad actually uses are worth checking
+	numVar, err := strconv.Atoi(sylk.Unquote(doodad.NumVar.String))
+	if err != nil || numVar > MAX_DOODAD_VARIATIONS {
+		numVar = MAX_DOODAD_VARIATIONS
+	}
+
+	doodadType := reflect.TypeOf(*doodad)
+	doodadValue := reflect.ValueOf(doodad).Elem()
+	fields := make(map[string]string)
+	for _, column := range objectColumns(doodadType) {
+		fields[strings.ToLower(column.Name)] = doodadType.Field(column.Field).Name
+	}
+
+	for _, id := range sortedObjectKeys(doodadMetaDataMap) {
+		metaData := doodadMetaDataMap[id]
+		if sylk.Unquote(metaData.Slk.String) != DOODAD_META_DATA_SLK {
+			continue
+		}
+
+		column := sylk.Unquote(metaData.Field.String)
+		columns := []string{column}
+		if repeat, err := strconv.Atoi(metaData.Repeat.String); err == nil && repeat > 0 {
+			if repeat > numVar {
+				repeat = numVar
+			}
+
+			columns = make([]string, repeat)
+			for i := range columns {
+				columns[i] = fmt.Sprintf("%s%02d", column, i+1)
+			}
+		}
+
+		for _, column := range columns {
+			fieldName, ok := fields[strings.ToLower(column)]
+			if !ok {
+				continue
+			}
+
+			value := doodadValue.FieldByName(fieldName).Interface().(null.String)
+			if issue := validateDoodadField(metaData, value); issue != nil {
+				issue.Field = fieldName
+				validation.Issues = append(validation.Issues, issue)
+			}
+		}
+	}
+
+	return validation
+}
+
+func validateDoodadField(metaData *DoodadMetaData, value null.String) *FieldIssue {
+	text := sylk.Unquote(value.String)
+	if !isSetValue(value) {
+		fieldType := sylk.Unquote(metaData.Type.String)
+		if metaData.CanBeEmpty.String == "0" && (fieldType == "string" || fieldType == "model") && text != "-" {
+			return &FieldIssue{Value: value.String, Message: "can not be empty"}
+		}
+
+		return nil
+	}
+
+	switch sylk.Unquote(metaData.Type.String) {
+	case "bool":
+		if text != "0" && text != "1" {
+			return &FieldIssue{Value: value.String, Message: "has to be either 0 or 1"}
+		}
+	case "int", "real", "unreal":
+		var number float64
+		var err error
+		if sylk.Unquote(metaData.Type.String) == "int" {
+			var integer int
+			integer, err = strconv.Atoi(text)
+			number = float64(integer)
+		} else {
+			number, err = strconv.ParseFloat(text, 64)
+		}
+
+		if err != nil {
+			return &FieldIssue{Value: value.String, Message: fmt.Sprintf("has to be of type %s", sylk.Unquote(metaData.Type.String))}
+		}
+
+		if minVal, err := strconv.ParseFloat(sylk.Unquote(metaData.MinVal.String), 64); err == nil && number < minVal {
+			return &FieldIssue{Value: value.String, Message: fmt.Sprintf("can not be lower than %v", minVal)}
+		}
+
+		if maxVal, err := strconv.ParseFloat(sylk.Unquote(metaData.MaxVal.String), 64); err == nil && number > maxVal {
+			return &FieldIssue{Value: value.String, Message: fmt.Sprintf("can not be higher than %v", maxVal)}
+		}
+
+		if metaData.ForceNonNeg.String == "1" && number < 0 {
+			return &FieldIssue{Value: value.String, Message: "can not be negative"}
+		}
+	}
+
+	return nil
+}
+
+// isSetValue tells apart real values from the markers the game data uses for empty fields
+func isSetValue(value null.String) bool {
+	text := sylk.Unquote(value.String)
+
+	return value.Valid && text != "" && text != "_" && text != "-"
+}
+
+func saveDoodadsToFile(location string) error {
+	err := writeObjectsToSlkFile(doodadMap, filepath.Join(location, DOODAD_DATA_FILENAME), CATEGORY_DOODADS)
+	if err != nil {
+		return err
+	}
+
+	for fileName, keys := range doodadTxtKeys {
+		err = writeObjectsToTxtFile(doodadMap, keys, filepath.Join(location, fileName), CATEGORY_DOODADS)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
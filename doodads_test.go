@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestGetNextValidDoodadIdRunsOut(t *testing.T) {
+	defer func(previous map[string]*SLKDoodad) { doodadMap = previous }(doodadMap)
+	defer func(previous map[string]*SLKDoodad) { baseDoodadMap = previous }(baseDoodadMap)
+	defer func(previous int) { lastValidDoodadIndex = previous }(lastValidDoodadIndex)
+
+	doodadMap = map[string]*SLKDoodad{}
+	baseDoodadMap = map[string]*SLKDoodad{"DFFE": {}}
+
+	if id := getNextValidDoodadId(4094); id != "DFFF" {
+		t.Errorf("got %q, expected DFFF", id)
+	}
+
+	doodadMap["DFFF"] = &SLKDoodad{}
+	if id := getNextValidDoodadId(4094); id != "" {
+		t.Errorf("got %q once every id was taken", id)
+	}
+}
+
+func TestCreateNewDoodadBeforeLoading(t *testing.T) {
+	defer func(previous map[string]*SLKDoodad) { doodadMap = previous }(doodadMap)
+
+	doodadMap = nil
+	if _, err := createNewDoodad(&NewDoodad{Name: "Rock"}); err != errDoodadsNotLoaded {
+		t.Errorf("got %v, expected %v", err, errDoodadsNotLoaded)
+	}
+}
@@ -0,0 +1,703 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilectron"
+)
+
+const (
+	DOWNLOAD_FILENAME           = "temp.zip"
+	DOWNLOAD_ETAG_FILENAME      = "temp.zip.etag"
+	RESOURCE_CHECKSUMS_FILENAME = "resource-checksums.json"
+	DOWNLOAD_MAX_ATTEMPTS       = 5
+	DOWNLOAD_INITIAL_BACKOFF    = time.Second
+	DOWNLOAD_MAX_BACKOFF        = 30 * time.Second
+)
+
+var (
+	// Guards downloadCancel, cancelDownload can be called while startDownload is still running
+	downloadMutex  sync.Mutex
+	downloadCancel context.CancelFunc = nil
+
+	// How long retry waits after the first failure, it doubles after every failure after that
+	downloadInitialBackoff = DOWNLOAD_INITIAL_BACKOFF
+
+	errDownloadCancelled = fmt.Errorf("download cancelled")
+)
+
+/**
+*    PRIVATE STRUCTURES
+ */
+type resourceChecksum struct {
+	Size   int64
+	SHA256 string
+}
+
+// messageSender is where downloads report their progress, which is the window of the editor
+type messageSender interface {
+	SendMessage(message interface{}, callbacks ...astilectron.CallbackMessage) error
+}
+
+// startDownload makes sure the resource version stored at path is up to date with the given URL,
+// the download can be stopped at any point with cancelDownload and will resume where it left off the next time
+func startDownload(w *astilectron.Window, path string, url string, version *ResourceVersion) error {
+	return runCancellable(func(ctx context.Context) error {
+		return downloadResources(ctx, w, url, path, version)
+	})
+}
+
+// runCancellable runs a download or extraction that can be stopped with cancelDownload
+func runCancellable(task func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	downloadMutex.Lock()
+	downloadCancel = cancel
+	downloadMutex.Unlock()
+
+	defer func() {
+		downloadMutex.Lock()
+		downloadCancel = nil
+		downloadMutex.Unlock()
+
+		cancel()
+	}()
+
+	return task(ctx)
+}
+
+func cancelDownload() bool {
+	downloadMutex.Lock()
+	defer downloadMutex.Unlock()
+
+	if downloadCancel == nil {
+		return false
+	}
+
+	downloadCancel()
+	return true
+}
+
+// downloadResources downloads and extracts the archive of a resource version. The archive has to match the
+// SHA-256 pinned for the version or the one shipped for the default archive, otherwise the one the server
+// publishes. Archives without any are kept so that the download doesn't have to start over once their SHA-256
+// has been pinned
+func downloadResources(ctx context.Context, w messageSender, url string, path string, version *ResourceVersion) error {
+	w.SendMessage(EventMessage{"downloadStart", nil})
+
+	start := time.Now()
+
+	var headResp *http.Response
+	err := retry(ctx, "HEAD "+url, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return err
+		}
+
+		headResp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+
+		headResp.Body.Close()
+		if headResp.StatusCode != http.StatusOK {
+			return fmt.Errorf("HEAD %s returned %s", url, headResp.Status)
+		}
+
+		return nil
+	})
+	if err == errDownloadCancelled {
+		return err
+	} else if err != nil {
+		// We might just be offline, there's no need to fail if we've already got everything
+		if verifyErr := verifyExtractedResources(path, false); verifyErr == nil {
+			log.Printf("Using previously downloaded resources since %s could not be reached: %v\n", url, err)
+			return nil
+		}
+
+		return err
+	}
+
+	eTag := headResp.Header.Get("ETag")
+	size := headResp.ContentLength
+
+	if version.ETag != nil && *(version.ETag) == eTag {
+		err = verifyExtractedResources(path, false)
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("Extracted resources failed verification, downloading them again: %v\n", err)
+	}
+
+	expectedChecksum := pinnedChecksum(version)
+	if expectedChecksum == "" {
+		if defaultURL, defaultChecksum := defaultResourceArchive(); url == defaultURL {
+			expectedChecksum = defaultChecksum
+		}
+	}
+
+	if expectedChecksum == "" {
+		expectedChecksum = digestFromHeader(headResp.Header)
+	}
+
+	if expectedChecksum == "" {
+		expectedChecksum = fetchPublishedChecksum(ctx, url)
+	}
+
+	file := path + string(filepath.Separator) + DOWNLOAD_FILENAME
+
+	log.Printf("Download started for %s...\n", file)
+
+	w.SendMessage(EventMessage{"downloadTextUpdate", "Downloading..."})
+
+	offset := resumableOffset(path, eTag)
+	if offset > 0 {
+		log.Printf("Resuming download at %v bytes\n", offset)
+	}
+
+	err = retry(ctx, "GET "+url, func() error {
+		var err error
+		offset, err = downloadRange(ctx, w, url, file, eTag, offset, size)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	elapsed := time.Since(start)
+	log.Printf("Download completed in %s\n", elapsed)
+
+	w.SendMessage(EventMessage{"downloadTextUpdate", "Verifying..."})
+
+	checksum, _, err := fileChecksum(file)
+	if err != nil {
+		return err
+	}
+
+	if expectedChecksum == "" {
+		return fmt.Errorf("%s publishes no SHA-256 and none has been pinned for this resource version, pin %s if the downloaded archive can be trusted", url, checksum)
+	}
+
+	if !strings.EqualFold(checksum, expectedChecksum) {
+		removeDownload(path)
+		return fmt.Errorf("downloaded archive has SHA-256 %s but %s was expected", checksum, expectedChecksum)
+	}
+
+	err = extractResources(ctx, w, file, path, eTag, version)
+	if err != nil {
+		return err
+	}
+
+	w.SendMessage(EventMessage{"downloadTextUpdate", "Cleaning up..."})
+
+	return removeDownload(path)
+}
+
+// extractResources replaces the files of a resource version with the contents of a zip archive and sets eTag
+// as what is now in place, storing it is up to the caller. Every file is hashed while it's extracted and read
+// back from disk once everything has been written, later checks only compare file sizes
+func extractResources(ctx context.Context, w messageSender, file string, path string, eTag string, version *ResourceVersion) error {
+	w.SendMessage(EventMessage{"downloadTextUpdate", "Extracting..."})
+
+	unzipDestination := path + string(filepath.Separator) + RESOURCE_FILES_FOLDER
+
+	// Leftovers from a previous archive could be mistaken for the resource root
+	err := os.RemoveAll(unzipDestination)
+	if err != nil {
+		return err
+	}
+
+	os.MkdirAll(unzipDestination, os.ModePerm)
+
+	checksums, err := Unzip(ctx, w, file, unzipDestination)
+	if err != nil {
+		return err
+	}
+
+	checksumsInBytes, err := json.Marshal(checksums)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(path+string(filepath.Separator)+RESOURCE_CHECKSUMS_FILENAME, checksumsInBytes, 0644)
+	if err != nil {
+		return err
+	}
+
+	w.SendMessage(EventMessage{"downloadTextUpdate", "Verifying..."})
+
+	err = verifyExtractedResources(path, true)
+	if err != nil {
+		return err
+	}
+
+	// Only remember the ETag once everything has been extracted, otherwise a failed extraction would never be retried
+	version.ETag = &eTag
+
+	return nil
+}
+
+// retry calls attempt until it succeeds, waiting a little longer between every failure
+func retry(ctx context.Context, description string, attempt func() error) error {
+	backoff := downloadInitialBackoff
+
+	for i := 1; ; i++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return errDownloadCancelled
+		}
+
+		if i >= DOWNLOAD_MAX_ATTEMPTS {
+			return fmt.Errorf("%s failed after %d attempts: %w", description, i, err)
+		}
+
+		log.Printf("%s failed (attempt %d of %d), retrying in %s: %v\n", description, i, DOWNLOAD_MAX_ATTEMPTS, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return errDownloadCancelled
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > DOWNLOAD_MAX_BACKOFF {
+			backoff = DOWNLOAD_MAX_BACKOFF
+		}
+	}
+}
+
+// resumableOffset returns how much of a previous download can be reused, which is nothing unless it was
+// downloaded from the exact same ETag
+func resumableOffset(path string, eTag string) int64 {
+	file := path + string(filepath.Separator) + DOWNLOAD_FILENAME
+	eTagFile := path + string(filepath.Separator) + DOWNLOAD_ETAG_FILENAME
+
+	previousETag, err := ioutil.ReadFile(eTagFile)
+	if err == nil && eTag != "" && string(previousETag) == eTag {
+		if fileStat, err := os.Stat(file); err == nil {
+			return fileStat.Size()
+		}
+	}
+
+	removeDownload(path)
+
+	err = ioutil.WriteFile(eTagFile, []byte(eTag), 0644)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return 0
+}
+
+func removeDownload(path string) error {
+	err := os.Remove(path + string(filepath.Separator) + DOWNLOAD_FILENAME)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.Remove(path + string(filepath.Separator) + DOWNLOAD_ETAG_FILENAME)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// downloadRange downloads everything from offset and onwards, returning the offset it managed to reach
+func downloadRange(ctx context.Context, w messageSender, url string, file string, eTag string, offset int64, size int64) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return offset, err
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if eTag != "" {
+			req.Header.Set("If-Range", eTag)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return offset, err
+	}
+
+	defer resp.Body.Close()
+
+	var out *os.File
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			return 0, fmt.Errorf("server responded with range %q when we asked for bytes %d and onwards", resp.Header.Get("Content-Range"), offset)
+		}
+
+		out, err = os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	case http.StatusOK:
+		// The server either ignored the range or the file has changed, either way we have to start over
+		offset = 0
+		out, err = os.Create(file)
+	case http.StatusRequestedRangeNotSatisfiable:
+		if size >= 0 && offset >= size {
+			return offset, nil
+		}
+
+		return 0, fmt.Errorf("GET %s returned %s", url, resp.Status)
+	default:
+		return offset, fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+
+	if err != nil {
+		return offset, err
+	}
+
+	total := size
+	if total < 0 && resp.ContentLength >= 0 {
+		// If the HEAD request didn't receive any Content-Length header we'll have to grab it from the actual request
+		total = offset + resp.ContentLength
+	}
+
+	log.Printf("size(%v)\n", total)
+
+	done := make(chan struct{})
+	go SendDownloadProgressMessage(w, done, file, total)
+
+	n, err := io.Copy(out, resp.Body)
+	close(done)
+
+	offset += n
+
+	closeErr := out.Close()
+	if err != nil {
+		return offset, err
+	}
+
+	if closeErr != nil {
+		return offset, closeErr
+	}
+
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return offset, io.ErrUnexpectedEOF
+	}
+
+	return offset, nil
+}
+
+func contentRangeStart(contentRange string) (int64, bool) {
+	if !strings.HasPrefix(contentRange, "bytes ") {
+		return 0, false
+	}
+
+	dashIndex := strings.Index(contentRange, "-")
+	if dashIndex < 0 {
+		return 0, false
+	}
+
+	start, err := strconv.ParseInt(contentRange[len("bytes "):dashIndex], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return start, true
+}
+
+// digestFromHeader reads a SHA-256 Digest header (RFC 3230) and returns it hex encoded
+func digestFromHeader(header http.Header) string {
+	for _, digest := range strings.Split(header.Get("Digest"), ",") {
+		digest = strings.TrimSpace(digest)
+		if len(digest) < 8 || !strings.EqualFold(digest[:8], "sha-256=") {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(digest[8:])
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		return hex.EncodeToString(decoded)
+	}
+
+	return ""
+}
+
+// pinnedChecksum returns the SHA-256 the archive of a resource version has to have, if one has been pinned
+func pinnedChecksum(version *ResourceVersion) string {
+	if version == nil || version.SHA256 == nil {
+		return ""
+	}
+
+	return strings.ToLower(strings.TrimSpace(*version.SHA256))
+}
+
+// verifyArchive compares an archive against the SHA-256 pinned for its resource version
+func verifyArchive(file string, version *ResourceVersion) error {
+	expectedChecksum := pinnedChecksum(version)
+	if expectedChecksum == "" {
+		return nil
+	}
+
+	checksum, _, err := fileChecksum(file)
+	if err != nil {
+		return err
+	}
+
+	if checksum != expectedChecksum {
+		return fmt.Errorf("%s has SHA-256 %s but %s was expected", file, checksum, expectedChecksum)
+	}
+
+	return nil
+}
+
+// fetchPublishedChecksum looks for a sha256sum style file next to the archive, it's fine if there isn't one
+func fetchPublishedChecksum(ctx context.Context, url string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+".sha256", nil)
+	if err != nil {
+		return ""
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ""
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return ""
+	}
+
+	fields := strings.Fields(string(body))
+	if len(fields) < 1 || !isSHA256(fields[0]) {
+		return ""
+	}
+
+	return strings.ToLower(fields[0])
+}
+
+func isSHA256(value string) bool {
+	checksum, err := hex.DecodeString(value)
+	return err == nil && len(checksum) == sha256.Size
+}
+
+func fileChecksum(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	defer file.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, file)
+	if err != nil {
+		return "", n, err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), n, nil
+}
+
+// verifyExtractedResources compares the extracted resources against the checksums we wrote when extracting them.
+// Files are only hashed when hashFiles is set, hashing every model on every start would take too long
+func verifyExtractedResources(path string, hashFiles bool) error {
+	checksumsInBytes, err := ioutil.ReadFile(path + string(filepath.Separator) + RESOURCE_CHECKSUMS_FILENAME)
+	if err != nil {
+		return err
+	}
+
+	var checksums map[string]resourceChecksum
+	err = json.Unmarshal(checksumsInBytes, &checksums)
+	if err != nil {
+		return err
+	}
+
+	destination := path + string(filepath.Separator) + RESOURCE_FILES_FOLDER
+	for name, expected := range checksums {
+		filePath := filepath.Join(destination, filepath.FromSlash(name))
+
+		fileStat, err := os.Stat(filePath)
+		if err != nil {
+			return err
+		}
+
+		if fileStat.Size() != expected.Size {
+			return fmt.Errorf("%s is %d bytes but should be %d bytes", filePath, fileStat.Size(), expected.Size)
+		}
+
+		if !hashFiles {
+			continue
+		}
+
+		checksum, _, err := fileChecksum(filePath)
+		if err != nil {
+			return err
+		}
+
+		if checksum != expected.SHA256 {
+			return fmt.Errorf("%s has SHA-256 %s but %s was extracted", filePath, checksum, expected.SHA256)
+		}
+	}
+
+	return nil
+}
+
+func SendDownloadProgressMessage(w messageSender, done chan struct{}, path string, total int64) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			var size int64 = 1
+
+			fileStat, err := os.Stat(path)
+			if err != nil {
+				log.Println(err)
+			} else if fileStat.Size() > 0 {
+				size = fileStat.Size()
+			}
+
+			percent := float64(size) / float64(total) * 50
+
+			w.SendMessage(EventMessage{"downloadPercentUpdate", percent})
+		}
+
+		time.Sleep(time.Second)
+	}
+}
+
+func DirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return err
+	})
+	return size, err
+}
+
+func SendExtractionProgressMessage(w messageSender, done chan struct{}, path string, total int64) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+
+			size, err := DirSize(path)
+			if err != nil {
+				log.Println(err)
+			}
+
+			if size == 0 {
+				size = 1
+			}
+
+			percent := 50 + (float64(size) / float64(total) * 50)
+
+			w.SendMessage(EventMessage{"downloadPercentUpdate", percent})
+		}
+
+		time.Sleep(time.Second)
+	}
+}
+
+// Unzip will decompress a zip archive, moving all files and folders
+// within the zip file to an output directory. It returns the size and SHA-256 of every
+// extracted file, the zip reader has already checked each of them against their CRC-32
+func Unzip(ctx context.Context, w messageSender, src string, destination string) (map[string]resourceChecksum, error) {
+	checksums := make(map[string]resourceChecksum)
+
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return checksums, err
+	}
+
+	defer r.Close()
+
+	var totalSize int64
+	totalSize = 0
+
+	for _, f := range r.File {
+		totalSize += f.FileInfo().Size()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go SendExtractionProgressMessage(w, done, destination, totalSize)
+
+	for _, f := range r.File {
+		if ctx.Err() != nil {
+			return checksums, errDownloadCancelled
+		}
+
+		filePath := filepath.Join(destination, f.Name)
+
+		if !strings.HasPrefix(filePath, filepath.Clean(destination)+string(os.PathSeparator)) {
+			return checksums, fmt.Errorf("%s: illegal file path", filePath)
+		}
+
+		if f.FileInfo().IsDir() {
+			os.MkdirAll(filePath, os.ModePerm)
+			continue
+		}
+
+		if err = os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+			return checksums, err
+		}
+
+		outFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+		if err != nil {
+			return checksums, err
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			outFile.Close()
+			return checksums, err
+		}
+
+		hash := sha256.New()
+		n, err := io.Copy(io.MultiWriter(outFile, hash), rc)
+
+		// Close the file without defer to close before next iteration of loop
+		outFile.Close()
+		rc.Close()
+
+		if err != nil {
+			return checksums, fmt.Errorf("%s: %w", f.Name, err)
+		}
+
+		checksums[f.Name] = resourceChecksum{n, hex.EncodeToString(hash.Sum(nil))}
+	}
+
+	return checksums, nil
+}
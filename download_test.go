@@ -0,0 +1,402 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astilectron"
+)
+
+type testWindow struct{}
+
+func (w *testWindow) SendMessage(message interface{}, callbacks ...astilectron.CallbackMessage) error {
+	return nil
+}
+
+// resourceServer stands in for MODEL_DOWNLOAD_URL, it supports HEAD, Range and If-Range the way GitHub does
+type resourceServer struct {
+	*httptest.Server
+
+	mutex  sync.Mutex
+	ranges []string
+}
+
+func newResourceServer(t *testing.T, archive []byte, handler func(w http.ResponseWriter, r *http.Request) bool) *resourceServer {
+	server := &resourceServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/resources.zip" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			server.mutex.Lock()
+			server.ranges = append(server.ranges, r.Header.Get("Range"))
+			server.mutex.Unlock()
+		}
+
+		if handler != nil && handler(w, r) {
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "resources.zip", time.Time{}, bytes.NewReader(archive))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (server *resourceServer) url() string {
+	return server.URL + "/resources.zip"
+}
+
+func testArchive() []byte {
+	return bytes.Repeat([]byte("0123456789abcdef"), 4096)
+}
+
+func archiveChecksum(archive []byte) string {
+	checksum := sha256.Sum256(archive)
+	return hex.EncodeToString(checksum[:])
+}
+
+func TestDownloadRangeResumes(t *testing.T) {
+	archive := testArchive()
+	server := newResourceServer(t, archive, nil)
+
+	file := filepath.Join(t.TempDir(), DOWNLOAD_FILENAME)
+	if err := ioutil.WriteFile(file, archive[:1000], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	offset, err := downloadRange(context.Background(), &testWindow{}, server.url(), file, `"v1"`, 1000, int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if offset != int64(len(archive)) {
+		t.Errorf("offset = %d, want %d", offset, len(archive))
+	}
+
+	if len(server.ranges) != 1 || server.ranges[0] != "bytes=1000-" {
+		t.Errorf("ranges = %q, want [bytes=1000-]", server.ranges)
+	}
+
+	downloaded, _ := ioutil.ReadFile(file)
+	if !bytes.Equal(downloaded, archive) {
+		t.Error("the resumed download differs from the archive")
+	}
+}
+
+func TestDownloadRangeStartsOverWhenTheArchiveChanged(t *testing.T) {
+	archive := testArchive()
+	server := newResourceServer(t, archive, nil)
+
+	file := filepath.Join(t.TempDir(), DOWNLOAD_FILENAME)
+	if err := ioutil.WriteFile(file, []byte("part of an older archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// If-Range doesn't match so the server sends everything
+	offset, err := downloadRange(context.Background(), &testWindow{}, server.url(), file, `"v0"`, 24, int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if offset != int64(len(archive)) {
+		t.Errorf("offset = %d, want %d", offset, len(archive))
+	}
+
+	downloaded, _ := ioutil.ReadFile(file)
+	if !bytes.Equal(downloaded, archive) {
+		t.Error("the download differs from the archive")
+	}
+}
+
+func TestDownloadResourcesResumesAcrossAttempts(t *testing.T) {
+	defer func(backoff time.Duration) { downloadInitialBackoff = backoff }(downloadInitialBackoff)
+	downloadInitialBackoff = time.Millisecond
+
+	archive := testArchive()
+	failed := false
+	server := newResourceServer(t, archive, func(w http.ResponseWriter, r *http.Request) bool {
+		// The first GET is cut off halfway through
+		if r.Method != http.MethodGet || failed {
+			return false
+		}
+
+		failed = true
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", "65536")
+		w.WriteHeader(http.StatusOK)
+		w.Write(archive[:30000])
+
+		return true
+	})
+
+	path := t.TempDir()
+	checksum := "0000000000000000000000000000000000000000000000000000000000000000"
+	err := downloadResources(context.Background(), &testWindow{}, server.url(), path, &ResourceVersion{SHA256: &checksum})
+	if err == nil || !strings.Contains(err.Error(), "was expected") {
+		t.Fatalf("err = %v, want a checksum mismatch", err)
+	}
+
+	if len(server.ranges) != 2 || server.ranges[0] != "" || server.ranges[1] != "bytes=30000-" {
+		t.Errorf("ranges = %q, want the second attempt to resume at 30000", server.ranges)
+	}
+}
+
+func TestRetryBacksOff(t *testing.T) {
+	defer func(backoff time.Duration) { downloadInitialBackoff = backoff }(downloadInitialBackoff)
+	downloadInitialBackoff = 20 * time.Millisecond
+
+	attempts := 0
+	start := time.Now()
+	err := retry(context.Background(), "test", func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("unavailable")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+
+	// 20ms after the first failure and 40ms after the second
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("retried after %s, want at least 60ms", elapsed)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	defer func(backoff time.Duration) { downloadInitialBackoff = backoff }(downloadInitialBackoff)
+	downloadInitialBackoff = time.Millisecond
+
+	attempts := 0
+	err := retry(context.Background(), "test", func() error {
+		attempts++
+		return errors.New("unavailable")
+	})
+	if err == nil {
+		t.Fatal("retry succeeded")
+	}
+
+	if attempts != DOWNLOAD_MAX_ATTEMPTS {
+		t.Errorf("attempts = %d, want %d", attempts, DOWNLOAD_MAX_ATTEMPTS)
+	}
+}
+
+func TestDownloadResourcesChecksumMismatch(t *testing.T) {
+	archive := testArchive()
+	server := newResourceServer(t, archive, nil)
+
+	path := t.TempDir()
+	checksum := archiveChecksum([]byte("another archive"))
+	err := downloadResources(context.Background(), &testWindow{}, server.url(), path, &ResourceVersion{SHA256: &checksum})
+	if err == nil || !strings.Contains(err.Error(), "was expected") {
+		t.Fatalf("err = %v, want a checksum mismatch", err)
+	}
+
+	// A corrupt archive must not be resumed
+	if _, err = os.Stat(filepath.Join(path, DOWNLOAD_FILENAME)); !os.IsNotExist(err) {
+		t.Error("the corrupt archive was kept")
+	}
+
+	if _, err = os.Stat(filepath.Join(path, RESOURCE_FILES_FOLDER)); !os.IsNotExist(err) {
+		t.Error("the corrupt archive was extracted")
+	}
+}
+
+func TestDownloadResourcesPublishedDigestMismatch(t *testing.T) {
+	archive := testArchive()
+	server := newResourceServer(t, archive, func(w http.ResponseWriter, r *http.Request) bool {
+		// RFC 3230 digest of something else
+		w.Header().Set("Digest", "SHA-256=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=")
+		return false
+	})
+
+	err := downloadResources(context.Background(), &testWindow{}, server.url(), t.TempDir(), &ResourceVersion{})
+	if err == nil || !strings.Contains(err.Error(), "was expected") {
+		t.Fatalf("err = %v, want a checksum mismatch", err)
+	}
+}
+
+func TestDownloadResourcesWithoutChecksum(t *testing.T) {
+	archive := testArchive()
+	server := newResourceServer(t, archive, nil)
+
+	path := t.TempDir()
+	err := downloadResources(context.Background(), &testWindow{}, server.url(), path, &ResourceVersion{})
+	if err == nil || !strings.Contains(err.Error(), archiveChecksum(archive)) {
+		t.Fatalf("err = %v, want it to refuse the archive and name its SHA-256", err)
+	}
+
+	// The archive is kept so that pinning its SHA-256 doesn't mean downloading it again
+	downloaded, _ := ioutil.ReadFile(filepath.Join(path, DOWNLOAD_FILENAME))
+	if !bytes.Equal(downloaded, archive) {
+		t.Error("the downloaded archive wasn't kept")
+	}
+
+	if _, err = os.Stat(filepath.Join(path, RESOURCE_FILES_FOLDER)); !os.IsNotExist(err) {
+		t.Error("an archive without a checksum was extracted")
+	}
+}
+
+func TestCancelDownload(t *testing.T) {
+	archive := testArchive()
+	started := make(chan struct{})
+	server := newResourceServer(t, archive, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodGet {
+			return false
+		}
+
+		w.Header().Set("Content-Length", "65536")
+		w.WriteHeader(http.StatusOK)
+		w.Write(archive[:1000])
+		w.(http.Flusher).Flush()
+		close(started)
+
+		// Stall until the client gives up
+		<-r.Context().Done()
+
+		return true
+	})
+
+	path := t.TempDir()
+	result := make(chan error)
+	go func() {
+		result <- runCancellable(func(ctx context.Context) error {
+			return downloadResources(ctx, &testWindow{}, server.url(), path, &ResourceVersion{})
+		})
+	}()
+
+	<-started
+	if !cancelDownload() {
+		t.Fatal("there was no download to cancel")
+	}
+
+	select {
+	case err := <-result:
+		if err != errDownloadCancelled {
+			t.Errorf("err = %v, want %v", err, errDownloadCancelled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the download kept going after it was cancelled")
+	}
+
+	if cancelDownload() {
+		t.Error("the cancelled download is still registered")
+	}
+}
+
+func testResourceZip(t *testing.T, files map[string]string) string {
+	file := filepath.Join(t.TempDir(), "resources.zip")
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := zip.NewWriter(out)
+	for name, content := range files {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		entry.Write([]byte(content))
+	}
+
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func TestExtractResourcesHashesEveryFile(t *testing.T) {
+	file := testResourceZip(t, map[string]string{"data/UnitData.slk": "ID;PWXL;N;E\r\nE\r\n", "units/footman.mdx": "MDLX"})
+
+	path := t.TempDir()
+	version := &ResourceVersion{}
+	if err := extractResources(context.Background(), &testWindow{}, file, path, `"v1"`, version); err != nil {
+		t.Fatal(err)
+	}
+
+	if version.ETag == nil || *version.ETag != `"v1"` {
+		t.Errorf("ETag = %v, want \"v1\"", version.ETag)
+	}
+
+	// A file that was damaged on disk keeps its size, only hashing it finds out
+	model := filepath.Join(path, RESOURCE_FILES_FOLDER, "units", "footman.mdx")
+	if err := ioutil.WriteFile(model, []byte("MDLY"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifyExtractedResources(path, false); err != nil {
+		t.Errorf("err = %v, want file sizes to match", err)
+	}
+
+	if err := verifyExtractedResources(path, true); err == nil || !strings.Contains(err.Error(), "footman.mdx") {
+		t.Errorf("err = %v, want footman.mdx to fail its SHA-256", err)
+	}
+}
+
+func TestDownloadResourcesUsesTheShippedChecksum(t *testing.T) {
+	defer func(previous string) { defaultResourcesPinPath = previous }(defaultResourcesPinPath)
+
+	file := testResourceZip(t, map[string]string{"data/UnitData.slk": "ID;PWXL;N;E\r\nE\r\n"})
+	archive, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newResourceServer(t, archive, nil)
+
+	defaultResourcesPinPath = filepath.Join(t.TempDir(), "resources.sha256")
+	if err = ioutil.WriteFile(defaultResourcesPinPath, []byte(archiveChecksum(archive)+"  "+server.url()+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	version := &ResourceVersion{}
+	if source := getResourceSource(version); source.Location != server.url() {
+		t.Fatalf("source = %s, want the pinned archive %s", source.Location, server.url())
+	}
+
+	path := t.TempDir()
+	if err = downloadResources(context.Background(), &testWindow{}, server.url(), path, version); err != nil {
+		t.Fatalf("err = %v, want the archive to match the shipped SHA-256", err)
+	}
+
+	if version.ETag == nil || *version.ETag != `"v1"` {
+		t.Errorf("ETag = %v, want \"v1\"", version.ETag)
+	}
+
+	// The shipped SHA-256 only covers the archive it was written for
+	other := newResourceServer(t, archive, nil)
+	if err = downloadResources(context.Background(), &testWindow{}, other.url(), t.TempDir(), &ResourceVersion{}); err == nil {
+		t.Error("another archive was accepted with the shipped SHA-256")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	// The icon catalog is built from the texture index and rebuilt whenever that changes
+	iconCatalogKey string
+	iconCatalog    map[string]*iconEntry
+
+	// Converted icons by path on disk so that switching between objects doesn't decode the same icon again
+	iconCache = make(map[string]string)
+
+	iconPrefixes = []string{"btn", "pasbtn", "disbtn", "atc"}
+)
+
+/**
+*    PRIVATE STRUCTURES
+ */
+type iconEntry struct {
+	Name string
+	// Path is the path used by Art fields, like ReplaceableTextures\CommandButtons\BTNFootman.blp
+	Path string
+	// File is the path on disk
+	File string
+}
+
+// forgetIconCatalog makes the next icon lookup scan for icons again
+func forgetIconCatalog() {
+	forgetTextureIndex()
+	iconCatalog = nil
+	iconCache = make(map[string]string)
+}
+
+// getIconCatalog returns all icons found in the resources and the input folder by their lowercase Art path
+func getIconCatalog() (map[string]*iconEntry, error) {
+	textures, key, err := getTextureIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	if iconCatalog != nil && iconCatalogKey == key {
+		return iconCatalog, nil
+	}
+
+	catalog := make(map[string]*iconEntry)
+	for lowercasePath, texture := range textures {
+		fileName := filepath.Base(texture.File)
+		lowercaseName := strings.ToLower(fileName)
+
+		prefix := ""
+		for _, iconPrefix := range iconPrefixes {
+			if strings.HasPrefix(lowercaseName, iconPrefix) {
+				prefix = iconPrefix
+				break
+			}
+		}
+
+		// Custom icons can be named anything so every texture in the input folder is an icon candidate
+		if prefix == "" && !texture.Custom {
+			continue
+		}
+
+		name := fileName[len(prefix) : len(fileName)-len(".blp")]
+		if prefix == "disbtn" {
+			name += " (Disabled)"
+		} else if prefix == "pasbtn" {
+			name += " (Passive)"
+		}
+
+		catalog[lowercasePath] = &iconEntry{name, texture.Path, texture.File}
+	}
+
+	iconCatalog = catalog
+	iconCatalogKey = key
+
+	return iconCatalog, nil
+}
+
+// loadIcons returns the name and Art path of every known icon
+func loadIcons() (Models, error) {
+	catalog, err := getIconCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	iconModels := make(Models, 0, len(catalog))
+	for _, icon := range catalog {
+		iconModels = append(iconModels, Model{icon.Name, icon.Path})
+	}
+
+	sort.Sort(iconModels)
+
+	return iconModels, nil
+}
+
+// loadIcon converts the icon behind an Art value into a base64 encoded PNG, unknown icons give us an
+// empty string so that the placeholder icon is shown instead
+func loadIcon(art string) (string, error) {
+	// Art fields can hold one icon per level
+	path := strings.Trim(art, "\"")
+	if index := strings.Index(path, ","); index > -1 {
+		path = path[:index]
+	}
+
+	if path == "" {
+		return "", nil
+	}
+
+	icon, err := findTexture(path)
+	if err != nil {
+		return "", err
+	}
+
+	if icon == nil {
+		return "", nil
+	}
+
+	if encoded, ok := iconCache[icon.File]; ok {
+		return encoded, nil
+	}
+
+	encoded, err := readTexture(icon.File, true)
+	if err != nil {
+		return "", err
+	}
+
+	iconCache[icon.File] = encoded
+
+	return encoded, nil
+}
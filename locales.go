@@ -0,0 +1,508 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+const (
+	LOCALES_FOLDER       = "_Locales"
+	LOCALE_FOLDER_SUFFIX = ".w3mod"
+	DEFAULT_LOCALE       = "enUS"
+)
+
+var (
+	// The translations of the map by locale name. The default locale is the input folder itself, every other locale
+	// has a folder of its own under _Locales with string TXT files and a string table of its own
+	locales = make(map[string]*localeStrings)
+
+	// The files translations of objects go into when neither the locale nor the default locale has them in a file
+	localeFallbackFiles = map[string]string{
+		CATEGORY_UNITS:     "CampaignUnitStrings.txt",
+		CATEGORY_ITEMS:     "ItemStrings.txt",
+		CATEGORY_ABILITIES: "CampaignAbilityStrings.txt",
+	}
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type LocalizedObject struct {
+	Category string
+	Id       string
+}
+
+type LocalizedField struct {
+	Locale string
+	Id     string
+	Field  string
+	Value  string
+}
+
+type MissingTranslation struct {
+	Category string
+	Id       string
+	Name     string
+	Locale   string
+	Fields   []string
+}
+
+/**
+*    PRIVATE STRUCTURES
+ */
+type localeStrings struct {
+	Name   string
+	Folder string
+	Files  map[string]*txtDocument
+	Wts    *wtsDocument
+
+	// The sections of every file, rebuilt after keys have been added
+	sections map[string]map[string]*txtSection
+}
+
+func getDefaultLocale() string {
+	if configuration.DefaultLocale != "" {
+		return configuration.DefaultLocale
+	}
+
+	return DEFAULT_LOCALE
+}
+
+// getLocales returns the default locale followed by the other ones in alphabetical order
+func getLocales() []string {
+	return append([]string{getDefaultLocale()}, sortedObjectKeys(locales)...)
+}
+
+// loadLocales reads the string TXT files and the string table of every locale folder in the input folder
+func loadLocales(inputDirectory string) {
+	locales = make(map[string]*localeStrings)
+
+	files, err := ioutil.ReadDir(inputDirectory)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, file := range files {
+		if !file.IsDir() || !strings.EqualFold(file.Name(), LOCALES_FOLDER) {
+			continue
+		}
+
+		localeFolders, err := ioutil.ReadDir(filepath.Join(inputDirectory, file.Name()))
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		for _, localeFolder := range localeFolders {
+			if !localeFolder.IsDir() {
+				continue
+			}
+
+			name := localeFolder.Name()
+			if strings.HasSuffix(strings.ToLower(name), LOCALE_FOLDER_SUFFIX) {
+				name = name[:len(name)-len(LOCALE_FOLDER_SUFFIX)]
+			}
+
+			if strings.EqualFold(name, getDefaultLocale()) {
+				log.Printf("%s is the default locale, the strings of the input folder are used instead\n", localeFolder.Name())
+				continue
+			}
+
+			locale, err := readLocale(filepath.Join(inputDirectory, file.Name(), localeFolder.Name()), name, localeFolder.Name())
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+
+			locales[name] = locale
+		}
+	}
+}
+
+func readLocale(directory string, name string, folder string) (*localeStrings, error) {
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	locale := &localeStrings{Name: name, Folder: folder, Files: make(map[string]*txtDocument)}
+	for _, file := range files {
+		lowerName := strings.ToLower(file.Name())
+		if file.IsDir() || (lowerName != STRING_TABLE_FILENAME && !strings.HasSuffix(lowerName, "strings.txt")) {
+			continue
+		}
+
+		fileBytes, err := ioutil.ReadFile(filepath.Join(directory, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Parsing %s of %s...\n", file.Name(), name)
+		if lowerName == STRING_TABLE_FILENAME {
+			locale.Wts = parseWtsDocument(fileBytes)
+		} else {
+			locale.Files[file.Name()] = parseTxtDocument(fileBytes)
+		}
+	}
+
+	return locale, nil
+}
+
+// addLocale starts a locale without any translations, its folder is created the next time the objects are saved
+func addLocale(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\.`) {
+		return fmt.Errorf("invalid locale name %q", name)
+	}
+
+	if strings.EqualFold(name, getDefaultLocale()) {
+		return fmt.Errorf("%s is the default locale", name)
+	}
+
+	for existing := range locales {
+		if strings.EqualFold(existing, name) {
+			return fmt.Errorf("there already is a %s locale", existing)
+		}
+	}
+
+	locales[name] = &localeStrings{Name: name, Folder: name + LOCALE_FOLDER_SUFFIX, Files: make(map[string]*txtDocument)}
+
+	return nil
+}
+
+// localizedFields returns the fields of the category's strings part, those are the ones that get translated
+func localizedFields(category spreadsheetCategory) []reflect.StructField {
+	part, _ := reflect.TypeOf(category.Objects()).Elem().Elem().FieldByName(category.Prefix + "StringId")
+
+	var fields []reflect.StructField
+	for _, field := range category.fields() {
+		if field.Index[0] == part.Index[0] {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+func isLocalizedField(category spreadsheetCategory, name string) bool {
+	for _, field := range localizedFields(category) {
+		if field.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// find returns the file and the line a key of an object is on in the locale's TXT files
+func (locale *localeStrings) find(id string, key string) (*txtDocument, int, bool) {
+	if locale.sections == nil {
+		locale.sections = make(map[string]map[string]*txtSection)
+		for name, document := range locale.Files {
+			_, locale.sections[name] = document.sections()
+		}
+	}
+
+	for _, name := range sortedObjectKeys(locale.Files) {
+		if section, ok := locale.sections[name][id]; ok {
+			if line, ok := section.keys[strings.ToLower(key)]; ok {
+				return locale.Files[name], line, true
+			}
+		}
+	}
+
+	return nil, 0, false
+}
+
+// ownText looks a string up in the locale's string table
+func (locale *localeStrings) ownText(id int) (string, bool) {
+	if locale.Wts == nil {
+		return "", false
+	}
+
+	return locale.Wts.text(id)
+}
+
+// text looks a string up in the locale's string table and falls back to the one of the default locale
+func (locale *localeStrings) text(id int) (string, bool) {
+	if text, ok := locale.ownText(id); ok {
+		return text, true
+	}
+
+	if stringTable != nil {
+		return stringTable.text(id)
+	}
+
+	return "", false
+}
+
+// value returns the translation of a field, which is either in the locale's TXT files or in its string table
+// under the same reference the field has in the default locale. A reference to a string only the default locale
+// has returns that string but doesn't count as a translation
+func (locale *localeStrings) value(category string, id string, field string) (string, bool) {
+	if document, line, ok := locale.find(id, field); ok {
+		_, value := document.value(line)
+		if reference, ok := stringReferenceId(value); ok {
+			if text, ok := locale.ownText(reference); ok {
+				return text, text != ""
+			}
+
+			if text, ok := locale.text(reference); ok {
+				value = text
+			}
+
+			return value, false
+		}
+
+		return value, value != ""
+	}
+
+	if resolved, ok := stringReferences[stringReference{category, id, field}]; ok {
+		reference, _ := stringReferenceId(resolved.Raw)
+		text, ok := locale.ownText(reference)
+
+		return text, ok && text != ""
+	}
+
+	return "", false
+}
+
+// setValue changes a translation where it's kept, translations the locale doesn't have yet go into its string table
+// when the field refers to a string of the default locale and into its TXT files otherwise
+func (locale *localeStrings) setValue(category string, id string, field string, value string) {
+	if document, line, ok := locale.find(id, field); ok {
+		_, current := document.value(line)
+		if reference, ok := stringReferenceId(current); ok && locale.Wts != nil && locale.Wts.setText(reference, value) {
+			return
+		}
+
+		document.setValue(line, value)
+		return
+	}
+
+	if resolved, ok := stringReferences[stringReference{category, id, field}]; ok {
+		reference, _ := stringReferenceId(resolved.Raw)
+		if locale.Wts == nil {
+			locale.Wts = &wtsDocument{txtDocument: &txtDocument{}}
+		}
+
+		if !locale.Wts.setText(reference, value) {
+			locale.Wts.insert(reference, value)
+		}
+
+		return
+	}
+
+	if value == "" {
+		return
+	}
+
+	locale.addKey(category, id, field+"="+value)
+}
+
+// addKey adds a key to the section of the object, the section is created in the file the default locale has the
+// object in when none of the locale's files have it
+func (locale *localeStrings) addKey(category string, id string, line string) {
+	defer func() {
+		locale.sections = nil
+	}()
+
+	for _, name := range sortedObjectKeys(locale.Files) {
+		if _, sections := locale.Files[name].sections(); sections[id] != nil {
+			locale.Files[name].insertLines(sections[id].lastKey+1, line)
+			return
+		}
+	}
+
+	fileName := localeFallbackFiles[category]
+	for _, name := range sortedObjectKeys(sourceFiles) {
+		source := sourceFiles[name]
+		if source.Category != category || source.Txt == nil || !strings.HasSuffix(name, "strings.txt") {
+			continue
+		}
+
+		if _, sections := source.Txt.sections(); sections[id] != nil {
+			fileName = inputFileName(source.Name)
+			break
+		}
+	}
+
+	var document *txtDocument
+	for name, existing := range locale.Files {
+		if strings.EqualFold(name, fileName) {
+			document = existing
+		}
+	}
+
+	if document == nil {
+		document = &txtDocument{}
+		locale.Files[fileName] = document
+	}
+
+	lines := []string{"[" + id + "]", line}
+	if last := len(document.lines) - 1; last >= 0 && strings.TrimSpace(document.lines[last]) != "" {
+		lines = append([]string{""}, lines...)
+	}
+
+	document.insertLines(len(document.lines), lines...)
+}
+
+// getLocalizedStrings returns the strings of an object by locale and field
+func getLocalizedStrings(object *LocalizedObject) (map[string]map[string]string, error) {
+	category, ok := getSpreadsheetCategory(object.Category)
+	if !ok {
+		return nil, fmt.Errorf("%s don't have localized strings", object.Category)
+	}
+
+	value := reflect.ValueOf(category.Objects()).MapIndex(reflect.ValueOf(object.Id))
+	if !value.IsValid() {
+		return nil, fmt.Errorf("%s does not exist", object.Id)
+	}
+
+	fields := localizedFields(category)
+	localized := make(map[string]map[string]string)
+
+	defaults := make(map[string]string)
+	for _, field := range fields {
+		defaults[field.Name], _ = fieldText(value.Elem(), field)
+	}
+
+	localized[getDefaultLocale()] = defaults
+	for name, locale := range locales {
+		values := make(map[string]string)
+		for _, field := range fields {
+			values[field.Name], _ = locale.value(category.Name, object.Id, field.Name)
+		}
+
+		localized[name] = values
+	}
+
+	return localized, nil
+}
+
+// saveLocalizedField sets a "Prefix-FieldName" field of an object in a single locale, false means there is no
+// object with the id
+func saveLocalizedField(localizedField *LocalizedField) (bool, error) {
+	if localizedField.Locale == "" || strings.EqualFold(localizedField.Locale, getDefaultLocale()) {
+		return applySaveField(&SaveField{Id: localizedField.Id, Field: localizedField.Field, Value: localizedField.Value})
+	}
+
+	locale, ok := locales[localizedField.Locale]
+	if !ok {
+		return false, fmt.Errorf("there is no %s locale", localizedField.Locale)
+	}
+
+	split := strings.Split(localizedField.Field, "-")
+	var category spreadsheetCategory
+	for _, candidate := range spreadsheetCategories {
+		if candidate.Prefix == split[0] {
+			category = candidate
+		}
+	}
+
+	if category.Name == "" || len(split) != 2 || !isLocalizedField(category, split[1]) {
+		return false, fmt.Errorf("%s is not a field that can be translated", localizedField.Field)
+	}
+
+	if !reflect.ValueOf(category.Objects()).MapIndex(reflect.ValueOf(localizedField.Id)).IsValid() {
+		return false, nil
+	}
+
+	locale.setValue(category.Name, localizedField.Id, split[1], localizedField.Value)
+
+	return true, nil
+}
+
+// getMissingTranslations lists the objects that have strings in the default locale that another locale doesn't
+// have, every locale an object is missing strings in gets an entry of its own
+func getMissingTranslations() []*MissingTranslation {
+	missing := []*MissingTranslation{}
+	for _, category := range spreadsheetCategories {
+		objects := reflect.ValueOf(category.Objects())
+		fields := localizedFields(category)
+		for _, id := range sortedObjectKeys(category.Objects()) {
+			object := objects.MapIndex(reflect.ValueOf(id)).Elem()
+
+			var name string
+			var translated []reflect.StructField
+			for _, field := range fields {
+				text, _ := fieldText(object, field)
+				if text == "" {
+					continue
+				}
+
+				if field.Name == "Name" {
+					name = text
+				}
+
+				translated = append(translated, field)
+			}
+
+			for _, localeName := range sortedObjectKeys(locales) {
+				var missingFields []string
+				for _, field := range translated {
+					if _, ok := locales[localeName].value(category.Name, id, field.Name); !ok {
+						missingFields = append(missingFields, field.Name)
+					}
+				}
+
+				if len(missingFields) > 0 {
+					missing = append(missing, &MissingTranslation{category.Name, id, name, localeName, missingFields})
+				}
+			}
+		}
+	}
+
+	return missing
+}
+
+// saveLocales writes every locale into a folder of its own under _Locales in the output folder
+func saveLocales(location string) error {
+	for _, name := range sortedObjectKeys(locales) {
+		locale := locales[name]
+		directory := filepath.Join(location, LOCALES_FOLDER, locale.Folder)
+		if err := os.MkdirAll(directory, os.ModePerm); err != nil {
+			return err
+		}
+
+		for fileName, document := range locale.Files {
+			if err := writeChangedFile(filepath.Join(directory, fileName), document.bytes()); err != nil {
+				return err
+			}
+		}
+
+		if locale.Wts != nil {
+			if err := writeChangedFile(filepath.Join(directory, STRING_TABLE_FILENAME), locale.Wts.bytes()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// inputFileName returns the name of a file the way it's written in the input folder, source files are remembered
+// by their lowercase name
+func inputFileName(name string) string {
+	if configuration.InDir == nil {
+		return name
+	}
+
+	files, err := ioutil.ReadDir(*configuration.InDir)
+	if err != nil {
+		return name
+	}
+
+	for _, file := range files {
+		if strings.EqualFold(file.Name(), name) {
+			return file.Name()
+		}
+	}
+
+	return name
+}
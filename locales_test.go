@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/runi95/wts-parser/models"
+)
+
+func TestMissingTranslationsIgnoreDefaultLocaleStrings(t *testing.T) {
+	defer useSpreadsheetObjects()()
+	defer func(previous map[string]*localeStrings) { locales = previous }(locales)
+	defer func(previous *wtsDocument) { stringTable = previous }(stringTable)
+
+	unitMap = map[string]*models.SLKUnit{"h000": newTestUnit("420")}
+	unitMap["h000"].UnitString.Name.SetValid("Footman")
+	itemMap = map[string]*models.SLKItem{}
+
+	stringTable = parseWtsDocument([]byte("STRING 1\r\n{\r\nFootman\r\n}\r\n"))
+	locales = map[string]*localeStrings{
+		// Refers to a string its own table doesn't have
+		"deDE": {Name: "deDE", Files: map[string]*txtDocument{"HumanUnitStrings.txt": parseTxtDocument([]byte("[h000]\r\nName=TRIGSTR_001\r\n"))}, Wts: parseWtsDocument([]byte("STRING 2\r\n{\r\nSchwert\r\n}\r\n"))},
+		"frFR": {Name: "frFR", Files: map[string]*txtDocument{"HumanUnitStrings.txt": parseTxtDocument([]byte("[h000]\r\nName=TRIGSTR_001\r\n"))}, Wts: parseWtsDocument([]byte("STRING 1\r\n{\r\nFantassin\r\n}\r\n"))},
+	}
+
+	// The untranslated string is still what the locale shows
+	if text, ok := locales["deDE"].value(CATEGORY_UNITS, "h000", "Name"); text != "Footman" || ok {
+		t.Errorf("got %q, %v, expected \"Footman\", false", text, ok)
+	}
+
+	if text, ok := locales["frFR"].value(CATEGORY_UNITS, "h000", "Name"); text != "Fantassin" || !ok {
+		t.Errorf("got %q, %v, expected \"Fantassin\", true", text, ok)
+	}
+
+	expected := []*MissingTranslation{{CATEGORY_UNITS, "h000", "Footman", "deDE", []string{"Name"}}}
+	if missing := getMissingTranslations(); !reflect.DeepEqual(missing, expected) {
+		t.Errorf("got:")
+		for _, translation := range missing {
+			t.Errorf("%+v", translation)
+		}
+	}
+}
@@ -25,10 +25,11 @@ var (
 
 // Application Vars
 var (
-	fs     = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
-	debug  = fs.Bool("d", false, "enables the debug mode")
-	input  = fs.String("input", "", "sets the input folder where the SLK files are stored")
-	output = fs.String("output", "", "sets the output folder where we'll save the resulting SLK files")
+	fs        = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	debug     = fs.Bool("d", false, "enables the debug mode")
+	input     = fs.String("input", "", "sets the input folder where the SLK files are stored")
+	output    = fs.String("output", "", "sets the output folder where we'll save the resulting SLK files")
+	resources = fs.String("resources", "", "sets where models and base data come from, either a URL, a zip file or an already extracted folder")
 
 	w *astilectron.Window
 )
@@ -37,6 +38,15 @@ func main() {
 	// Create logger
 	l := log.New(log.Writer(), log.Prefix(), log.Flags())
 
+	// Subcommands do their work without opening the editor
+	if len(os.Args) > 1 && os.Args[1] == GENERATE_CODE_COMMAND {
+		if err := runGenerateCodeCommand(os.Args[2:]); err != nil {
+			l.Fatal(err)
+		}
+
+		return
+	}
+
 	// Parse flags
 	fs.Parse(os.Args[1:])
 
@@ -0,0 +1,225 @@
+// Package mdx reads the header chunks of Warcraft III MDX models, geometry and animation tracks are skipped
+package mdx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+const (
+	MAGIC = "MDLX"
+
+	modelSize      = 372
+	sequenceSize   = 132
+	textureSize    = 268
+	nameLength     = 80
+	fileNameLength = 260
+)
+
+var ErrNotMdx = fmt.Errorf("not an mdx model")
+
+type Extent struct {
+	BoundsRadius float32
+	Minimum      [3]float32
+	Maximum      [3]float32
+}
+
+type Sequence struct {
+	Name       string
+	Start      uint32
+	End        uint32
+	MoveSpeed  float32
+	NonLooping bool
+	Rarity     float32
+	Extent     Extent
+}
+
+type Texture struct {
+	ReplaceableId uint32
+	Path          string
+	Flags         uint32
+}
+
+type Attachment struct {
+	Name         string
+	Path         string
+	AttachmentId uint32
+}
+
+type Model struct {
+	Version         uint32
+	Name            string
+	AnimationFile   string
+	Extent          Extent
+	BlendTime       uint32
+	Sequences       []*Sequence
+	GlobalSequences []uint32
+	Textures        []*Texture
+	Attachments     []*Attachment
+}
+
+// Read parses a complete MDX file
+func Read(r io.Reader) (*Model, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// Parse walks the chunks of an MDX file and fills in the ones we know about
+func Parse(data []byte) (*Model, error) {
+	if len(data) < 4 || string(data[:4]) != MAGIC {
+		return nil, ErrNotMdx
+	}
+
+	model := &Model{}
+	offset := 4
+	for offset < len(data) {
+		if offset+8 > len(data) {
+			return nil, fmt.Errorf("truncated chunk header at offset %d", offset)
+		}
+
+		tag := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		offset += 8
+		if size < 0 || offset+size > len(data) {
+			return nil, fmt.Errorf("chunk %s at offset %d runs past the end of the file", tag, offset-8)
+		}
+
+		chunk := data[offset : offset+size]
+		offset += size
+
+		var err error
+		switch tag {
+		case "VERS":
+			if len(chunk) < 4 {
+				return nil, fmt.Errorf("chunk VERS is too small")
+			}
+			model.Version = binary.LittleEndian.Uint32(chunk)
+		case "MODL":
+			err = model.readModel(chunk)
+		case "SEQS":
+			err = model.readSequences(chunk)
+		case "GLBS":
+			for i := 0; i+4 <= len(chunk); i += 4 {
+				model.GlobalSequences = append(model.GlobalSequences, binary.LittleEndian.Uint32(chunk[i:]))
+			}
+		case "TEXS":
+			err = model.readTextures(chunk)
+		case "ATCH":
+			err = model.readAttachments(chunk)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return model, nil
+}
+
+func (model *Model) readModel(chunk []byte) error {
+	if len(chunk) < modelSize {
+		return fmt.Errorf("chunk MODL is too small")
+	}
+
+	model.Name = readString(chunk[:nameLength])
+	model.AnimationFile = readString(chunk[nameLength : nameLength+fileNameLength])
+	model.Extent = readExtent(chunk[nameLength+fileNameLength:])
+	model.BlendTime = binary.LittleEndian.Uint32(chunk[nameLength+fileNameLength+28:])
+
+	return nil
+}
+
+func (model *Model) readSequences(chunk []byte) error {
+	if len(chunk)%sequenceSize != 0 {
+		return fmt.Errorf("chunk SEQS has an unexpected size of %d", len(chunk))
+	}
+
+	for i := 0; i < len(chunk); i += sequenceSize {
+		record := chunk[i : i+sequenceSize]
+		model.Sequences = append(model.Sequences, &Sequence{
+			Name:       readString(record[:nameLength]),
+			Start:      binary.LittleEndian.Uint32(record[80:]),
+			End:        binary.LittleEndian.Uint32(record[84:]),
+			MoveSpeed:  readFloat(record[88:]),
+			NonLooping: binary.LittleEndian.Uint32(record[92:]) != 0,
+			Rarity:     readFloat(record[96:]),
+			Extent:     readExtent(record[104:]),
+		})
+	}
+
+	return nil
+}
+
+func (model *Model) readTextures(chunk []byte) error {
+	if len(chunk)%textureSize != 0 {
+		return fmt.Errorf("chunk TEXS has an unexpected size of %d", len(chunk))
+	}
+
+	for i := 0; i < len(chunk); i += textureSize {
+		record := chunk[i : i+textureSize]
+		model.Textures = append(model.Textures, &Texture{
+			ReplaceableId: binary.LittleEndian.Uint32(record),
+			Path:          readString(record[4 : 4+fileNameLength]),
+			Flags:         binary.LittleEndian.Uint32(record[4+fileNameLength:]),
+		})
+	}
+
+	return nil
+}
+
+// readAttachments only reads the name, path and id of every attachment, the node behind the name is
+// followed by animation tracks of varying length which we skip using the inclusive sizes
+func (model *Model) readAttachments(chunk []byte) error {
+	for i := 0; i < len(chunk); {
+		if i+4 > len(chunk) {
+			return fmt.Errorf("truncated attachment in chunk ATCH")
+		}
+
+		attachmentSize := int(binary.LittleEndian.Uint32(chunk[i:]))
+		if attachmentSize < 8+nameLength || i+attachmentSize > len(chunk) {
+			return fmt.Errorf("attachment at offset %d has an invalid size of %d", i, attachmentSize)
+		}
+
+		record := chunk[i : i+attachmentSize]
+		nodeSize := int(binary.LittleEndian.Uint32(record[4:]))
+		attachment := &Attachment{Name: readString(record[8 : 8+nameLength])}
+		pathOffset := 4 + nodeSize
+		if nodeSize >= 4+nameLength && pathOffset+fileNameLength+4 <= len(record) {
+			attachment.Path = readString(record[pathOffset : pathOffset+fileNameLength])
+			attachment.AttachmentId = binary.LittleEndian.Uint32(record[pathOffset+fileNameLength:])
+		}
+
+		model.Attachments = append(model.Attachments, attachment)
+		i += attachmentSize
+	}
+
+	return nil
+}
+
+// readString reads a fixed size string that is padded with zeroes
+func readString(data []byte) string {
+	if index := bytes.IndexByte(data, 0); index > -1 {
+		data = data[:index]
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func readFloat(data []byte) float32 {
+	return math.Float32frombits(binary.LittleEndian.Uint32(data))
+}
+
+func readExtent(data []byte) Extent {
+	var extent Extent
+	binary.Read(bytes.NewReader(data[:28]), binary.LittleEndian, &extent)
+	return extent
+}
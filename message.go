@@ -1,25 +1,22 @@
 package main
 
 import (
-	"archive/zip"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
-	"sort"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/asticode/go-astilectron"
 	bootstrap "github.com/asticode/go-astilectron-bootstrap"
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"github.com/asticode/go-astilectron-demo/xlsx"
 	"github.com/runi95/wts-parser/models"
 	"github.com/runi95/wts-parser/parser"
 	"github.com/shibukawa/configdir"
@@ -32,6 +29,8 @@ const (
 	CONFIG_FILENAME          = "config.json"
 	DISABLED_INPUTS_FILENAME = "disabled-inputs.json"
 	MODEL_DOWNLOAD_URL       = "https://codeload.github.com/runi95/wc3-slk-edit-electron-resources/zip/master"
+	// Generated ids are a first character followed by three hex digits, which makes room for 4096 ids per character
+	GENERATED_ID_SPACE = 16 * 16 * 16
 )
 
 var (
@@ -96,6 +95,13 @@ var (
 		"Unit-Formation",
 		"Unit-Prio",
 		"Unit-CargoSize",
+		"Unit-Mindmg1",
+		"Unit-Avgdmg1",
+		"Unit-Maxdmg1",
+		"Unit-Mindmg2",
+		"Unit-Avgdmg2",
+		"Unit-Maxdmg2",
+		"Unit-DPS",
 		"DependencyEquivalents",
 		"RequirementsLevels",
 		"UpgradesUsed",
@@ -113,9 +119,10 @@ type ListData struct {
 }
 
 type SaveField struct {
-	Id    string
-	Field string
-	Value string
+	Id        string
+	Field     string
+	Value     string
+	NewString bool
 }
 
 type ConfigurationDirectories struct {
@@ -175,11 +182,22 @@ type GroupedModels struct {
 *    PRIVATE STRUCTURES
  */
 type config struct {
-	InDir         *string
-	OutDir        *string
-	ResourceETag  *string
-	IsLocked      bool
-	IsRegexSearch bool
+	InDir  *string
+	OutDir *string
+	// ResourceETag and ResourceSource are only read to migrate configurations from before resource versions
+	ResourceETag            *string `json:",omitempty"`
+	ResourceSource          *string `json:",omitempty"`
+	ResourceVersions        map[string]*ResourceVersion
+	ProjectResourceVersions map[string]string
+	ModelCategories         []*ModelCategory `json:",omitempty"`
+	IsLocked                bool
+	IsRegexSearch           bool
+	// SortKey is either original, which keeps rows in the order they were read in, or rawcode
+	SortKey string `json:",omitempty"`
+	// DefaultLocale is the locale of the strings in the input folder itself
+	DefaultLocale string `json:",omitempty"`
+	// DamageTable replaces the damage table of the game when combat stats are worked out
+	DamageTable DamageTable `json:",omitempty"`
 }
 
 func (models Models) Len() int {
@@ -195,53 +213,81 @@ func (models Models) Swap(i, j int) {
 }
 
 func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload interface{}, err error) {
+	// Resources are downloaded and extracted without holding dataMutex so that the editor keeps responding in the
+	// meantime, which is also what lets cancelling get through
 	switch m.Name {
-	case "saveField":
-		var saveField SaveField
+	case "cancelDownload":
+		payload = cancelDownload()
+		return
+	case "loadMdx":
 		if len(m.Payload) > 0 {
-			if err = json.Unmarshal(m.Payload, &saveField); err != nil {
+			err = prepareResources(w)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		}
+	case "importResourceZip":
+		var zipPath string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &zipPath); err != nil {
 				log.Println(err)
 				payload = err.Error()
 				return
 			}
 
-			fieldSplit := strings.Split(saveField.Field, "-")
-			var ok bool
-			var v interface{}
-			if fieldSplit[0] == "Unit" {
-				v, ok = unitMap[saveField.Id]
-			} else if fieldSplit[0] == "Item" {
-				v, ok = itemMap[saveField.Id]
-			} else if fieldSplit[0] == "Ability" {
-				v, ok = abilityMap[saveField.Id]
-			} else {
-				err = fmt.Errorf("invalid field name %v does not belong anywhere", saveField.Field)
+			zipPath, err = filepath.Abs(zipPath)
+			if err != nil {
 				log.Println(err)
+				payload = err.Error()
 				return
 			}
 
-			if !ok {
-				log.Println("The given id does not exist, returning unsaved")
-				payload = "unsaved"
+			err = importSelectedResourceZip(w, zipPath)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
 				return
 			}
 
-			split := strings.Split(saveField.Field, "-")
+			payload = "success"
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+
+		return
+	}
 
-			nullString := new(null.String)
-			if saveField.Value == "" || saveField.Value == "_" || saveField.Value == "\"_\"" || saveField.Value == "-" || saveField.Value == "\"-\"" {
-				nullString.Valid = false
-			} else {
-				nullString.SetValid(saveField.Value)
+	dataMutex.Lock()
+	defer dataMutex.Unlock()
+
+	switch m.Name {
+	case "saveField":
+		var saveField SaveField
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &saveField); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
 			}
 
-			err = reflectUpdateValueOnFieldNullStruct(v, *nullString, split[1])
-			if err != nil {
+			var saved bool
+			if saved, err = applySaveField(&saveField); err != nil {
 				log.Println(err)
 				payload = err.Error()
 				return
 			}
 
+			if !saved {
+				log.Println("The given id does not exist, returning unsaved")
+				payload = "unsaved"
+				return
+			}
+
 			payload = "success"
 		}
 	case "fetchMdxModel":
@@ -253,15 +299,13 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 				return
 			}
 
-			folders := configDirs.QueryFolders(configdir.Global)
-			if len(folders) < 1 {
-				err = fmt.Errorf("failed to load config directory")
-
+			resourcePath, err := resolveResourcePath(path)
+			if err != nil {
 				log.Println(err)
 				return 0, err
 			}
 
-			data, err := ioutil.ReadFile(folders[0].Path + string(filepath.Separator) + path)
+			data, err := ioutil.ReadFile(resourcePath)
 			if err != nil {
 				log.Println(err)
 				return 0, fmt.Errorf("failed to fetch mdx models")
@@ -270,6 +314,85 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 			encoded := base64.StdEncoding.EncodeToString(data)
 			payload = encoded
 		}
+	case "getResourceServer":
+		payload, err = startResourceServer()
+		if err != nil {
+			log.Println(err)
+			payload = err.Error()
+			return
+		}
+	case "fetchMdxInfo":
+		var path string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &path); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = readModelInfo(path)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "fetchModelTextures":
+		if len(m.Payload) > 0 {
+			var modelTextureRequest ModelTextureRequest
+			if err = json.Unmarshal(m.Payload, &modelTextureRequest); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = fetchModelTextures(&modelTextureRequest)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "validateUnitModel":
+		var unitId string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &unitId); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			unit, ok := unitMap[unitId]
+			if !ok {
+				err = fmt.Errorf("unit %s does not exist", unitId)
+
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = validateUnitModel(unitId, unit)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
 	case "removeUnit":
 		var unit string
 		if len(m.Payload) > 0 {
@@ -280,6 +403,7 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 			}
 
 			delete(unitMap, unit)
+			markDirty(CATEGORY_UNITS, unit)
 			payload = unit
 		} else {
 			err = fmt.Errorf("invalid input")
@@ -297,6 +421,7 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 			}
 
 			delete(itemMap, item)
+			markDirty(CATEGORY_ITEMS, item)
 			payload = item
 		} else {
 			err = fmt.Errorf("invalid input")
@@ -314,10 +439,47 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 			}
 
 			delete(abilityMap, ability)
+			markDirty(CATEGORY_ABILITIES, ability)
 			payload = ability
 		} else {
 			err = fmt.Errorf("invalid input")
 
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "removeDestructable":
+		var destructable string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &destructable); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			delete(destructableMap, destructable)
+			markDirty(CATEGORY_DESTRUCTABLES, destructable)
+			payload = destructable
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "removeDoodad":
+		var doodad string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &doodad); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			delete(doodadMap, doodad)
+			markDirty(CATEGORY_DOODADS, doodad)
+			payload = doodad
+		} else {
+			err = fmt.Errorf("invalid input")
+
 			log.Println(err)
 			payload = err.Error()
 		}
@@ -359,6 +521,23 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 					return
 				}
 
+				var merged bool
+				if disabledInputs, merged = mergeDisabledInputs(disabledInputs); merged {
+					file, err = json.Marshal(disabledInputs)
+					if err != nil {
+						log.Println(err)
+						payload = err.Error()
+						return
+					}
+
+					err = saveConfigFile(DISABLED_INPUTS_FILENAME, file)
+					if err != nil {
+						log.Println(err)
+						payload = err.Error()
+						return
+					}
+				}
+
 				payload = disabledInputs
 			} else {
 				var file []byte
@@ -427,52 +606,712 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 			log.Println(err)
 			payload = err.Error()
 		}
-	case "generateUnitId":
-		payload = getNextValidUnitId(lastValidUnitIndex)
-	case "generateItemId":
-		payload = getNextValidItemId(lastValidItemIndex)
-	case "generateAbilityId":
-		payload = getNextValidAbilityId(lastValidAbilityIndex)
-	case "saveToFile":
-		if configuration.OutDir != nil {
-			saveUnitsToFile(*configuration.OutDir)
+	case "selectDestructable":
+		var destructableId string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &destructableId); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload = destructableMap[destructableId]
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
 		}
+	case "selectDoodad":
+		var doodadId string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &doodadId); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
 
-		payload = configuration.OutDir
-	case "loadIcon":
-		var imagePath string
+			payload = doodadMap[doodadId]
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "loadDoodadVariations":
+		var doodadId string
 		if len(m.Payload) > 0 {
-			if err = json.Unmarshal(m.Payload, &imagePath); err != nil {
+			if err = json.Unmarshal(m.Payload, &doodadId); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			doodad, ok := doodadMap[doodadId]
+			if !ok {
+				err = fmt.Errorf("doodad %s does not exist", doodadId)
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload = getDoodadVariations(doodad)
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "validateDoodad":
+		var doodadId string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &doodadId); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			doodad, ok := doodadMap[doodadId]
+			if !ok {
+				err = fmt.Errorf("doodad %s does not exist", doodadId)
 				log.Println(err)
 				payload = err.Error()
 				return
 			}
 
-			payload = images[strings.Replace(strings.Replace(imagePath, "ReplaceableTextures\\CommandButtons", "Command", 1), "ReplaceableTextures\\PassiveButtons", "Passive", 1)]
+			payload = validateDoodad(doodadId, doodad)
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
 		}
-	case "saveUnit":
-		var unit models.SLKUnit
+	case "selectSplat":
+		var splatId string
 		if len(m.Payload) > 0 {
-			if err = json.Unmarshal(m.Payload, &unit); err != nil {
+			if err = json.Unmarshal(m.Payload, &splatId); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload = splatMap[splatId]
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "selectUberSplat":
+		var uberSplatId string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &uberSplatId); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload = uberSplatMap[uberSplatId]
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "selectSpawn":
+		var spawnId string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &spawnId); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload = spawnMap[spawnId]
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "selectAnimSound":
+		var soundName string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &soundName); err != nil {
 				log.Println(err)
 				payload = err.Error()
 				return
 			}
 
-			unitMap[unit.UnitID.String] = &unit
+			payload = animSoundMap[soundName]
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "loadSoundLabels":
+		var field string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &field); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = getSoundLabels(field)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "generateUnitId":
+		payload = getNextValidUnitId(lastValidUnitIndex)
+	case "generateItemId":
+		payload = getNextValidItemId(lastValidItemIndex)
+	case "generateAbilityId":
+		payload = getNextValidAbilityId(lastValidAbilityIndex)
+	case "generateDestructableId":
+		payload = getNextValidDestructableId(lastValidDestructableIndex)
+	case "generateDoodadId":
+		payload = getNextValidDoodadId(lastValidDoodadIndex)
+	case "saveToFile":
+		if configuration.OutDir != nil {
+			err = saveAllToFile(*configuration.OutDir)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			resyncInputWatcher()
+		}
+
+		payload = configuration.OutDir
+	case "exportSpreadsheet":
+		if len(m.Payload) > 0 {
+			var export SpreadsheetExport
+			if err = json.Unmarshal(m.Payload, &export); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = exportSpreadsheet(&export)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		} else {
+			err = fmt.Errorf("invalid input")
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "previewSpreadsheetImport", "importSpreadsheet":
+		if len(m.Payload) > 0 {
+			var spreadsheetImport SpreadsheetImport
+			if err = json.Unmarshal(m.Payload, &spreadsheetImport); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			var sheets []*xlsx.Sheet
+			sheets, err = readSpreadsheet(spreadsheetImport.Path, spreadsheetImport.Category)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			if m.Name == "importSpreadsheet" {
+				payload = importSpreadsheet(sheets)
+			} else {
+				payload = previewSpreadsheet(sheets)
+			}
+		} else {
+			err = fmt.Errorf("invalid input")
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "generateCode":
+		if len(m.Payload) > 0 {
+			var codeGeneration CodeGeneration
+			if err = json.Unmarshal(m.Payload, &codeGeneration); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			if configuration.OutDir == nil {
+				err = fmt.Errorf("the output folder has not been set")
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = generateCode(&codeGeneration, *configuration.OutDir)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		} else {
+			err = fmt.Errorf("invalid input")
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "exportObjectDatabase":
+		if len(m.Payload) > 0 {
+			var database ObjectDatabase
+			if err = json.Unmarshal(m.Payload, &database); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = exportObjectDatabase(&database)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		} else {
+			err = fmt.Errorf("invalid input")
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "importObjectDatabase":
+		if len(m.Payload) > 0 {
+			var database ObjectDatabase
+			if err = json.Unmarshal(m.Payload, &database); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = loadObjectDatabase(database.Path)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		} else {
+			err = fmt.Errorf("invalid input")
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "getLocales":
+		payload = getLocales()
+	case "addLocale":
+		if len(m.Payload) > 0 {
+			var locale string
+			if err = json.Unmarshal(m.Payload, &locale); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			if err = addLocale(locale); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload = getLocales()
+		} else {
+			err = fmt.Errorf("invalid input")
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "getLocalizedStrings":
+		if len(m.Payload) > 0 {
+			var localizedObject LocalizedObject
+			if err = json.Unmarshal(m.Payload, &localizedObject); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = getLocalizedStrings(&localizedObject)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		} else {
+			err = fmt.Errorf("invalid input")
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "saveLocalizedField":
+		if len(m.Payload) > 0 {
+			var localizedField LocalizedField
+			if err = json.Unmarshal(m.Payload, &localizedField); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			var saved bool
+			if saved, err = saveLocalizedField(&localizedField); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			if !saved {
+				log.Println("The given id does not exist, returning unsaved")
+				payload = "unsaved"
+				return
+			}
+
+			payload = "success"
+		} else {
+			err = fmt.Errorf("invalid input")
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "getMissingTranslations":
+		payload = getMissingTranslations()
+	case "setDefaultLocale":
+		var defaultLocale string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &defaultLocale); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			if defaultLocale == "" || strings.ContainsAny(defaultLocale, `/\.`) {
+				err = fmt.Errorf("invalid locale name %q", defaultLocale)
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			if defaultLocale != configuration.DefaultLocale {
+				configuration.DefaultLocale = defaultLocale
+
+				err = saveConfig()
+				if err != nil {
+					log.Println(err)
+					payload = err.Error()
+					return
+				}
+			}
+
+			payload = getDefaultLocale()
+		} else {
+			err = fmt.Errorf("invalid input")
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "previewTooltip":
+		if len(m.Payload) > 0 {
+			var text string
+			if err = json.Unmarshal(m.Payload, &text); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload = previewTooltip(text)
+		} else {
+			err = fmt.Errorf("invalid input")
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "validateTooltips":
+		payload = validateTooltips()
+	case "checkCommandCards":
+		var unitIds []string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &unitIds); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		}
+
+		payload = checkCommandCards(unitIds)
+	case "getTechTree":
+		var options TechTreeOptions
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &options); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		}
+
+		payload, err = getTechTree(&options)
+		if err != nil {
+			log.Println(err)
+			payload = err.Error()
+			return
+		}
+	case "getUnitStats":
+		var unitIds []string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &unitIds); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		}
+
+		payload, err = getUnitsStats(unitIds)
+		if err != nil {
+			log.Println(err)
+			payload = err.Error()
+			return
+		}
+	case "getDamageTable":
+		payload = getDamageTable()
+	case "setDamageTable":
+		var damageTable DamageTable
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &damageTable); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			// An empty table goes back to the one the game comes with
+			if len(damageTable) > 0 {
+				if err = validateDamageTable(damageTable); err != nil {
+					log.Println(err)
+					payload = err.Error()
+					return
+				}
+			} else {
+				damageTable = nil
+			}
+
+			configuration.DamageTable = damageTable
+
+			err = saveConfig()
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload = getDamageTable()
+		} else {
+			err = fmt.Errorf("invalid input")
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "loadIcon":
+		var imagePath string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &imagePath); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = loadIcon(imagePath)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		}
+	case "saveUnit":
+		var unit models.SLKUnit
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &unit); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			deriveDamageFields(&unit)
+			unitMap[unit.UnitID.String] = &unit
+			markDirty(CATEGORY_UNITS, unit.UnitID.String)
+
+			payload = "success"
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "saveDestructable":
+		var destructable SLKDestructable
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &destructable); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			if destructableMap == nil {
+				err = errDestructablesNotLoaded
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			destructableMap[destructable.DestructableID.String] = &destructable
+			markDirty(CATEGORY_DESTRUCTABLES, destructable.DestructableID.String)
+
+			payload = "success"
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "saveDoodad":
+		var doodad SLKDoodad
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &doodad); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			if doodadMap == nil {
+				err = errDoodadsNotLoaded
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			doodadMap[doodad.DoodID.String] = &doodad
+			markDirty(CATEGORY_DOODADS, doodad.DoodID.String)
+
+			payload = "success"
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "saveSplat":
+		var splat SLKSplat
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &splat); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			splatMap[splat.Name.String] = &splat
+			markDirty(CATEGORY_SPLATS, splat.Name.String)
+
+			payload = "success"
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "saveUberSplat":
+		var uberSplat SLKUberSplat
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &uberSplat); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			uberSplatMap[uberSplat.Name.String] = &uberSplat
+			markDirty(CATEGORY_UBERSPLATS, uberSplat.Name.String)
+
+			payload = "success"
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "saveSpawn":
+		var spawn SLKSpawn
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &spawn); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			spawnMap[spawn.Name.String] = &spawn
+			markDirty(CATEGORY_SPAWNS, spawn.Name.String)
+
+			payload = "success"
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "saveAnimSound":
+		var animSound SLKAnimSound
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &animSound); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			soundName := sylk.Unquote(animSound.SoundName.String)
+			animSoundMap[soundName] = &animSound
+			markDirty(CATEGORY_ANIM_SOUNDS, soundName)
+
+			payload = "success"
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "createSoundLabel":
+		if m.Payload != nil {
+			var newSoundLabel NewSoundLabel
+			if err = json.Unmarshal(m.Payload, &newSoundLabel); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = createSoundLabel(&newSoundLabel)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		}
+	case "loadSlk":
+		payload = loadSLK()
+
+		// Custom icons might have been imported into the input folder since we last looked
+		forgetIconCatalog()
 
-			payload = "success"
+		clearDirty(CATEGORY_UNITS, CATEGORY_ITEMS, CATEGORY_ABILITIES, CATEGORY_DESTRUCTABLES, CATEGORY_DOODADS, CATEGORY_SPLATS, CATEGORY_UBERSPLATS, CATEGORY_SPAWNS, CATEGORY_ANIM_SOUNDS, CATEGORY_ANIM_LOOKUPS)
+		if configuration.InDir != nil {
+			startInputWatcher(w, *configuration.InDir)
 		} else {
-			err = fmt.Errorf("invalid input")
-
-			log.Println(err)
-			payload = err.Error()
+			stopInputWatcher()
 		}
-	case "loadSlk":
-		payload = loadSLK()
 	case "loadData":
 		err = loadData()
-		if err != nil {
+		if err == errBaseDataMissing {
+			// Everything except the base ability data still works so there's no reason to stop here
+			err = nil
+			payload = "missing"
+			return
+		} else if err != nil {
 			payload = err.Error()
 			return
 		}
@@ -487,6 +1326,28 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 		}
 
 		payload = baseAbilityKeyList
+	case "loadBaseDestructableData":
+		var baseDestructableListData = make([]ListData, len(baseDestructableMap))
+
+		i := 0
+		for k, v := range baseDestructableMap {
+			baseDestructableListData[i] = ListData{k, resolveWorldEditString(v.Name.String), null.String{}}
+			i++
+		}
+
+		payload = baseDestructableListData
+	case "loadBaseDoodadData":
+		var baseDoodadListData = make([]ListData, len(baseDoodadMap))
+
+		i := 0
+		for k, v := range baseDoodadMap {
+			baseDoodadListData[i] = ListData{k, resolveWorldEditString(v.Name.String), null.String{}}
+			i++
+		}
+
+		payload = baseDoodadListData
+	case "loadDoodadMetaData":
+		payload = doodadMetaDataMap
 	case "loadAbilityMetaData":
 		payload = abilityMetaDataMap
 	case "loadUnitData":
@@ -519,18 +1380,29 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 		}
 
 		payload = abilityListData
+	case "loadDestructableData":
+		payload = loadDestructableListData()
+	case "loadDoodadData":
+		payload = loadDoodadListData()
+	case "loadSplatData":
+		payload = loadSplatListData(splatMap)
+	case "loadUberSplatData":
+		payload = loadSplatListData(uberSplatMap)
+	case "loadSpawnData":
+		payload = loadSplatListData(spawnMap)
+	case "validateSplatReferences":
+		payload = validateSplatReferences()
+	case "loadAnimSoundData":
+		payload, _ = getSoundLabels(SOUND_LABEL_FIELD_RANDOM)
+	case "loadAnimLookupData":
+		payload = loadAnimLookupListData()
 	case "loadIcons":
-		iconModels := make(Models, 0, len(images))
-		for k := range images {
-			path := strings.Replace(strings.Replace(k, "Command", "ReplaceableTextures\\CommandButtons", 1), "Passive", "ReplaceableTextures\\PassiveButtons", 1)
-			name := strings.Replace(strings.Replace(strings.Replace(k, "Command\\BTN", "", 1), "Passive\\PASBTN", "", 1), ".blp", "", 1)
-
-			iconModels = append(iconModels, Model{name, path})
+		payload, err = loadIcons()
+		if err != nil {
+			log.Println(err)
+			payload = err.Error()
+			return
 		}
-
-		sort.Sort(iconModels)
-
-		payload = iconModels
 	case "loadConfig":
 		queryResult := configDirs.QueryFolders(configdir.Global)
 		if len(queryResult) < 1 {
@@ -577,6 +1449,16 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 			configuration.OutDir = output
 		}
 
+		if err = migrateResourceVersions(); err != nil {
+			log.Println(err)
+			payload = err.Error()
+			return
+		}
+
+		if resources != nil && *resources != "" {
+			useCommandLineResources(resources)
+		}
+
 		payload = configuration
 	case "saveOptions":
 		if m.Payload != nil {
@@ -1258,6 +2140,7 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 			}
 
 			unitMap[unitId] = unit
+			markDirty(CATEGORY_UNITS, unitId)
 			payload = unit
 		}
 	case "createNewItem":
@@ -1323,6 +2206,7 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 			item.Ubertip.SetValid("\"Unlearns all of the Hero's spells, allowing the Hero to learn different skills.\"")
 
 			itemMap[itemId] = item
+			markDirty(CATEGORY_ITEMS, itemId)
 			payload = item
 		}
 	case "createNewAbility":
@@ -1354,203 +2238,309 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 			// TODO: Implement functionality to create new abilities here!
 
 			abilityMap[alias] = ability
+			markDirty(CATEGORY_ABILITIES, alias)
 			payload = ability
 		}
+	case "createNewDestructable":
+		if m.Payload != nil {
+			var newDestructable NewDestructable
+			if err = json.Unmarshal(m.Payload, &newDestructable); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = createNewDestructable(&newDestructable)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		}
+	case "createNewDoodad":
+		if m.Payload != nil {
+			var newDoodad NewDoodad
+			if err = json.Unmarshal(m.Payload, &newDoodad); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload, err = createNewDoodad(&newDoodad)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		}
 	case "loadMdx":
 		if len(m.Payload) > 0 {
-			folders := configDirs.QueryFolders(configdir.Global)
-			if len(folders) < 1 {
-				err = fmt.Errorf("failed to load config directory")
+			var catalog *modelCatalog
+			catalog, err = getModelCatalog()
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			payload = catalog.grouped()
+		}
+	case "refreshModelCatalog":
+		payload, err = refreshModelCatalog()
+		if err != nil {
+			log.Println(err)
+			payload = err.Error()
+			return
+		}
+	case "searchModels":
+		if len(m.Payload) > 0 {
+			var modelSearch ModelSearch
+			if err = json.Unmarshal(m.Payload, &modelSearch); err != nil {
 				log.Println(err)
 				payload = err.Error()
 				return
 			}
 
-			path := folders[0].Path
+			var catalog *modelCatalog
+			catalog, err = getModelCatalog()
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
 
-			err = startDownload(w, path)
+			payload, err = catalog.search(&modelSearch)
 			if err != nil {
 				log.Println(err)
 				payload = err.Error()
 				return
 			}
+		} else {
+			err = fmt.Errorf("invalid input")
 
-			var unitModelList Models
-			unitWalkPath := path + string(filepath.Separator) + "resources" + string(filepath.Separator) + "wc3-slk-edit-electron-resources-master" + string(filepath.Separator) + "units"
-			var flag = false
-			if flag, err = exists(unitWalkPath); err != nil || flag {
-				err = filepath.Walk(unitWalkPath, func(currentPath string, info os.FileInfo, err error) error {
-					if err != nil {
-						return err
-					}
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "getModelCategories":
+		payload = getModelCategories()
+	case "setModelCategories":
+		if len(m.Payload) > 0 {
+			var modelCategories []*ModelCategory
+			if err = json.Unmarshal(m.Payload, &modelCategories); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
 
-					if !info.IsDir() {
-						index := strings.LastIndex(info.Name(), ".")
-						if index > -1 {
-							if info.Name()[index:] == ".mdx" {
-								unitModelList = append(unitModelList, Model{info.Name()[:index], "units" + currentPath[len(unitWalkPath):]})
-							}
-						}
-					}
-					return err
-				})
+			if err = validateModelCategories(modelCategories); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
 
-				if err != nil {
-					log.Println(err)
-					payload = err.Error()
-					return
-				}
+			configuration.ModelCategories = modelCategories
+			forgetModelCatalogs()
+
+			err = saveConfig()
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
 			}
 
-			buildingWalkPath := path + string(filepath.Separator) + "resources" + string(filepath.Separator) + "wc3-slk-edit-electron-resources-master" + string(filepath.Separator) + "buildings"
-			flag = false
-			if flag, err = exists(buildingWalkPath); err != nil || flag {
-				err = filepath.Walk(buildingWalkPath, func(currentPath string, info os.FileInfo, err error) error {
-					if err != nil {
-						return err
-					}
+			payload = getModelCategories()
+		} else {
+			err = fmt.Errorf("invalid input")
 
-					if !info.IsDir() {
-						index := strings.LastIndex(info.Name(), ".")
-						if index > -1 {
-							if info.Name()[index:] == ".mdx" {
-								unitModelList = append(unitModelList, Model{info.Name()[:index], "buildings" + currentPath[len(buildingWalkPath):]})
-							}
-						}
-					}
-					return err
-				})
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "getResourceSource":
+		source := getResourceSource(getResourceVersion(getResourceVersionName()))
+		source.Root, err = getResourceRoot()
+		if err != nil {
+			log.Println(err)
+			payload = err.Error()
+			return
+		}
 
-				if err != nil {
-					log.Println(err)
-					payload = err.Error()
-					return
-				}
+		payload = source
+	case "setResourceSource":
+		var resourceSource *string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &resourceSource); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
 			}
 
-			var abilityModelList Models
-			abilityWalkPath := path + string(filepath.Separator) + "resources" + string(filepath.Separator) + "wc3-slk-edit-electron-resources-master" + string(filepath.Separator) + "abilities" + string(filepath.Separator) + "spells"
-			flag = false
-			if flag, err = exists(abilityWalkPath); err != nil || flag {
-				err = filepath.Walk(abilityWalkPath, func(currentPath string, info os.FileInfo, err error) error {
-					if err != nil {
-						return err
-					}
+			// A source picked in the editor replaces the one passed on the command line and is kept
+			commandLineResourceVersion = nil
+			version := getResourceVersion(getResourceVersionName())
+			version.Source = resourceSource
+			version.ETag = nil
 
-					if !info.IsDir() {
-						index := strings.LastIndex(info.Name(), ".")
-						if index > -1 {
-							if info.Name()[index:] == ".mdx" {
-								abilityModelList = append(abilityModelList, Model{info.Name()[:index], "abilities" + string(filepath.Separator) + "spells" + currentPath[len(abilityWalkPath):]})
-							}
-						}
-					}
-					return err
-				})
+			err = saveConfig()
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
 
-				if err != nil {
-					log.Println(err)
-					payload = err.Error()
-					return
-				}
+			payload = configuration
+		}
+	case "listResourceVersions":
+		payload, err = listResourceVersions()
+		if err != nil {
+			log.Println(err)
+			payload = err.Error()
+			return
+		}
+	case "addResourceVersion":
+		if len(m.Payload) > 0 {
+			var newResourceVersion NewResourceVersion
+			if err = json.Unmarshal(m.Payload, &newResourceVersion); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
 			}
 
-			var missileModelList Models
-			missileWalkPath := path + string(filepath.Separator) + "resources" + string(filepath.Separator) + "wc3-slk-edit-electron-resources-master" + string(filepath.Separator) + "abilities" + string(filepath.Separator) + "weapons"
-			flag = false
-			if flag, err = exists(missileWalkPath); err != nil || flag {
-				err = filepath.Walk(missileWalkPath, func(currentPath string, info os.FileInfo, err error) error {
-					if err != nil {
-						return err
-					}
+			err = addResourceVersion(&newResourceVersion)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
 
-					if !info.IsDir() {
-						index := strings.LastIndex(info.Name(), ".")
-						if index > -1 {
-							if info.Name()[index:] == ".mdx" {
-								missileModelList = append(missileModelList, Model{info.Name()[:index], "abilities" + string(filepath.Separator) + "weapons" + currentPath[len(missileWalkPath):]})
-							}
-						}
-					}
-					return err
-				})
+			err = saveConfig()
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
 
-				if err != nil {
-					log.Println(err)
-					payload = err.Error()
-					return
-				}
+			payload, err = listResourceVersions()
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
 			}
+		} else {
+			err = fmt.Errorf("invalid input")
 
-			var itemModelList Models
-			itemWalkPath := path + string(filepath.Separator) + "resources" + string(filepath.Separator) + "wc3-slk-edit-electron-resources-master" + string(filepath.Separator) + "objects" + string(filepath.Separator) + "inventoryitems"
-			flag = false
-			if flag, err = exists(itemWalkPath); err != nil || flag {
-				err = filepath.Walk(itemWalkPath, func(currentPath string, info os.FileInfo, err error) error {
-					if err != nil {
-						return err
-					}
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "selectResourceVersion":
+		var name string
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &name); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
 
-					if !info.IsDir() {
-						index := strings.LastIndex(info.Name(), ".")
-						if index > -1 {
-							if info.Name()[index:] == ".mdx" {
-								itemModelList = append(itemModelList, Model{info.Name()[:index], "objects" + string(filepath.Separator) + "inventoryitems" + currentPath[len(itemWalkPath):]})
-							}
-						}
-					}
-					return err
-				})
+			err = selectResourceVersion(name)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
 
-				if err != nil {
-					log.Println(err)
-					payload = err.Error()
-					return
-				}
+			err = saveConfig()
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
 			}
 
-			var groupedModelList = new(GroupedModels)
-			if unitModelList != nil {
-				sort.Sort(unitModelList)
-				groupedModelList.Units = unitModelList
-			} else {
-				groupedModelList.Units = []Model{}
+			payload, err = listResourceVersions()
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
 			}
+		} else {
+			err = fmt.Errorf("invalid input")
 
-			if abilityModelList != nil {
-				sort.Sort(abilityModelList)
-				groupedModelList.Abilities = abilityModelList
-			} else {
-				groupedModelList.Abilities = []Model{}
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "planResourceCleanup":
+		payload, err = planResourceCleanup()
+		if err != nil {
+			log.Println(err)
+			payload = err.Error()
+			return
+		}
+	case "cleanupResourceVersions":
+		var confirmed ResourceCleanup
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &confirmed); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
 			}
 
-			if missileModelList != nil {
-				sort.Sort(missileModelList)
-				groupedModelList.Missiles = missileModelList
-			} else {
-				groupedModelList.Missiles = []Model{}
+			payload, err = cleanupResourceVersions(&confirmed)
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
 			}
 
-			if itemModelList != nil {
-				sort.Sort(itemModelList)
-				groupedModelList.Items = itemModelList
-			} else {
-				groupedModelList.Items = []Model{}
+			err = saveConfig()
+			if err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+		} else {
+			err = fmt.Errorf("invalid input")
+
+			log.Println(err)
+			payload = err.Error()
+		}
+	case "setRegexSearch":
+		var isRegexSearch bool
+		if len(m.Payload) > 0 {
+			if err = json.Unmarshal(m.Payload, &isRegexSearch); err != nil {
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			if isRegexSearch != configuration.IsRegexSearch {
+				configuration.IsRegexSearch = isRegexSearch
+
+				err = saveConfig()
+				if err != nil {
+					log.Println(err)
+					payload = err.Error()
+					return
+				}
 			}
 
-			payload = groupedModelList
+			payload = configuration.IsRegexSearch
 		}
-	case "setRegexSearch":
-		var isRegexSearch bool
+	case "setSortKey":
+		var sortKey string
 		if len(m.Payload) > 0 {
-			if err = json.Unmarshal(m.Payload, &isRegexSearch); err != nil {
+			if err = json.Unmarshal(m.Payload, &sortKey); err != nil {
 				log.Println(err)
 				payload = err.Error()
 				return
 			}
 
-			if isRegexSearch != configuration.IsRegexSearch {
-				configuration.IsRegexSearch = isRegexSearch
+			if sortKey != SORT_KEY_ORIGINAL && sortKey != SORT_KEY_RAWCODE {
+				err = fmt.Errorf("invalid sort key %s", sortKey)
+				log.Println(err)
+				payload = err.Error()
+				return
+			}
+
+			if sortKey != configuration.SortKey {
+				configuration.SortKey = sortKey
 
 				err = saveConfig()
 				if err != nil {
@@ -1560,7 +2550,11 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 				}
 			}
 
-			payload = configuration.IsRegexSearch
+			payload = configuration.SortKey
+		} else {
+			err = fmt.Errorf("invalid input")
+			log.Println(err)
+			payload = err.Error()
 		}
 	case "getOperatingSystem":
 		payload = runtime.GOOS
@@ -1583,6 +2577,86 @@ func HandleMessages(w *astilectron.Window, m bootstrap.MessageIn) (payload inter
 *    PRIVATE FUNCTIONS
 *     - these are functions that are only called from within this file
  */
+// applySaveField sets a "Prefix-FieldName" field of an object, false means there is no object with the id
+func applySaveField(saveField *SaveField) (bool, error) {
+	fieldSplit := strings.Split(saveField.Field, "-")
+	var ok bool
+	var v interface{}
+	var category string
+	if fieldSplit[0] == "Unit" {
+		v, ok = unitMap[saveField.Id]
+		category = CATEGORY_UNITS
+	} else if fieldSplit[0] == "Item" {
+		v, ok = itemMap[saveField.Id]
+		category = CATEGORY_ITEMS
+	} else if fieldSplit[0] == "Ability" {
+		v, ok = abilityMap[saveField.Id]
+		category = CATEGORY_ABILITIES
+	} else if fieldSplit[0] == "Destructable" {
+		v, ok = destructableMap[saveField.Id]
+		category = CATEGORY_DESTRUCTABLES
+	} else if fieldSplit[0] == "Doodad" {
+		v, ok = doodadMap[saveField.Id]
+		category = CATEGORY_DOODADS
+	} else if fieldSplit[0] == "Splat" {
+		v, ok = splatMap[saveField.Id]
+		category = CATEGORY_SPLATS
+	} else if fieldSplit[0] == "UberSplat" {
+		v, ok = uberSplatMap[saveField.Id]
+		category = CATEGORY_UBERSPLATS
+	} else if fieldSplit[0] == "Spawn" {
+		v, ok = spawnMap[saveField.Id]
+		category = CATEGORY_SPAWNS
+	} else if fieldSplit[0] == "AnimSound" {
+		v, ok = animSoundMap[saveField.Id]
+		category = CATEGORY_ANIM_SOUNDS
+	} else if fieldSplit[0] == "AnimLookup" {
+		v, ok = animLookupMap[saveField.Id]
+		category = CATEGORY_ANIM_LOOKUPS
+	} else {
+		return false, fmt.Errorf("invalid field name %v does not belong anywhere", saveField.Field)
+	}
+
+	if !ok {
+		return false, nil
+	}
+
+	split := strings.Split(saveField.Field, "-")
+
+	nullString := new(null.String)
+	if isNullFieldValue(saveField.Value) {
+		nullString.Valid = false
+	} else {
+		nullString.SetValid(saveField.Value)
+	}
+
+	err := reflectUpdateValueOnFieldNullStruct(v, *nullString, split[1])
+	if err != nil {
+		return false, err
+	}
+
+	// The text goes into a new string of the map's string table and the field refers to it
+	if saveField.NewString {
+		if err = createStringReference(category, saveField.Id, split[1]); err != nil {
+			return false, err
+		}
+	}
+
+	// The damage and DPS of a unit are worked out from its dice, sides, damage bonus and cooldown
+	if category == CATEGORY_UNITS && isDamageInputField(split[1]) {
+		deriveDamageFields(v.(*models.SLKUnit))
+	}
+
+	markDirty(category, saveField.Id)
+
+	return true, nil
+}
+
+// isNullFieldValue tells whether a value clears a field rather than setting it
+func isNullFieldValue(value string) bool {
+	return value == "" || value == "_" || value == "\"_\"" || value == "-" || value == "\"-\""
+}
+
 func reflectUpdateValueOnFieldNullStruct(iface interface{}, fieldValue interface{}, fieldName string) error {
 	valueIface := reflect.ValueOf(iface)
 	if valueIface.Type().Kind() != reflect.Ptr {
@@ -1633,18 +2707,27 @@ func saveConfigFile(fileName string, data []byte) error {
 	return folders[0].WriteFile(fileName, data)
 }
 
-func getNextValidAbilityId(offset int) string {
-	var str string
+// mergeDisabledInputs adds the inputs that are disabled by default but weren't when the list was written, merged
+// tells whether any were added
+func mergeDisabledInputs(disabledInputs []string) ([]string, bool) {
+	merged := false
+	for _, input := range derivedDamageInputs {
+		if !containsString(disabledInputs, input) {
+			disabledInputs = append(disabledInputs, input)
+			merged = true
+		}
+	}
 
-	if offset > 16383 {
+	return disabledInputs, merged
+}
+
+func getNextValidAbilityId(offset int) string {
+	str, ok := generatedId("A", offset)
+	if !ok {
 		log.Println("Ran out of valid generated ability id's")
 		return ""
 	}
 
-	var firstChar string
-	firstChar = "A"
-
-	str = firstChar + intToHex(offset/256) + intToHex(int(offset/16)%16) + intToHex(offset%16)
 	if _, ok := abilityMap[str]; !ok {
 		lastValidAbilityIndex = offset
 		return str
@@ -1654,17 +2737,12 @@ func getNextValidAbilityId(offset int) string {
 }
 
 func getNextValidItemId(offset int) string {
-	var str string
-
-	if offset > 16383 {
+	str, ok := generatedId("I", offset)
+	if !ok {
 		log.Println("Ran out of valid generated item id's")
 		return ""
 	}
 
-	var firstChar string
-	firstChar = "I"
-
-	str = firstChar + intToHex(offset/256) + intToHex(int(offset/16)%16) + intToHex(offset%16)
 	if _, ok := itemMap[str]; !ok {
 		lastValidItemIndex = offset
 		return str
@@ -1674,75 +2752,114 @@ func getNextValidItemId(offset int) string {
 }
 
 func getNextValidUnitId(offset int) string {
-	var str string
-
-	if offset > 16383 {
+	str, ok := generatedId("unhoe", offset)
+	if !ok {
 		log.Println("Ran out of valid generated unit id's")
 		return ""
 	}
 
-	var firstChar string
-
-	switch offset / 4096 {
-	case 0:
-		firstChar = "u"
-	case 1:
-		firstChar = "n"
-	case 2:
-		firstChar = "h"
-	case 3:
-		firstChar = "o"
-	case 4:
-		firstChar = "e"
-	default:
-		firstChar = "u"
-	}
-
-	str = firstChar + intToHex(offset/256) + intToHex(int(offset/16)%16) + intToHex(offset%16)
 	if _, ok := unitMap[str]; !ok {
-		lastValidItemIndex = offset
+		lastValidUnitIndex = offset
 		return str
 	}
 
 	return getNextValidUnitId(offset + 1)
 }
 
+// generatedId encodes an offset as one of the first characters followed by three hex digits, the next first
+// character is used every GENERATED_ID_SPACE offsets. ok is false for offsets that can't be encoded
+func generatedId(firstChars string, offset int) (string, bool) {
+	if offset < 0 || offset >= len(firstChars)*GENERATED_ID_SPACE {
+		return "", false
+	}
+
+	index := offset % GENERATED_ID_SPACE
+
+	return firstChars[offset/GENERATED_ID_SPACE:offset/GENERATED_ID_SPACE+1] + intToHex(index/256) + intToHex(index/16%16) + intToHex(index%16), true
+}
+
 func intToHex(i int) string {
 	if i < 10 {
 		return fmt.Sprint(i)
 	} else if i < 16 {
-		return fmt.Sprint(string(55 + i))
+		return fmt.Sprint(string(rune(55 + i)))
 	} else {
 		return ""
 	}
 }
 
-func saveUnitsToFile(location string) {
-	unitList := make([]*models.SLKUnit, len(unitMap))
+// saveAllToFile writes every object, string and locale file to the output folder. Nothing is marked as saved
+// unless everything was written, the dirty objects are written again next time
+func saveAllToFile(location string) error {
+	err := saveUnitsToFile(location)
+	if err != nil {
+		return err
+	}
 
-	i := 0
-	for _, v := range unitMap {
-		unitList[i] = v
-		i++
+	err = saveStringTable(location)
+	if err != nil {
+		return err
 	}
 
-	abilityList := make([]*models.SLKAbility, len(abilityMap))
-	i = 0
-	for _, v := range abilityMap {
-		abilityList[i] = v
-		i++
+	err = saveLocales(location)
+	if err != nil {
+		return err
+	}
+
+	if destructableMap != nil {
+		err = saveDestructablesToFile(location)
+		if err != nil {
+			return err
+		}
+	}
+
+	if doodadMap != nil {
+		err = saveDoodadsToFile(location)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = saveSplatsToFile(location)
+	if err != nil {
+		return err
+	}
+
+	err = saveSoundInfoToFile(location)
+	if err != nil {
+		return err
 	}
 
-	parser.WriteToFilesAndSaveToFolder(unitList, []*models.SLKItem{}, abilityList, location, true)
+	// Items aren't written yet so they're still just as dirty as before
+	clearDirty(CATEGORY_UNITS, CATEGORY_ABILITIES, CATEGORY_DESTRUCTABLES, CATEGORY_DOODADS, CATEGORY_SPLATS, CATEGORY_UBERSPLATS, CATEGORY_SPAWNS, CATEGORY_ANIM_SOUNDS, CATEGORY_ANIM_LOOKUPS)
+
+	return nil
 }
 
-func loadData() error {
-	var err error
+func saveUnitsToFile(location string) error {
+	// The library writes what's in the maps so the string table references have to be in there while it does
+	restoreStringReferences()
+	defer resolveStringReferences()
 
-	folders := configDirs.QueryFolders(configdir.Global)
-	if len(folders) < 1 {
-		err = fmt.Errorf("failed to load config directory")
+	// Ranging over the maps would hand the units and abilities over in a different order on every save
+	unitList := make([]*models.SLKUnit, len(unitMap))
+	for i, unitId := range sortedObjectKeys(unitMap) {
+		unitList[i] = unitMap[unitId]
+	}
+
+	abilityList := make([]*models.SLKAbility, len(abilityMap))
+	for i, abilityId := range sortedObjectKeys(abilityMap) {
+		abilityList[i] = abilityMap[abilityId]
+	}
+
+	return saveWithLibrary(location, func(generatedDirectory string) {
+		parser.WriteToFilesAndSaveToFolder(unitList, []*models.SLKItem{}, abilityList, generatedDirectory, true)
+	})
+}
 
+func loadData() error {
+	resourceRoot, err := getResourceRoot()
+	if err != nil {
 		log.Println(err)
 		return err
 	}
@@ -1766,13 +2883,19 @@ func loadData() error {
 	var undeadAbilityFuncPath *string = nil
 	var undeadAbilityStringsPath *string = nil
 
-	inputDirectory := folders[0].Path + string(filepath.Separator) + "resources" + string(filepath.Separator) + "wc3-slk-edit-electron-resources-master" + string(filepath.Separator) + "data"
+	inputDirectory := resourceRoot + string(filepath.Separator) + "data"
+	loadBaseDestructables(inputDirectory)
+	loadBaseDoodads(inputDirectory)
+	loadBaseObjectIds(inputDirectory)
 
 	var filesInDirectory []os.FileInfo
 	filesInDirectory, err = ioutil.ReadDir(inputDirectory)
 	if err != nil {
-		log.Fatal(err)
-		return err
+		log.Printf("Could not read base data from %s, continuing without it: %v\n", inputDirectory, err)
+
+		abilityMetaDataMap = make(map[string]*models.AbilityMetaData)
+		baseAbilityMap = make(map[string]*models.SLKAbility)
+		return errBaseDataMissing
 	}
 
 	for _, file := range filesInDirectory {
@@ -1816,6 +2939,8 @@ func loadData() error {
 			itemAbilityFuncPath = &path
 		case "itemabilitystrings.txt":
 			itemAbilityStringsPath = &path
+		case "destructabledata.slk", "worldeditstrings.txt", "doodads.slk", "doodadmetadata.slk":
+			// Read by loadBaseDestructables and loadBaseDoodads
 		default:
 			log.Printf("%v is an unknown file and will be ignored!", lowercaseFilename)
 		}
@@ -2283,6 +3408,17 @@ func loadSLK() []*FileInfo {
 	var itemDataFileInfo = &FileInfo{"ItemData.slk", "color-secondary", "fa-genderless"}
 	var itemFuncFileInfo = &FileInfo{"ItemFunc.txt", "color-secondary", "fa-genderless"}
 	var itemStringsFileInfo = &FileInfo{"ItemStrings.txt", "color-secondary", "fa-genderless"}
+	var destructableDataFileInfo = &FileInfo{DESTRUCTABLE_DATA_FILENAME, "color-secondary", "fa-genderless"}
+	var worldEditStringsFileInfo = &FileInfo{WORLD_EDIT_STRINGS_FILENAME, "color-secondary", "fa-genderless"}
+	var doodadDataFileInfo = &FileInfo{DOODAD_DATA_FILENAME, "color-secondary", "fa-genderless"}
+	var doodadFuncFileInfo = &FileInfo{DOODAD_FUNC_FILENAME, "color-secondary", "fa-genderless"}
+	var doodadStringsFileInfo = &FileInfo{DOODAD_STRINGS_FILENAME, "color-secondary", "fa-genderless"}
+	var splatDataFileInfo = &FileInfo{SPLAT_DATA_FILENAME, "color-secondary", "fa-genderless"}
+	var uberSplatDataFileInfo = &FileInfo{UBERSPLAT_DATA_FILENAME, "color-secondary", "fa-genderless"}
+	var spawnDataFileInfo = &FileInfo{SPAWN_DATA_FILENAME, "color-secondary", "fa-genderless"}
+	var animSoundsFileInfo = &FileInfo{ANIM_SOUNDS_FILENAME, "color-secondary", "fa-genderless"}
+	var animLookupsFileInfo = &FileInfo{ANIM_LOOKUPS_FILENAME, "color-secondary", "fa-genderless"}
+	var stringTableFileInfo = &FileInfo{STRING_TABLE_FILENAME, "color-secondary", "fa-genderless"}
 	var fileInfoList = []*FileInfo{
 		campaignAbilityFuncFileInfo,
 		campaignAbilityStringsFileInfo,
@@ -2320,8 +3456,23 @@ func loadSLK() []*FileInfo {
 		itemDataFileInfo,
 		itemFuncFileInfo,
 		itemStringsFileInfo,
+		destructableDataFileInfo,
+		worldEditStringsFileInfo,
+		doodadDataFileInfo,
+		doodadFuncFileInfo,
+		doodadStringsFileInfo,
+		splatDataFileInfo,
+		uberSplatDataFileInfo,
+		spawnDataFileInfo,
+		animSoundsFileInfo,
+		animLookupsFileInfo,
+		stringTableFileInfo,
 	}
 
+	destructableMap = make(map[string]*SLKDestructable)
+	doodadMap = make(map[string]*SLKDoodad)
+	forgetSourceFiles()
+
 	if configuration.InDir == nil {
 		log.Println("Input directory has not been set!")
 		return fileInfoList
@@ -3414,6 +4565,30 @@ func loadSLK() []*FileInfo {
 		parser.PopulateItemMapWithTxtFileData(itemStringsBytes, itemMap)
 	}
 
+	loadStringTable(inputDirectory, stringTableFileInfo)
+	loadLocales(inputDirectory)
+	loadUpgrades(inputDirectory)
+
+	// An object database replaces the units, items and abilities of the SLK and TXT files, which means those
+	// files are written from scratch the next time they're saved
+	if isObjectDatabase(inputDirectory) {
+		log.Println("Parsing object database...")
+		if _, err = loadObjectDatabase(inputDirectory); err != nil {
+			log.Println(err)
+		}
+
+		rememberInputFiles(inputDirectory, CATEGORY_DESTRUCTABLES, CATEGORY_DOODADS, CATEGORY_SPLATS, CATEGORY_UBERSPLATS, CATEGORY_SPAWNS, CATEGORY_ANIM_SOUNDS, CATEGORY_ANIM_LOOKUPS)
+	} else {
+		rememberInputFiles(inputDirectory)
+	}
+
+	resolveStringReferences()
+
+	loadDestructables(inputDirectory, destructableDataFileInfo, worldEditStringsFileInfo)
+	loadDoodads(inputDirectory, doodadDataFileInfo, doodadFuncFileInfo, doodadStringsFileInfo)
+	loadSplats(inputDirectory, splatDataFileInfo, uberSplatDataFileInfo, spawnDataFileInfo)
+	loadSoundInfo(inputDirectory, animSoundsFileInfo, animLookupsFileInfo)
+
 	return fileInfoList
 }
 
@@ -3431,257 +4606,6 @@ func exists(path string) (bool, error) {
 	return true, err
 }
 
-func SendDownloadProgressMessage(w *astilectron.Window, done chan int64, path string, total int64) {
-	stop := false
-
-	for {
-		select {
-		case <-done:
-			stop = true
-		default:
-			file, err := os.Open(path)
-			if err != nil {
-				log.Println(err)
-			}
-
-			fileStat, err := file.Stat()
-			if err != nil {
-				log.Println(err)
-			}
-
-			size := fileStat.Size()
-
-			if size == 0 {
-				size = 1
-			}
-
-			percent := float64(size) / float64(total) * 50
-
-			w.SendMessage(EventMessage{"downloadPercentUpdate", percent})
-		}
-
-		if stop {
-			break
-		}
-
-		time.Sleep(time.Second)
-	}
-}
-
-func startDownload(w *astilectron.Window, path string) error {
-	w.SendMessage(EventMessage{"downloadStart", nil})
-
-	url := MODEL_DOWNLOAD_URL
-
-	start := time.Now()
-
-	file := path + string(filepath.Separator) + "temp.zip"
-
-	log.Printf("Download started for %s...\n", file)
-
-	var err error
-	var headResp *http.Response
-	var size int64
-
-	out, err := os.Create(file)
-	if err != nil {
-		return err
-	}
-
-	headResp, err = http.Head(url)
-	if err != nil {
-		return err
-	}
-
-	defer headResp.Body.Close()
-
-	eTag := headResp.Header.Get("ETag")
-
-	if configuration.ResourceETag != nil && *(configuration.ResourceETag) == eTag {
-		return nil
-	}
-
-	size = headResp.ContentLength
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	log.Printf("size(%v)\n", size)
-
-	// If the HEAD request didn't receive any Content-Length header we'll have to grab it from the actual request
-	if size < 0 {
-		size = resp.ContentLength
-		log.Printf("Size failed, new size(%v)\n", size)
-	}
-
-	w.SendMessage(EventMessage{"downloadTextUpdate", "Downloading..."})
-
-	done := make(chan int64)
-
-	go SendDownloadProgressMessage(w, done, file, size)
-
-	n, err := io.Copy(out, resp.Body)
-	if err != nil {
-		done <- 0
-
-		return err
-	}
-
-	done <- n
-
-	err = out.Close()
-	if err != nil {
-		return err
-	}
-
-	elapsed := time.Since(start)
-	log.Printf("Download completed in %s\n", elapsed)
-
-	configuration.ResourceETag = &eTag
-
-	err = saveConfig()
-	if err != nil {
-		return err
-	}
-
-	w.SendMessage(EventMessage{"downloadTextUpdate", "Extracting..."})
-
-	unzipDestination := path + string(filepath.Separator) + "resources"
-
-	os.Mkdir(unzipDestination, os.ModePerm)
-
-	_, err = Unzip(w, file, unzipDestination)
-	if err != nil {
-		return err
-	}
-
-	w.SendMessage(EventMessage{"downloadTextUpdate", "Cleaning up..."})
-
-	err = os.Remove(file)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func DirSize(path string) (int64, error) {
-	var size int64
-	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			size += info.Size()
-		}
-		return err
-	})
-	return size, err
-}
-
-func SendExtractionProgressMessage(w *astilectron.Window, done chan int64, path string, total int64) {
-	stop := false
-
-	for {
-		select {
-		case <-done:
-			stop = true
-		default:
-
-			size, err := DirSize(path)
-			if err != nil {
-				log.Println(err)
-			}
-
-			if size == 0 {
-				size = 1
-			}
-
-			percent := 50 + (float64(size) / float64(total) * 50)
-
-			w.SendMessage(EventMessage{"downloadPercentUpdate", percent})
-		}
-
-		if stop {
-			break
-		}
-
-		time.Sleep(time.Second)
-	}
-}
-
-// Unzip will decompress a zip archive, moving all files and folders
-// within the zip file to an output directory
-func Unzip(w *astilectron.Window, src string, destination string) ([]string, error) {
-	var fileNames []string
-
-	r, err := zip.OpenReader(src)
-	if err != nil {
-		return fileNames, err
-	}
-
-	var totalSize int64
-	totalSize = 0
-
-	for _, f := range r.File {
-		totalSize += f.FileInfo().Size()
-	}
-
-	done := make(chan int64)
-
-	go SendExtractionProgressMessage(w, done, destination, totalSize)
-
-	for _, f := range r.File {
-		filePath := filepath.Join(destination, f.Name)
-
-		if !strings.HasPrefix(filePath, filepath.Clean(destination)+string(os.PathSeparator)) {
-			return fileNames, fmt.Errorf("%s: illegal file path", filePath)
-		}
-
-		fileNames = append(fileNames, filePath)
-
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(filePath, os.ModePerm)
-			continue
-		}
-
-		if err = os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
-			return fileNames, err
-		}
-
-		outFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			return fileNames, err
-		}
-
-		rc, err := f.Open()
-		if err != nil {
-			return fileNames, err
-		}
-
-		_, err = io.Copy(outFile, rc)
-
-		// Close the file without defer to close before next iteration of loop
-		outFile.Close()
-		rc.Close()
-
-		if err != nil {
-			return fileNames, err
-		}
-	}
-
-	err = r.Close()
-	if err != nil {
-		return fileNames, err
-	}
-
-	return fileNames, nil
-}
-
 func CrashWithMessage(w *astilectron.Window, message string) {
 	w.SendMessage(EventMessage{"crash", message})
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/runi95/wts-parser/models"
+)
+
+func TestGeneratedId(t *testing.T) {
+	tests := []struct {
+		firstChars string
+		offset     int
+		id         string
+		ok         bool
+	}{
+		{"A", 0, "A000", true},
+		{"A", 255, "A0FF", true},
+		{"A", 4095, "AFFF", true},
+		{"A", 4096, "", false},
+		{"A", -1, "", false},
+		{"unhoe", 4095, "uFFF", true},
+		{"unhoe", 4096, "n000", true},
+		{"unhoe", 16384, "e000", true},
+		{"unhoe", 20479, "eFFF", true},
+		{"unhoe", 20480, "", false},
+	}
+
+	for _, test := range tests {
+		id, ok := generatedId(test.firstChars, test.offset)
+		if id != test.id || ok != test.ok {
+			t.Errorf("generatedId(%q, %d) = %q, %v, expected %q, %v", test.firstChars, test.offset, id, ok, test.id, test.ok)
+		}
+	}
+}
+
+func TestGetNextValidUnitIdRemembersUnitIndex(t *testing.T) {
+	defer func(previous map[string]*models.SLKUnit) { unitMap = previous }(unitMap)
+	defer func(previous int) { lastValidUnitIndex = previous }(lastValidUnitIndex)
+	defer func(previous int) { lastValidItemIndex = previous }(lastValidItemIndex)
+
+	unitMap = map[string]*models.SLKUnit{"uFFF": {}}
+	lastValidItemIndex = 0
+
+	if id := getNextValidUnitId(4095); id != "n000" {
+		t.Errorf("got %q, expected n000", id)
+	}
+
+	if lastValidUnitIndex != 4096 || lastValidItemIndex != 0 {
+		t.Errorf("the unit index is %d and the item index %d", lastValidUnitIndex, lastValidItemIndex)
+	}
+}
+
+func TestMergeDisabledInputs(t *testing.T) {
+	// A list written before the derived damage fields were disabled
+	disabledInputs, merged := mergeDisabledInputs([]string{"Unit-Blend", "Unit-DPS"})
+	expected := []string{"Unit-Blend", "Unit-DPS", "Unit-Mindmg1", "Unit-Avgdmg1", "Unit-Maxdmg1", "Unit-Mindmg2", "Unit-Avgdmg2", "Unit-Maxdmg2"}
+	if !merged || !reflect.DeepEqual(disabledInputs, expected) {
+		t.Errorf("got %v, %v, expected %v, true", disabledInputs, merged, expected)
+	}
+
+	if _, merged = mergeDisabledInputs(append([]string{}, defaultDisabledUnits...)); merged {
+		t.Errorf("the default list was missing inputs")
+	}
+}
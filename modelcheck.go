@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/mdx"
+	"github.com/runi95/wts-parser/models"
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type ModelIssue struct {
+	Field   string
+	Value   string
+	Message string
+}
+
+type UnitModelValidation struct {
+	UnitID string
+	Path   string
+	Model  *mdx.Model
+	Issues []*ModelIssue
+}
+
+// readModelInfo parses the header of a model, path is relative to the resources or the input folder
+func readModelInfo(path string) (*mdx.Model, error) {
+	resourcePath, err := resolveResourcePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(resourcePath)
+	if err != nil && configuration.InDir != nil {
+		inputPath, inputErr := resolveInputPath(path)
+		if inputErr != nil {
+			return nil, inputErr
+		}
+
+		data, err = ioutil.ReadFile(inputPath)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mdx.Parse(data)
+}
+
+// findModelPath turns the value of a file field like "units\human\Footman\Footman" into the path of an
+// mdx file, looking through the model catalog first since the game doesn't care about casing
+func findModelPath(file string) (string, error) {
+	modelPath := strings.Replace(strings.Trim(file, "\""), "\\", "/", -1)
+	if modelPath == "" {
+		return "", fmt.Errorf("no model has been set")
+	}
+
+	lowercaseModelPath := strings.ToLower(modelPath)
+	if strings.HasSuffix(lowercaseModelPath, ".mdl") {
+		modelPath = modelPath[:len(modelPath)-4]
+	}
+
+	if !strings.HasSuffix(lowercaseModelPath, ".mdx") {
+		modelPath += ".mdx"
+	}
+
+	catalog, err := getModelCatalog()
+	if err != nil {
+		return "", err
+	}
+
+	lowercaseModelPath = strings.ToLower(filepath.FromSlash(modelPath))
+	for _, entry := range catalog.Entries {
+		if strings.ToLower(entry.Path) == lowercaseModelPath {
+			return entry.Path, nil
+		}
+	}
+
+	if inputPath, err := resolveInputPath(modelPath); err == nil {
+		if flag, err := exists(inputPath); err == nil && flag {
+			return filepath.FromSlash(modelPath), nil
+		}
+	}
+
+	return "", fmt.Errorf("could not find the model %s", modelPath)
+}
+
+// validateUnitModel checks that the animation and attachment properties of a unit exist in its model
+func validateUnitModel(unitId string, unit *models.SLKUnit) (*UnitModelValidation, error) {
+	validation := &UnitModelValidation{UnitID: unitId, Issues: []*ModelIssue{}}
+	if unit.UnitUI == nil || !unit.File.Valid {
+		validation.Issues = append(validation.Issues, &ModelIssue{"File", "", "no model has been set"})
+		return validation, nil
+	}
+
+	path, err := findModelPath(unit.File.String)
+	if err != nil {
+		validation.Issues = append(validation.Issues, &ModelIssue{"File", unit.File.String, err.Error()})
+		return validation, nil
+	}
+
+	model, err := readModelInfo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	validation.Path = path
+	validation.Model = model
+	if unit.UnitFunc == nil {
+		return validation, nil
+	}
+
+	var sequenceNames []string
+	for _, sequence := range model.Sequences {
+		sequenceNames = append(sequenceNames, sequence.Name)
+	}
+
+	var attachmentNames []string
+	for _, attachment := range model.Attachments {
+		attachmentNames = append(attachmentNames, attachment.Name)
+	}
+
+	if unit.Animprops.Valid {
+		for _, tag := range missingModelTags(unit.Animprops.String, sequenceNames) {
+			validation.Issues = append(validation.Issues, &ModelIssue{"Animprops", tag, fmt.Sprintf("the model has no %s animations", tag)})
+		}
+	}
+
+	if unit.Attachmentanimprops.Valid {
+		for _, tag := range missingModelTags(unit.Attachmentanimprops.String, attachmentNames) {
+			validation.Issues = append(validation.Issues, &ModelIssue{"Attachmentanimprops", tag, fmt.Sprintf("the model has no %s attachments", tag)})
+		}
+	}
+
+	return validation, nil
+}
+
+// missingModelTags returns the comma separated tags of a field that aren't a word in any of the names
+func missingModelTags(field string, names []string) []string {
+	words := make(map[string]bool)
+	for _, name := range names {
+		for _, word := range strings.Fields(strings.ToLower(name)) {
+			words[word] = true
+		}
+	}
+
+	var missing []string
+	for _, tag := range strings.Split(strings.Trim(field, "\""), ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "_" || tag == "-" {
+			continue
+		}
+
+		if !words[strings.ToLower(tag)] {
+			missing = append(missing, tag)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"github.com/runi95/wts-parser/models"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+// An object database keeps every unit, item and ability in a file of its own, units/h001.yaml for example, so
+// that they can be kept in version control. The files are flat maps of field names to values and null fields
+// are left out. Only the part of YAML that such a map needs is supported
+
+const (
+	OBJECT_DATABASE_FORMAT_JSON = "json"
+	OBJECT_DATABASE_FORMAT_YAML = "yaml"
+	// The manifest lists the files of the last export, only those are ever removed by the next one
+	OBJECT_DATABASE_MANIFEST = ".exported"
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type ObjectDatabase struct {
+	Path   string
+	Format string
+}
+
+// isObjectDatabase tells whether a folder has a category folder with object files in it
+func isObjectDatabase(directory string) bool {
+	for _, category := range spreadsheetCategories {
+		files, err := ioutil.ReadDir(filepath.Join(directory, category.Name))
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			if _, ok := objectFileFormat(file.Name()); ok && !file.IsDir() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func objectFileFormat(name string) (string, bool) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return OBJECT_DATABASE_FORMAT_JSON, true
+	case ".yaml", ".yml":
+		return OBJECT_DATABASE_FORMAT_YAML, true
+	}
+
+	return "", false
+}
+
+// exportObjectDatabase writes a file per object and removes the files an earlier export wrote for objects that
+// no longer exist, files that were put there some other way are left alone. The number of objects that were
+// written is returned by category
+func exportObjectDatabase(database *ObjectDatabase) (map[string]int, error) {
+	format := strings.ToLower(database.Format)
+	if format == "" || format == "yml" {
+		format = OBJECT_DATABASE_FORMAT_YAML
+	}
+
+	if format != OBJECT_DATABASE_FORMAT_JSON && format != OBJECT_DATABASE_FORMAT_YAML {
+		return nil, fmt.Errorf("%s is not a supported format", database.Format)
+	}
+
+	exported, err := readObjectDatabaseManifest(database.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	written := []string{}
+	counts := make(map[string]int)
+	for _, category := range spreadsheetCategories {
+		directory := filepath.Join(database.Path, category.Name)
+		if err := os.MkdirAll(directory, os.ModePerm); err != nil {
+			return nil, err
+		}
+
+		ids := sortedObjectKeys(category.Objects())
+
+		// Ids are case sensitive while the file systems of Windows and macOS aren't
+		fileNames := make(map[string]string)
+		for _, id := range ids {
+			if other, ok := fileNames[strings.ToLower(id)]; ok {
+				return nil, fmt.Errorf("%s %s and %s can't be written to the same folder since their ids only differ in case", category.Name, other, id)
+			}
+
+			fileNames[strings.ToLower(id)] = id
+		}
+
+		// Stale files go first, a file system that ignores case would otherwise keep the name of an old file
+		// for an object whose id only differs in case
+		files := make(map[string]bool)
+		for _, id := range ids {
+			files[category.Name+"/"+id+"."+format] = true
+		}
+
+		for _, name := range exported {
+			// A manifest edited by hand must not reach outside of the category folder
+			fileName := strings.TrimPrefix(name, category.Name+"/")
+			if _, ok := objectFileFormat(fileName); !ok || fileName == name || strings.ContainsAny(fileName, "/\\") || files[name] {
+				continue
+			}
+
+			if err = os.Remove(filepath.Join(database.Path, filepath.FromSlash(name))); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+
+		objects := reflect.ValueOf(category.Objects())
+		fields := category.allFields()
+		for _, id := range ids {
+			object := objects.MapIndex(reflect.ValueOf(id)).Elem()
+
+			var data []byte
+			if format == OBJECT_DATABASE_FORMAT_JSON {
+				data = objectToJson(object, fields)
+			} else {
+				data = objectToYaml(object, fields)
+			}
+
+			path := filepath.Join(directory, id+"."+format)
+			if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, data) {
+				continue
+			}
+
+			if err := writeFileAtomically(path, data); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, id := range ids {
+			written = append(written, category.Name+"/"+id+"."+format)
+		}
+
+		counts[category.Name] = len(ids)
+	}
+
+	if err = writeObjectDatabaseManifest(database.Path, written); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// readObjectDatabaseManifest returns the files of the last export by their slash separated path relative to
+// the database, a database that has never been exported has none
+func readObjectDatabaseManifest(directory string) ([]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(directory, OBJECT_DATABASE_MANIFEST))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+
+	return files, nil
+}
+
+func writeObjectDatabaseManifest(directory string, files []string) error {
+	sort.Strings(files)
+
+	data := []byte(strings.Join(files, "\n") + "\n")
+	path := filepath.Join(directory, OBJECT_DATABASE_MANIFEST)
+	if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+
+	return writeFileAtomically(path, data)
+}
+
+// validFieldValue returns the value of a field, ok is false for null fields
+func validFieldValue(object reflect.Value, field reflect.StructField) (string, bool) {
+	value, ok := objectField(object, field.Index)
+	if !ok {
+		return "", false
+	}
+
+	nullString := value.Interface().(null.String)
+
+	return nullString.String, nullString.Valid
+}
+
+func objectToJson(object reflect.Value, fields []reflect.StructField) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("{")
+	first := true
+	for _, field := range fields {
+		value, ok := validFieldValue(object, field)
+		if !ok {
+			continue
+		}
+
+		if !first {
+			buffer.WriteString(",")
+		}
+
+		key, _ := json.Marshal(field.Name)
+		text, _ := json.Marshal(value)
+		fmt.Fprintf(&buffer, "\n  %s: %s", key, text)
+		first = false
+	}
+
+	buffer.WriteString("\n}\n")
+
+	return buffer.Bytes()
+}
+
+func objectToYaml(object reflect.Value, fields []reflect.StructField) []byte {
+	var buffer bytes.Buffer
+	for _, field := range fields {
+		value, ok := validFieldValue(object, field)
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(&buffer, "%s: %s\n", field.Name, yamlScalar(value))
+	}
+
+	return buffer.Bytes()
+}
+
+// yamlScalar writes a value plainly when a YAML reader would read it back as the same text and quotes it otherwise
+func yamlScalar(value string) string {
+	plain := value != "" && strings.TrimSpace(value) == value && !strings.ContainsAny(value[:1], "-?:,[]{}#&*!|>'\"%@`") &&
+		!strings.Contains(value, ": ") && !strings.HasSuffix(value, ":") && !strings.Contains(value, " #") && !strings.ContainsAny(value, "\r\n\t")
+
+	switch strings.ToLower(value) {
+	case "~", "null", "true", "false", "yes", "no", "on", "off", "y", "n":
+		plain = false
+	}
+
+	if plain {
+		return value
+	}
+
+	if !strings.ContainsAny(value, "\r\n\t") {
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	}
+
+	text, _ := json.Marshal(value)
+
+	return string(text)
+}
+
+// readObjectFile reads the fields of a JSON or YAML object file, null fields are left out
+func readObjectFile(name string, data []byte) (map[string]string, error) {
+	format, _ := objectFileFormat(name)
+	if format == OBJECT_DATABASE_FORMAT_JSON {
+		var raw map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+
+		values := make(map[string]string)
+		for key, value := range raw {
+			switch value := value.(type) {
+			case string:
+				values[key] = value
+			case json.Number:
+				values[key] = value.String()
+			case nil:
+			default:
+				return nil, fmt.Errorf("%s has to be text or a number", key)
+			}
+		}
+
+		return values, nil
+	}
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") || line == "---" {
+			continue
+		}
+
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			return nil, fmt.Errorf("line %d: nested values aren't supported", lineNumber)
+		}
+
+		index := strings.Index(line, ":")
+		if index <= 0 {
+			return nil, fmt.Errorf("line %d: expected a key and a value", lineNumber)
+		}
+
+		key := strings.TrimSpace(line[:index])
+		value, isNull, err := parseYamlScalar(strings.TrimSpace(line[index+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+
+		if !isNull {
+			values[key] = value
+		}
+	}
+
+	return values, scanner.Err()
+}
+
+func parseYamlScalar(raw string) (string, bool, error) {
+	switch {
+	case strings.HasPrefix(raw, "\""):
+		var value string
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			return "", false, fmt.Errorf("invalid double quoted value %s", raw)
+		}
+
+		return value, false, nil
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return "", false, fmt.Errorf("invalid single quoted value %s", raw)
+		}
+
+		return strings.Replace(raw[1:len(raw)-1], "''", "'", -1), false, nil
+	case strings.HasPrefix(raw, "|") || strings.HasPrefix(raw, ">"):
+		return "", false, fmt.Errorf("block values aren't supported")
+	}
+
+	if index := strings.Index(raw, " #"); index >= 0 {
+		raw = strings.TrimSpace(raw[:index])
+	}
+
+	if raw == "" || raw == "~" || raw == "null" {
+		return "", true, nil
+	}
+
+	return raw, false, nil
+}
+
+// newObject returns an object with every embedded struct created
+func newObject(objectType reflect.Type) reflect.Value {
+	object := reflect.New(objectType)
+	for i := 0; i < objectType.NumField(); i++ {
+		field := objectType.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Ptr {
+			object.Elem().Field(i).Set(reflect.New(field.Type.Elem()))
+		}
+	}
+
+	return object
+}
+
+// setObjectIds gives every embedded struct that has a value its id the way the parser library would have read it.
+// Ids read from SLK files keep their quotes while the ones from TXT files, the fields named ...Id, don't have any
+func setObjectIds(object reflect.Value, id string, idFields []string) {
+	for i := 0; i < object.NumField(); i++ {
+		part := object.Field(i)
+		if !object.Type().Field(i).Anonymous || part.Kind() != reflect.Ptr || part.IsNil() {
+			continue
+		}
+
+		part = part.Elem()
+		idField := -1
+		hasValue := false
+		for j := 0; j < part.NumField(); j++ {
+			if containsString(idFields, part.Type().Field(j).Name) {
+				idField = j
+			} else if value, ok := part.Field(j).Interface().(null.String); ok && value.Valid {
+				hasValue = true
+			}
+		}
+
+		if idField < 0 || !hasValue {
+			continue
+		}
+
+		if strings.HasSuffix(part.Type().Field(idField).Name, "Id") {
+			part.Field(idField).Set(reflect.ValueOf(null.StringFrom(id)))
+		} else {
+			part.Field(idField).Set(reflect.ValueOf(null.StringFrom(sylk.Quote(id))))
+		}
+	}
+}
+
+// loadObjectDatabase replaces the units, items and abilities with the ones of an object database, every object
+// that was added, removed or replaced is marked as dirty
+func loadObjectDatabase(directory string) (map[string]int, error) {
+	objectMaps := make(map[string]reflect.Value)
+	for _, category := range spreadsheetCategories {
+		mapType := reflect.TypeOf(category.Objects())
+		objectMap := reflect.MakeMap(mapType)
+		objectMaps[category.Name] = objectMap
+
+		files, err := ioutil.ReadDir(filepath.Join(directory, category.Name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		fields := make(map[string]reflect.StructField)
+		for _, field := range category.allFields() {
+			fields[strings.ToLower(field.Name)] = field
+		}
+
+		for _, file := range files {
+			if _, ok := objectFileFormat(file.Name()); !ok || file.IsDir() {
+				continue
+			}
+
+			id := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
+			if objectMap.MapIndex(reflect.ValueOf(id)).IsValid() {
+				return nil, fmt.Errorf("%s/%s: there's another file for %s", category.Name, file.Name(), id)
+			}
+
+			data, err := ioutil.ReadFile(filepath.Join(directory, category.Name, file.Name()))
+			if err != nil {
+				return nil, err
+			}
+
+			values, err := readObjectFile(file.Name(), data)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %v", category.Name, file.Name(), err)
+			}
+
+			object := newObject(mapType.Elem().Elem())
+			hasIds := false
+			for key, value := range values {
+				field, ok := fields[strings.ToLower(strings.TrimPrefix(key, category.Prefix+"-"))]
+				if !ok {
+					return nil, fmt.Errorf("%s/%s: %s is not a field", category.Name, file.Name(), key)
+				}
+
+				object.Elem().FieldByIndex(field.Index).Set(reflect.ValueOf(null.StringFrom(value)))
+				hasIds = hasIds || containsString(category.IdFields, field.Name)
+			}
+
+			// Files written by hand can leave the ids out
+			if !hasIds {
+				setObjectIds(object.Elem(), id, category.IdFields)
+			}
+
+			objectMap.SetMapIndex(reflect.ValueOf(id), object)
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, category := range spreadsheetCategories {
+		for _, id := range append(sortedObjectKeys(category.Objects()), sortedObjectKeys(objectMaps[category.Name].Interface())...) {
+			markDirty(category.Name, id)
+		}
+
+		counts[category.Name] = objectMaps[category.Name].Len()
+	}
+
+	unitMap = objectMaps[CATEGORY_UNITS].Interface().(map[string]*models.SLKUnit)
+	itemMap = objectMaps[CATEGORY_ITEMS].Interface().(map[string]*models.SLKItem)
+	abilityMap = objectMaps[CATEGORY_ABILITIES].Interface().(map[string]*models.SLKAbility)
+	resolveStringReferences()
+
+	return counts, nil
+}
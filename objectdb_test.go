@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/runi95/wts-parser/models"
+)
+
+func TestYamlRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"plain", "Footman"},
+		{"empty", ""},
+		{"boolean", "yes"},
+		{"null", "null"},
+		{"number", "425"},
+		{"leading dash", "-1"},
+		{"key like", "Damage: 12"},
+		{"trailing colon", "Damage:"},
+		{"comment like", "Sword #2"},
+		{"leading hash", "#1"},
+		{"apostrophe", "Thrall's Axe"},
+		{"leading quote", "'Quoted'"},
+		{"double quotes", "\"Quoted\""},
+		{"surrounding spaces", " Footman "},
+		{"multiple lines", "Line one\nLine two"},
+		{"windows line ending", "Line one\r\nLine two"},
+		{"tab", "Left\tRight"},
+		{"color", "|cffffcc00Gold|r"},
+		{"new line code", "Attacks land units.|n|n|cffffcc00Level 1|r"},
+		{"leading new line code", "|nStarts on the next line"},
+		{"unicode", "Jäger — 猎人"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := []byte("Name: " + yamlScalar(test.value) + "\n")
+			values, err := readObjectFile("h000.yaml", data)
+			if err != nil {
+				t.Fatalf("%q was written as %q which can't be read: %v", test.value, data, err)
+			}
+
+			if value, ok := values["Name"]; !ok || value != test.value {
+				t.Errorf("%q was written as %q and read back as %q", test.value, data, value)
+			}
+		})
+	}
+}
+
+func TestReadObjectFileSkipsNullValues(t *testing.T) {
+	values, err := readObjectFile("h000.yaml", []byte("---\n# A comment\nName: Footman # trailing comment\nHP: ~\nArmor:\nTip: null\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := map[string]string{"Name": "Footman"}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("got %v, expected %v", values, expected)
+	}
+}
+
+func TestReadObjectFileRejectsBlockValues(t *testing.T) {
+	for _, data := range []string{"Name: |\n  Footman\n", "Name: >\n  Footman\n", "Name:\n  nested: value\n"} {
+		if _, err := readObjectFile("h000.yaml", []byte(data)); err == nil {
+			t.Errorf("%q was read without an error", data)
+		}
+	}
+}
+
+func newTestUnit(hp string) *models.SLKUnit {
+	unit := newObject(reflect.TypeOf(models.SLKUnit{})).Interface().(*models.SLKUnit)
+	unit.UnitBalance.HP.SetValid(hp)
+
+	return unit
+}
+
+func TestExportObjectDatabaseOnlyRemovesFilesItWrote(t *testing.T) {
+	defer func(previous map[string]*models.SLKUnit) { unitMap = previous }(unitMap)
+	defer func(previous map[string]*models.SLKItem) { itemMap = previous }(itemMap)
+	defer func(previous map[string]*models.SLKAbility) { abilityMap = previous }(abilityMap)
+
+	directory := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(directory, CATEGORY_UNITS), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file somebody put there by hand
+	notes := filepath.Join(directory, CATEGORY_UNITS, "notes.yaml")
+	if err := ioutil.WriteFile(notes, []byte("Name: Notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	unitMap = map[string]*models.SLKUnit{"h000": newTestUnit("420"), "h001": newTestUnit("425")}
+	itemMap = map[string]*models.SLKItem{}
+	abilityMap = map[string]*models.SLKAbility{}
+
+	if _, err := exportObjectDatabase(&ObjectDatabase{Path: directory, Format: OBJECT_DATABASE_FORMAT_YAML}); err != nil {
+		t.Fatal(err)
+	}
+
+	delete(unitMap, "h000")
+	counts, err := exportObjectDatabase(&ObjectDatabase{Path: directory, Format: OBJECT_DATABASE_FORMAT_JSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if counts[CATEGORY_UNITS] != 1 {
+		t.Errorf("%d units were exported", counts[CATEGORY_UNITS])
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(directory, CATEGORY_UNITS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+
+	if expected := []string{"h001.json", "notes.yaml"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("the units folder has %v, expected %v", names, expected)
+	}
+
+	manifest, err := readObjectDatabaseManifest(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"units/h001.json"}; !reflect.DeepEqual(manifest, expected) {
+		t.Errorf("the manifest lists %v, expected %v", manifest, expected)
+	}
+}
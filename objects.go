@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+// The object types that the parser library doesn't know about are plain structs of null.String fields,
+// the slk tag names the column a field is read from and written to, the first one being the object id
+
+var nullStringType = reflect.TypeOf(null.String{})
+
+// The categories of bundled tables that the input folder has a copy of, those are written back on every save
+// while the others only are once something in them has been edited
+var inputTables = make(map[string]bool)
+
+type objectColumn struct {
+	Name  string
+	Field int
+}
+
+// objectColumns returns the slk columns of an object struct in the order they're declared in
+func objectColumns(objectType reflect.Type) []objectColumn {
+	var columns []objectColumn
+	for i := 0; i < objectType.NumField(); i++ {
+		field := objectType.Field(i)
+		if name, ok := field.Tag.Lookup("slk"); ok && field.Type == nullStringType {
+			columns = append(columns, objectColumn{name, i})
+		}
+	}
+
+	return columns
+}
+
+// populateObjectMapWithSlkFileData adds the rows of an SLK file to a map of object structs, rows that are
+// already in the map are updated column by column
+func populateObjectMapWithSlkFileData(inputFileData []byte, objectMap interface{}) error {
+	table, err := sylk.Read(inputFileData)
+	if err != nil {
+		return err
+	}
+
+	if len(table.Columns) < 1 {
+		return nil
+	}
+
+	mapValue := reflect.ValueOf(objectMap)
+	objectType := mapValue.Type().Elem().Elem()
+	columns := objectColumns(objectType)
+
+	fields := make(map[string]int)
+	for _, column := range columns {
+		fields[strings.ToLower(column.Name)] = column.Field
+	}
+
+	columnFields := make([]int, len(table.Columns))
+	for x, column := range table.Columns {
+		index, ok := fields[strings.ToLower(column)]
+		if !ok {
+			index = -1
+		}
+
+		columnFields[x] = index
+	}
+
+	for _, row := range table.Rows {
+		id := sylk.Unquote(row[0])
+		if id == "" {
+			continue
+		}
+
+		object := mapValue.MapIndex(reflect.ValueOf(id))
+		if !object.IsValid() {
+			object = reflect.New(objectType)
+			object.Elem().Field(columns[0].Field).Set(reflect.ValueOf(null.StringFrom(id)))
+			mapValue.SetMapIndex(reflect.ValueOf(id), object)
+		}
+
+		for x, value := range row {
+			if x == 0 || columnFields[x] < 0 || value == "" {
+				continue
+			}
+
+			object.Elem().Field(columnFields[x]).Set(reflect.ValueOf(null.StringFrom(value)))
+		}
+	}
+
+	return nil
+}
+
+// loadBundledObjects reads a table that ships with the editor, a table in the input folder or in the
+// folder the game keeps it in replaces the rows it contains
+func loadBundledObjects(bundledName string, inputDirectory string, gameFolder string, fileInfo *FileInfo, objectMap interface{}, category string) {
+	delete(inputTables, category)
+
+	fileBytes, err := readBundledFile(bundledName)
+	if err != nil {
+		log.Println(err)
+	} else if err = populateObjectMapWithSlkFileData(fileBytes, objectMap); err != nil {
+		log.Println(err)
+	} else {
+		rememberSourceFile(fileInfo.FileName, category, fileBytes)
+	}
+
+	if inputDirectory == "" {
+		return
+	}
+
+	fileBytes = readInputFile(inputDirectory, fileInfo)
+	if fileBytes == nil {
+		if flag, err := exists(filepath.Join(inputDirectory, gameFolder)); err == nil && flag {
+			fileBytes = readInputFile(filepath.Join(inputDirectory, gameFolder), fileInfo)
+		}
+	}
+
+	if fileBytes != nil {
+		log.Printf("Parsing %s...\n", fileInfo.FileName)
+		if err = populateObjectMapWithSlkFileData(fileBytes, objectMap); err != nil {
+			log.Println(err)
+		} else {
+			fileInfo.StatusClass = "text-success"
+			fileInfo.StatusIconClass = "fa-check"
+			rememberSourceFile(fileInfo.FileName, category, fileBytes)
+			inputTables[category] = true
+		}
+	}
+}
+
+// readBundledFile reads a file that ships with the editor, running from source means it hasn't been bundled
+func readBundledFile(name string) ([]byte, error) {
+	if data, err := Asset(name); err == nil && len(data) > 0 {
+		return data, nil
+	}
+
+	return ioutil.ReadFile(filepath.FromSlash(name))
+}
+
+// isTableToWrite tells whether a bundled table has to be saved, writing the bundled rows of a table nobody
+// touched would only make the game read the same rows from the map
+func isTableToWrite(category string) bool {
+	return inputTables[category] || len(dirtyObjects(category)) > 0
+}
+
+// writeObjectsToSlkFile writes a map of object structs to an SLK file, sorted by id
+func writeObjectsToSlkFile(objectMap interface{}, path string, category string) error {
+	mapValue := reflect.ValueOf(objectMap)
+	columns := objectColumns(mapValue.Type().Elem().Elem())
+
+	table := &sylk.Table{}
+	for _, column := range columns {
+		table.Columns = append(table.Columns, column.Name)
+	}
+
+	for _, key := range sortedObjectKeys(objectMap) {
+		object := mapValue.MapIndex(reflect.ValueOf(key)).Elem()
+
+		row := make([]string, len(columns))
+		row[0] = sylk.Quote(key)
+		for x, column := range columns[1:] {
+			if value := object.Field(column.Field).Interface().(null.String); value.Valid {
+				row[x+1] = sylk.FormatNumber(value.String)
+			}
+		}
+
+		table.Rows = append(table.Rows, row)
+	}
+
+	var buffer bytes.Buffer
+	if err := sylk.Write(&buffer, table); err != nil {
+		return err
+	}
+
+	return writeObjectFile(path, category, buffer.Bytes())
+}
+
+// readTxtSections reads a TXT file made up of [id] sections with key=value lines
+func readTxtSections(inputFileData []byte) map[string]map[string]string {
+	sections := make(map[string]map[string]string)
+
+	var current map[string]string
+	scanner := bufio.NewScanner(bytes.NewReader(inputFileData))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			id := line[1 : len(line)-1]
+			if sections[id] == nil {
+				sections[id] = make(map[string]string)
+			}
+
+			current = sections[id]
+			continue
+		}
+
+		index := strings.Index(line, "=")
+		if current != nil && index > 0 {
+			current[line[:index]] = line[index+1:]
+		}
+	}
+
+	return sections
+}
+
+// populateObjectMapWithTxtFileData adds the sections of a TXT file to a map of object structs, keys are matched
+// against the slk columns. The keys that were found are returned by object id so that they can be written back
+func populateObjectMapWithTxtFileData(inputFileData []byte, objectMap interface{}) map[string][]string {
+	mapValue := reflect.ValueOf(objectMap)
+	objectType := mapValue.Type().Elem().Elem()
+	columns := objectColumns(objectType)
+
+	fields := make(map[string]objectColumn)
+	for _, column := range columns {
+		fields[strings.ToLower(column.Name)] = column
+	}
+
+	foundKeys := make(map[string][]string)
+	for id, section := range readTxtSections(inputFileData) {
+		object := mapValue.MapIndex(reflect.ValueOf(id))
+		if !object.IsValid() {
+			object = reflect.New(objectType)
+			object.Elem().Field(columns[0].Field).Set(reflect.ValueOf(null.StringFrom(id)))
+			mapValue.SetMapIndex(reflect.ValueOf(id), object)
+		}
+
+		for key, value := range section {
+			column, ok := fields[strings.ToLower(key)]
+			if !ok || column.Field == columns[0].Field {
+				continue
+			}
+
+			object.Elem().Field(column.Field).Set(reflect.ValueOf(null.StringFrom(value)))
+			foundKeys[id] = append(foundKeys[id], column.Name)
+		}
+
+		sort.Strings(foundKeys[id])
+	}
+
+	return foundKeys
+}
+
+// writeObjectsToTxtFile writes the given keys of every object to a TXT file, objects without keys are left out
+func writeObjectsToTxtFile(objectMap interface{}, keys map[string][]string, path string, category string) error {
+	mapValue := reflect.ValueOf(objectMap)
+	objectType := mapValue.Type().Elem().Elem()
+
+	fields := make(map[string]int)
+	for _, column := range objectColumns(objectType) {
+		fields[column.Name] = column.Field
+	}
+
+	var buffer bytes.Buffer
+	for _, id := range sortedObjectKeys(objectMap) {
+		object := mapValue.MapIndex(reflect.ValueOf(id)).Elem()
+
+		var lines []string
+		for _, key := range keys[id] {
+			field, ok := fields[key]
+			if !ok {
+				continue
+			}
+
+			if value := object.Field(field).Interface().(null.String); value.Valid {
+				lines = append(lines, key+"="+value.String)
+			}
+		}
+
+		if len(lines) < 1 {
+			continue
+		}
+
+		fmt.Fprintf(&buffer, "[%s]\r\n", id)
+		for _, line := range lines {
+			fmt.Fprintf(&buffer, "%s\r\n", line)
+		}
+
+		buffer.WriteString("\r\n")
+	}
+
+	return writeObjectFile(path, category, buffer.Bytes())
+}
+
+func sortedObjectKeys(objectMap interface{}) []string {
+	mapValue := reflect.ValueOf(objectMap)
+
+	keys := make([]string, 0, mapValue.Len())
+	for _, key := range mapValue.MapKeys() {
+		keys = append(keys, key.String())
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// cloneObject returns a copy of an object struct, null.String fields are values so a shallow copy will do
+func cloneObject(object interface{}) interface{} {
+	original := reflect.ValueOf(object)
+	clone := reflect.New(original.Type().Elem())
+	clone.Elem().Set(original.Elem())
+
+	return clone.Interface()
+}
+
+// writeFileAtomically writes to a temporary file first so that a failed save never leaves half a file behind
+func writeFileAtomically(path string, data []byte) error {
+	temporaryPath := path + ".tmp"
+	file, err := os.Create(temporaryPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(temporaryPath)
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	return os.Rename(temporaryPath, path)
+}
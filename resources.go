@@ -0,0 +1,674 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/asticode/go-astilectron"
+	"github.com/shibukawa/configdir"
+)
+
+const (
+	RESOURCE_FOLDER_NAME     = "wc3-slk-edit-electron-resources-master"
+	RESOURCE_VERSIONS_FOLDER = "resources"
+	RESOURCE_FILES_FOLDER    = "files"
+	DEFAULT_RESOURCE_VERSION = "default"
+	// The version used when resources are passed on the command line, it's never saved
+	COMMAND_LINE_RESOURCE_VERSION = "command-line"
+	RESOURCE_SOURCE_URL           = "url"
+	RESOURCE_SOURCE_ZIP           = "zip"
+	RESOURCE_SOURCE_DIRECTORY     = "directory"
+	// Holds the archive of the resources repository that is downloaded by default and its SHA-256, written by
+	// make resources-checksum and shipped with the editor
+	DEFAULT_RESOURCES_PIN_PATH = "resources/app/resources.sha256"
+)
+
+var (
+	errBaseDataMissing = fmt.Errorf("base data is missing")
+
+	// Only one resource version is downloaded or extracted at a time. That happens without holding dataMutex so
+	// the editor keeps responding, the download works on a copy of the version which is stored once it's done
+	resourceMutex sync.Mutex
+
+	commandLineResourceVersion *ResourceVersion = nil
+
+	defaultResourcesPinPath = DEFAULT_RESOURCES_PIN_PATH
+
+	resourceVersionNameReg = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type ResourceVersion struct {
+	Source *string
+	ETag   *string
+	// SHA256 is what the archive of the version has to hash to, sources that don't publish one need it pinned
+	SHA256 *string `json:",omitempty"`
+}
+
+type ResourceSource struct {
+	Kind     string
+	Location string
+	Root     string
+}
+
+type ResourceVersionInfo struct {
+	Name      string
+	Source    *ResourceSource
+	Installed bool
+	Selected  bool
+	Projects  []string
+}
+
+type NewResourceVersion struct {
+	Name   string
+	Source *string
+	SHA256 *string
+}
+
+type ResourceCleanup struct {
+	// Projects whose folder no longer exists, their choice of resource version is forgotten
+	Projects []string
+	// Resource versions whose files are removed
+	Versions []string
+}
+
+// getConfigDirectory returns the global configuration directory, which is also where resources end up
+func getConfigDirectory() (string, error) {
+	folders := configDirs.QueryFolders(configdir.Global)
+	if len(folders) < 1 {
+		return "", fmt.Errorf("failed to load config directory")
+	}
+
+	return folders[0].Path, nil
+}
+
+// useCommandLineResources makes the current run take its resources from the given source, the configuration
+// keeps whatever version was selected. Archives are extracted again on every run as their ETag isn't kept
+func useCommandLineResources(source *string) {
+	commandLineResourceVersion = &ResourceVersion{Source: source}
+}
+
+// getResourceVersionName returns the resource version selected for the current input folder
+func getResourceVersionName() string {
+	if commandLineResourceVersion != nil {
+		return COMMAND_LINE_RESOURCE_VERSION
+	}
+
+	if configuration.InDir != nil {
+		if name, ok := configuration.ProjectResourceVersions[*configuration.InDir]; ok {
+			if _, ok = configuration.ResourceVersions[name]; ok {
+				return name
+			}
+		}
+	}
+
+	return DEFAULT_RESOURCE_VERSION
+}
+
+// getResourceVersion returns the named resource version, the default version is created whenever it's missing
+func getResourceVersion(name string) *ResourceVersion {
+	if name == COMMAND_LINE_RESOURCE_VERSION {
+		return commandLineResourceVersion
+	}
+
+	if configuration.ResourceVersions == nil {
+		configuration.ResourceVersions = make(map[string]*ResourceVersion)
+	}
+
+	version, ok := configuration.ResourceVersions[name]
+	if !ok && name == DEFAULT_RESOURCE_VERSION {
+		version = &ResourceVersion{}
+		configuration.ResourceVersions[name] = version
+	}
+
+	return version
+}
+
+func getResourceVersionDirectory(name string) (string, error) {
+	if !resourceVersionNameReg.MatchString(name) {
+		return "", fmt.Errorf("%q is not a valid resource version name", name)
+	}
+
+	path, err := getConfigDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return path + string(filepath.Separator) + RESOURCE_VERSIONS_FOLDER + string(filepath.Separator) + name, nil
+}
+
+// defaultResourceArchive returns the archive that is downloaded for resource versions without a source and the
+// SHA-256 it has to have. Without a pin the latest archive is downloaded and its SHA-256 has to be pinned by hand
+func defaultResourceArchive() (string, string) {
+	pin, err := readBundledFile(defaultResourcesPinPath)
+	if err != nil {
+		return MODEL_DOWNLOAD_URL, ""
+	}
+
+	// The same "<SHA-256>  <archive>" line sha256sum writes
+	fields := strings.Fields(string(pin))
+	if len(fields) != 2 || !isSHA256(fields[0]) {
+		log.Printf("%s should hold a SHA-256 followed by the URL of the archive\n", defaultResourcesPinPath)
+		return MODEL_DOWNLOAD_URL, ""
+	}
+
+	return fields[1], strings.ToLower(fields[0])
+}
+
+// getResourceSource works out what kind of source a resource version points at, nothing at all
+// means that we download the default archive
+func getResourceSource(version *ResourceVersion) *ResourceSource {
+	if version == nil || version.Source == nil || *version.Source == "" {
+		url, _ := defaultResourceArchive()
+		return &ResourceSource{RESOURCE_SOURCE_URL, url, ""}
+	}
+
+	location := *version.Source
+	lowercaseLocation := strings.ToLower(location)
+	if strings.HasPrefix(lowercaseLocation, "http://") || strings.HasPrefix(lowercaseLocation, "https://") {
+		return &ResourceSource{RESOURCE_SOURCE_URL, location, ""}
+	}
+
+	if fileStat, err := os.Stat(location); err == nil && fileStat.IsDir() {
+		return &ResourceSource{RESOURCE_SOURCE_DIRECTORY, location, ""}
+	}
+
+	return &ResourceSource{RESOURCE_SOURCE_ZIP, location, ""}
+}
+
+// getResourceRoot returns the folder that holds the data, units, buildings, ... folders of the selected version
+func getResourceRoot() (string, error) {
+	return getResourceVersionRoot(getResourceVersionName())
+}
+
+func getResourceVersionRoot(name string) (string, error) {
+	source := getResourceSource(getResourceVersion(name))
+	if source.Kind == RESOURCE_SOURCE_DIRECTORY {
+		return findResourceRoot(source.Location), nil
+	}
+
+	path, err := getResourceVersionDirectory(name)
+	if err != nil {
+		return "", err
+	}
+
+	return findResourceRoot(path + string(filepath.Separator) + RESOURCE_FILES_FOLDER), nil
+}
+
+// findResourceRoot descends through folders that only wrap a single other folder, like the one GitHub puts
+// at the top of its archives, until it finds the actual resources
+func findResourceRoot(directory string) string {
+	current := directory
+	for i := 0; i < 3; i++ {
+		for _, marker := range []string{"data", "units"} {
+			if flag, err := exists(current + string(filepath.Separator) + marker); err == nil && flag {
+				return current
+			}
+		}
+
+		filesInDirectory, err := ioutil.ReadDir(current)
+		if err != nil || len(filesInDirectory) != 1 || !filesInDirectory[0].IsDir() {
+			break
+		}
+
+		current = current + string(filepath.Separator) + filesInDirectory[0].Name()
+	}
+
+	if flag, err := exists(directory + string(filepath.Separator) + RESOURCE_FOLDER_NAME); err == nil && flag {
+		return directory + string(filepath.Separator) + RESOURCE_FOLDER_NAME
+	}
+
+	return directory
+}
+
+// resolveResourcePath turns a path sent by the model viewer into a path on disk, the viewer always prefixes
+// paths with the folder name used by the default download
+func resolveResourcePath(path string) (string, error) {
+	root, err := getResourceRoot()
+	if err != nil {
+		return "", err
+	}
+
+	relativePath := strings.Replace(path, "\\", "/", -1)
+	relativePath = strings.TrimPrefix(relativePath, "resources/"+RESOURCE_FOLDER_NAME+"/")
+
+	return joinWithin(root, relativePath)
+}
+
+// resolveInputPath turns a path relative to the input folder into a path on disk
+func resolveInputPath(path string) (string, error) {
+	if configuration.InDir == nil {
+		return "", fmt.Errorf("no input folder has been set")
+	}
+
+	return joinWithin(*configuration.InDir, strings.Replace(path, "\\", "/", -1))
+}
+
+// joinWithin joins a slash separated relative path onto root and refuses paths that would end up outside of it
+func joinWithin(root string, relativePath string) (string, error) {
+	if strings.Contains(relativePath, ":") || filepath.IsAbs(relativePath) || strings.HasPrefix(relativePath, "/") {
+		return "", fmt.Errorf("%s is not a relative path", relativePath)
+	}
+
+	for _, element := range strings.Split(filepath.ToSlash(relativePath), "/") {
+		if element == ".." {
+			return "", fmt.Errorf("%s points outside of %s", relativePath, root)
+		}
+	}
+
+	return filepath.Join(root, filepath.FromSlash(relativePath)), nil
+}
+
+// snapshotResourceVersion copies the selected resource version so that it can be downloaded without holding
+// dataMutex
+func snapshotResourceVersion() (string, *ResourceVersion) {
+	dataMutex.Lock()
+	defer dataMutex.Unlock()
+
+	name := getResourceVersionName()
+	snapshot := &ResourceVersion{}
+	if version := getResourceVersion(name); version != nil {
+		*snapshot = *version
+	}
+
+	return name, snapshot
+}
+
+// storeResourceETag stores what has been extracted for a resource version, unless the version has been pointed
+// somewhere else in the meantime
+func storeResourceETag(name string, snapshot *ResourceVersion) error {
+	dataMutex.Lock()
+	defer dataMutex.Unlock()
+
+	version := getResourceVersion(name)
+	if version == nil || snapshot.ETag == nil || getResourceSource(version).Location != getResourceSource(snapshot).Location {
+		return nil
+	}
+
+	if version.ETag != nil && *version.ETag == *snapshot.ETag {
+		return nil
+	}
+
+	version.ETag = snapshot.ETag
+
+	return saveConfig()
+}
+
+// prepareResources makes sure that the selected resource version is ready to be used, versions whose model
+// catalog has already been built are left alone. The caller must not hold dataMutex
+func prepareResources(w *astilectron.Window) error {
+	resourceMutex.Lock()
+	defer resourceMutex.Unlock()
+
+	dataMutex.Lock()
+	_, ok := modelCatalogs[getResourceVersionName()]
+	dataMutex.Unlock()
+
+	if ok {
+		return nil
+	}
+
+	name, version := snapshotResourceVersion()
+	path, err := getResourceVersionDirectory(name)
+	if err != nil {
+		return err
+	}
+
+	source := getResourceSource(version)
+	if source.Kind == RESOURCE_SOURCE_DIRECTORY {
+		return nil
+	}
+
+	err = os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	if source.Kind == RESOURCE_SOURCE_ZIP {
+		err = importResourceZip(w, path, source.Location, false, version)
+	} else {
+		err = startDownload(w, path, source.Location, version)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return storeResourceETag(name, version)
+}
+
+// importSelectedResourceZip extracts a local archive into the selected resource version and makes the version
+// use it from now on instead of going online. The caller must not hold dataMutex
+func importSelectedResourceZip(w *astilectron.Window, zipPath string) error {
+	resourceMutex.Lock()
+	defer resourceMutex.Unlock()
+
+	name, snapshot := snapshotResourceVersion()
+	path, err := getResourceVersionDirectory(name)
+	if err != nil {
+		return err
+	}
+
+	err = importResourceZip(w, path, zipPath, true, snapshot)
+	if err != nil {
+		return err
+	}
+
+	dataMutex.Lock()
+	defer dataMutex.Unlock()
+
+	version := getResourceVersion(name)
+	if version == nil {
+		return fmt.Errorf("resource version %s has been removed", name)
+	}
+
+	version.Source = &zipPath
+	version.ETag = snapshot.ETag
+
+	return saveConfig()
+}
+
+// importResourceZip extracts a local resource archive into a resource version unless that very archive has
+// already been extracted, in which case force decides whether we extract it again
+func importResourceZip(w *astilectron.Window, path string, zipPath string, force bool, version *ResourceVersion) error {
+	fileStat, err := os.Stat(zipPath)
+	if err != nil {
+		// The archive might have been moved after it was imported, what we extracted back then is still usable
+		if verifyErr := verifyExtractedResources(path, false); !force && verifyErr == nil {
+			log.Printf("Using previously imported resources since %s could not be read: %v\n", zipPath, err)
+			return nil
+		}
+
+		return err
+	}
+
+	eTag := fmt.Sprintf("zip:%s:%d:%d", zipPath, fileStat.Size(), fileStat.ModTime().Unix())
+	if !force && version.ETag != nil && *(version.ETag) == eTag {
+		if err = verifyExtractedResources(path, false); err == nil {
+			return nil
+		}
+
+		log.Printf("Extracted resources failed verification, extracting them again: %v\n", err)
+	}
+
+	err = os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	err = verifyArchive(zipPath, version)
+	if err != nil {
+		return err
+	}
+
+	w.SendMessage(EventMessage{"downloadStart", nil})
+
+	return runCancellable(func(ctx context.Context) error {
+		return extractResources(ctx, w, zipPath, path, eTag, version)
+	})
+}
+
+func listResourceVersions() ([]*ResourceVersionInfo, error) {
+	getResourceVersion(DEFAULT_RESOURCE_VERSION)
+
+	selected := getResourceVersionName()
+	infoList := make([]*ResourceVersionInfo, 0, len(configuration.ResourceVersions))
+	for name, version := range configuration.ResourceVersions {
+		info := &ResourceVersionInfo{Name: name, Source: getResourceSource(version), Selected: name == selected, Projects: []string{}}
+
+		root, err := getResourceVersionRoot(name)
+		if err != nil {
+			return nil, err
+		}
+
+		info.Source.Root = root
+		if info.Source.Kind == RESOURCE_SOURCE_DIRECTORY {
+			info.Installed, _ = exists(root)
+		} else {
+			path, err := getResourceVersionDirectory(name)
+			if err != nil {
+				return nil, err
+			}
+
+			info.Installed = version.ETag != nil && verifyExtractedResources(path, false) == nil
+		}
+
+		for project, projectVersion := range configuration.ProjectResourceVersions {
+			if projectVersion == name {
+				info.Projects = append(info.Projects, project)
+			}
+		}
+
+		sort.Strings(info.Projects)
+		infoList = append(infoList, info)
+	}
+
+	sort.Slice(infoList, func(i, j int) bool {
+		return infoList[i].Name < infoList[j].Name
+	})
+
+	return infoList, nil
+}
+
+func addResourceVersion(newVersion *NewResourceVersion) error {
+	if !resourceVersionNameReg.MatchString(newVersion.Name) {
+		return fmt.Errorf("%q is not a valid resource version name", newVersion.Name)
+	}
+
+	if newVersion.Name == COMMAND_LINE_RESOURCE_VERSION {
+		return fmt.Errorf("%q is reserved for resources passed on the command line", newVersion.Name)
+	}
+
+	if newVersion.SHA256 != nil {
+		if !isSHA256(*newVersion.SHA256) {
+			return fmt.Errorf("%q is not a SHA-256", *newVersion.SHA256)
+		}
+	}
+
+	if version, ok := configuration.ResourceVersions[newVersion.Name]; ok {
+		// Changing the source of an existing version means that whatever we've extracted is outdated
+		version.Source = newVersion.Source
+		version.SHA256 = newVersion.SHA256
+		version.ETag = nil
+		return nil
+	}
+
+	getResourceVersion(DEFAULT_RESOURCE_VERSION)
+	configuration.ResourceVersions[newVersion.Name] = &ResourceVersion{Source: newVersion.Source, SHA256: newVersion.SHA256}
+
+	return nil
+}
+
+// selectResourceVersion makes the current input folder use the named resource version
+func selectResourceVersion(name string) error {
+	if _, ok := configuration.ResourceVersions[name]; !ok {
+		return fmt.Errorf("there is no resource version called %q", name)
+	}
+
+	if configuration.InDir == nil {
+		return fmt.Errorf("an input folder has to be set before a resource version can be selected")
+	}
+
+	if configuration.ProjectResourceVersions == nil {
+		configuration.ProjectResourceVersions = make(map[string]string)
+	}
+
+	if name == DEFAULT_RESOURCE_VERSION {
+		delete(configuration.ProjectResourceVersions, *configuration.InDir)
+	} else {
+		configuration.ProjectResourceVersions[*configuration.InDir] = name
+	}
+
+	return nil
+}
+
+// planResourceCleanup lists the projects that no longer exist and the resource versions whose files aren't used
+// by any other project, nothing is removed until the list has been confirmed
+func planResourceCleanup() (*ResourceCleanup, error) {
+	plan := &ResourceCleanup{Projects: []string{}, Versions: []string{}}
+
+	for project := range configuration.ProjectResourceVersions {
+		if flag, err := exists(project); err == nil && !flag {
+			plan.Projects = append(plan.Projects, project)
+		}
+	}
+
+	referenced := map[string]bool{DEFAULT_RESOURCE_VERSION: true, getResourceVersionName(): true}
+	for project, name := range configuration.ProjectResourceVersions {
+		if !containsString(plan.Projects, project) {
+			referenced[name] = true
+		}
+	}
+
+	versionsPath, err := getResourceVersionsPath()
+	if err != nil {
+		return plan, err
+	}
+
+	filesInDirectory, err := ioutil.ReadDir(versionsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return plan, err
+	}
+
+	for _, file := range filesInDirectory {
+		if !referenced[file.Name()] {
+			plan.Versions = append(plan.Versions, file.Name())
+		}
+	}
+
+	sort.Strings(plan.Projects)
+	sort.Strings(plan.Versions)
+
+	return plan, nil
+}
+
+// cleanupResourceVersions forgets the projects and removes the files of the resource versions of a confirmed
+// plan, anything that has come into use since the plan was made is kept. The versions themselves are kept so
+// they can be installed again later on
+func cleanupResourceVersions(confirmed *ResourceCleanup) (*ResourceCleanup, error) {
+	removed := &ResourceCleanup{Projects: []string{}, Versions: []string{}}
+
+	plan, err := planResourceCleanup()
+	if err != nil {
+		return removed, err
+	}
+
+	for _, project := range plan.Projects {
+		if containsString(confirmed.Projects, project) {
+			delete(configuration.ProjectResourceVersions, project)
+			removed.Projects = append(removed.Projects, project)
+		}
+	}
+
+	// Projects that weren't confirmed still hold on to their version
+	plan, err = planResourceCleanup()
+	if err != nil {
+		return removed, err
+	}
+
+	versionsPath, err := getResourceVersionsPath()
+	if err != nil {
+		return removed, err
+	}
+
+	for _, name := range plan.Versions {
+		if !containsString(confirmed.Versions, name) {
+			continue
+		}
+
+		log.Printf("Removing unused resource version %s...\n", name)
+
+		err = os.RemoveAll(versionsPath + string(filepath.Separator) + name)
+		if err != nil {
+			return removed, err
+		}
+
+		if version, ok := configuration.ResourceVersions[name]; ok {
+			version.ETag = nil
+		}
+
+		removed.Versions = append(removed.Versions, name)
+	}
+
+	return removed, nil
+}
+
+func getResourceVersionsPath() (string, error) {
+	path, err := getConfigDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return path + string(filepath.Separator) + RESOURCE_VERSIONS_FOLDER, nil
+}
+
+// migrateResourceVersions moves resources downloaded before versions existed into the default version
+func migrateResourceVersions() error {
+	if configuration.ResourceVersions != nil {
+		return nil
+	}
+
+	err := moveResourcesIntoDefaultVersion()
+	if err != nil {
+		return err
+	}
+
+	// The move must never happen twice so we save right away
+	return saveConfig()
+}
+
+func moveResourcesIntoDefaultVersion() error {
+	getResourceVersion(DEFAULT_RESOURCE_VERSION).Source = configuration.ResourceSource
+	getResourceVersion(DEFAULT_RESOURCE_VERSION).ETag = configuration.ResourceETag
+	configuration.ResourceSource = nil
+	configuration.ResourceETag = nil
+
+	path, err := getConfigDirectory()
+	if err != nil {
+		return err
+	}
+
+	oldResourcesPath := path + string(filepath.Separator) + RESOURCE_VERSIONS_FOLDER
+	if flag, err := exists(oldResourcesPath); err != nil || !flag {
+		return err
+	}
+
+	log.Println("Moving previously downloaded resources into the default resource version...")
+
+	temporaryPath := path + string(filepath.Separator) + RESOURCE_VERSIONS_FOLDER + ".old"
+	err = os.Rename(oldResourcesPath, temporaryPath)
+	if err != nil {
+		return err
+	}
+
+	defaultVersionPath := oldResourcesPath + string(filepath.Separator) + DEFAULT_RESOURCE_VERSION
+	err = os.MkdirAll(defaultVersionPath, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(temporaryPath, defaultVersionPath+string(filepath.Separator)+RESOURCE_FILES_FOLDER)
+	if err != nil {
+		return err
+	}
+
+	checksumsPath := path + string(filepath.Separator) + RESOURCE_CHECKSUMS_FILENAME
+	if flag, err := exists(checksumsPath); err == nil && flag {
+		err = os.Rename(checksumsPath, defaultVersionPath+string(filepath.Separator)+RESOURCE_CHECKSUMS_FILENAME)
+		if err != nil {
+			return err
+		}
+	}
+
+	return removeDownload(path)
+}
@@ -18,6 +18,18 @@ const index = {
     loadMdxModel: function (path) {
         if (mdxModels.hasOwnProperty(path)) {
             return {src: mdxModels[path], fetch: false};
+        } else if (resourceServerUrl !== null) {
+            const fetchPromise = fetch(resourceServerUrl + path.split("/").map(encodeURIComponent).join("/")).then(response => {
+                if (!response.ok) {
+                    throw response.statusText;
+                }
+
+                return response.arrayBuffer();
+            }).then(buf => {
+                mdxModels[path] = buf;
+                return buf;
+            });
+            return {src: fetchPromise, fetch: true};
         } else {
             const fetchPromise = new Promise((resolve, reject) => {
                 astilectron.sendMessage({name: "fetchMdxModel", payload: path}, function (message) {
@@ -79,6 +91,13 @@ const index = {
             // Listen
             index.listen();
 
+            astilectron.sendMessage({name: "getResourceServer", payload: null}, function (message) {
+                // Models are sent through messages instead when the server couldn't be started
+                if (message.name !== "error") {
+                    resourceServerUrl = message.payload;
+                }
+            });
+
             index.loadConfig();
         })
     },
@@ -95,6 +114,28 @@ const index = {
                 case "downloadTextUpdate":
                     document.getElementById("downloadtext").innerText = message.Payload;
                     break;
+                case "inputFilesChanged":
+                    if (message.Payload.Conflicts.length > 0) {
+                        asticode.notifier.error("Kept local changes to " + message.Payload.Conflicts.join(", ") + " even though " + message.Payload.Files.join(", ") + " changed on disk");
+                    }
+
+                    switch (message.Payload.Category) {
+                        case "units":
+                            index.loadUnitData();
+                            break;
+                        case "items":
+                            index.loadItemData();
+                            break;
+                        case "abilities":
+                            index.loadAbilityData();
+                            break;
+                        case "strings":
+                            index.loadUnitData();
+                            index.loadItemData();
+                            index.loadAbilityData();
+                            break;
+                    }
+                    break;
                 case "crash":
                     astilectron.showErrorBox("Crash", message.Payload);
                     astilectron.sendMessage({name: "closeWindow", payload: null}, function (message) {
@@ -191,6 +232,10 @@ const index = {
                 return;
             }
 
+            if (message.payload === "missing") {
+                asticode.notifier.error("Base data could not be found, base abilities will not be available");
+            }
+
             index.postLoadData();
         });
     },
@@ -612,6 +657,15 @@ const index = {
             index.loadIcon("ImageAbility-Unart", document.getElementById("Ability-Unart"));
         });
     },
+    cancelDownload: function () {
+        const message = {name: "cancelDownload", payload: null};
+        astilectron.sendMessage(message, function (message) {
+            // Check for errors
+            if (message.name === "error") {
+                asticode.notifier.error(message.payload);
+            }
+        });
+    },
     saveToFile: function () {
         document.getElementById("savingSpan").hidden = false;
         document.getElementById("savedSpan").hidden = true;
@@ -1061,6 +1115,18 @@ const index = {
             loadMdxModel(modelNameToPath[inputValue]);
         }
     },
+    refreshModelCatalog: function () {
+        astilectron.sendMessage({name: "refreshModelCatalog", payload: null}, function (message) {
+            // Check for errors
+            if (message.name === "error") {
+                asticode.notifier.error(message.payload);
+                return;
+            }
+
+            asticode.notifier.info("Found " + message.payload.Added + " new and " + message.payload.Removed + " removed models");
+            index.loadMdx();
+        });
+    },
     loadMdx: function () {
         const message = {name: "loadMdx", payload: null};
         astilectron.sendMessage(message, function (message) {
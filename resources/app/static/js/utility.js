@@ -21,6 +21,7 @@ let sortAbilityNameState = 0;
 let sortAbilityIdState = 0;
 let modelInputId;
 let iconInputId;
+let resourceServerUrl = null;
 const mdxModels = {};
 const modelNameToPath = {};
 const modelPathToName = {};
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCommandLineResourcesAreNotSaved(t *testing.T) {
+	defer func(previous *config) { configuration = previous }(configuration)
+	defer func(previous *ResourceVersion) { commandLineResourceVersion = previous }(commandLineResourceVersion)
+
+	inputDirectory := "/maps/project"
+	savedSource := "https://example.com/saved.zip"
+	commandLineSource := "https://example.com/command-line.zip"
+	configuration = &config{
+		InDir:                   &inputDirectory,
+		ResourceVersions:        map[string]*ResourceVersion{DEFAULT_RESOURCE_VERSION: {}, "saved": {Source: &savedSource}},
+		ProjectResourceVersions: map[string]string{inputDirectory: "saved"},
+	}
+
+	useCommandLineResources(&commandLineSource)
+
+	if name := getResourceVersionName(); name != COMMAND_LINE_RESOURCE_VERSION {
+		t.Errorf("the run uses resource version %q", name)
+	}
+
+	if source := getResourceSource(getResourceVersion(getResourceVersionName())); source.Location != commandLineSource {
+		t.Errorf("the run takes its resources from %s", source.Location)
+	}
+
+	data, err := json.Marshal(configuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(data), commandLineSource) || !strings.Contains(string(data), savedSource) {
+		t.Errorf("the saved configuration changed: %s", data)
+	}
+
+	if err = addResourceVersion(&NewResourceVersion{Name: COMMAND_LINE_RESOURCE_VERSION, Source: &savedSource}); err == nil {
+		t.Errorf("a saved version took the name of the command line version")
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// libraryResourcesFolder finds the game files that come with the parser library, they make up the input folder
+func libraryResourcesFolder(t *testing.T) string {
+	output, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "github.com/runi95/wts-parser").Output()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	return filepath.Join(strings.TrimSpace(string(output)), "resources")
+}
+
+// newInputFolder copies every input file we know about from the library into a temporary folder
+func newInputFolder(t *testing.T) string {
+	resources := libraryResourcesFolder(t)
+	files, err := ioutil.ReadDir(resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputDirectory := t.TempDir()
+	for _, file := range files {
+		known := false
+		for _, watchedFile := range watchedInputFiles {
+			known = known || watchedFile.Name == strings.ToLower(file.Name())
+		}
+
+		if !known {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(resources, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = ioutil.WriteFile(filepath.Join(inputDirectory, file.Name()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return inputDirectory
+}
+
+// readOutputFiles reads every SLK, TXT and WTS file below a folder by its path relative to the folder
+func readOutputFiles(t *testing.T, directory string) map[string][]byte {
+	files := make(map[string][]byte)
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".slk", ".txt", ".wts":
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			relativePath, _ := filepath.Rel(directory, path)
+			files[relativePath] = data
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+// compareOutputFiles reports every file that differs between two saves
+func compareOutputFiles(t *testing.T, what string, first map[string][]byte, second map[string][]byte) {
+	for name, data := range first {
+		if !bytes.Equal(second[name], data) {
+			t.Errorf("%s changed %s", name, what)
+		}
+	}
+
+	for name := range second {
+		if _, ok := first[name]; !ok {
+			t.Errorf("%s was only written %s", name, what)
+		}
+	}
+}
+
+// loadEditAndSave reads the input folder, edits a unit and a few abilities and saves them to a new output folder
+func loadEditAndSave(t *testing.T, inputDirectory string, sortKey string, fromScratch bool) string {
+	outputDirectory := t.TempDir()
+	configuration = &config{InDir: &inputDirectory, OutDir: &outputDirectory, SortKey: sortKey}
+
+	loadSLK()
+	if len(unitMap) < 1 || len(abilityMap) < 1 {
+		t.Fatal("the input folder has no units or abilities")
+	}
+
+	if fromScratch {
+		forgetSourceFiles()
+	}
+
+	footman, ok := unitMap["hfoo"]
+	if !ok {
+		t.Fatal("hfoo is missing")
+	}
+
+	footman.UnitBalance.HP.SetValid("425")
+	markDirty(CATEGORY_UNITS, "hfoo")
+	for _, abilityId := range sortedObjectKeys(abilityMap)[:3] {
+		abilityMap[abilityId].AbilityData.Levels.SetValid("4")
+		markDirty(CATEGORY_ABILITIES, abilityId)
+	}
+
+	if err := saveAllToFile(outputDirectory); err != nil {
+		t.Fatal(err)
+	}
+
+	return outputDirectory
+}
+
+func TestSavingTwiceWritesTheSameFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("reads and writes every game file several times")
+	}
+
+	defer func(previous *config) { configuration = previous }(configuration)
+
+	tests := []struct {
+		name    string
+		sortKey string
+		// Files of an object database are written from scratch rather than merged into the input files
+		fromScratch bool
+	}{
+		{"original order", SORT_KEY_ORIGINAL, false},
+		{"by rawcode", SORT_KEY_RAWCODE, false},
+		{"from scratch", SORT_KEY_ORIGINAL, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inputDirectory := newInputFolder(t)
+			outputDirectory := loadEditAndSave(t, inputDirectory, test.sortKey, test.fromScratch)
+			first := readOutputFiles(t, outputDirectory)
+			if len(first) < 1 {
+				t.Fatal("nothing was written")
+			}
+
+			// Saving again, with the unit marked as edited once more, has to leave every file the way it was
+			markDirty(CATEGORY_UNITS, "hfoo")
+			if err := saveAllToFile(outputDirectory); err != nil {
+				t.Fatal(err)
+			}
+
+			compareOutputFiles(t, "when it was saved again", first, readOutputFiles(t, outputDirectory))
+
+			// And so does making the same edits from the start, which is where the order of Go maps would show
+			outputDirectory = loadEditAndSave(t, inputDirectory, test.sortKey, test.fromScratch)
+			compareOutputFiles(t, "when the same edits were saved again", first, readOutputFiles(t, outputDirectory))
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	RESOURCE_SERVER_ADDRESS       = "127.0.0.1:0"
+	RESOURCE_SERVER_CACHE_CONTROL = "private, max-age=3600"
+)
+
+var (
+	resourceServerOnce sync.Once
+	resourceServerURL  string
+	resourceServerErr  error
+)
+
+// startResourceServer serves models and textures over HTTP on the loopback interface so that the viewer can
+// stream them instead of receiving them base64 encoded through messages. Every path starts with a random
+// token so that other pages and processes can't read files through us
+func startResourceServer() (string, error) {
+	resourceServerOnce.Do(func() {
+		tokenBytes := make([]byte, 16)
+		if _, resourceServerErr = rand.Read(tokenBytes); resourceServerErr != nil {
+			return
+		}
+
+		var listener net.Listener
+		listener, resourceServerErr = net.Listen("tcp", RESOURCE_SERVER_ADDRESS)
+		if resourceServerErr != nil {
+			return
+		}
+
+		token := hex.EncodeToString(tokenBytes)
+		resourceServerURL = fmt.Sprintf("http://%s/%s/", listener.Addr().String(), token)
+
+		go func() {
+			err := http.Serve(listener, &resourceHandler{"/" + token + "/"})
+			log.Println(err)
+		}()
+	})
+
+	return resourceServerURL, resourceServerErr
+}
+
+/**
+*    PRIVATE STRUCTURES
+ */
+type resourceHandler struct {
+	prefix string
+}
+
+func (handler *resourceHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	// The editor itself is loaded from disk so requests come from a file:// origin
+	rw.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, handler.prefix) {
+		http.NotFound(rw, r)
+		return
+	}
+
+	path, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), handler.prefix))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filePath, err := resolveServedPath(path)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		http.NotFound(rw, r)
+		return
+	}
+	defer file.Close()
+
+	fileStat, err := file.Stat()
+	if err != nil || fileStat.IsDir() {
+		http.NotFound(rw, r)
+		return
+	}
+
+	rw.Header().Set("Cache-Control", RESOURCE_SERVER_CACHE_CONTROL)
+
+	// Viewers that can't read BLP ask for textures as PNG
+	if r.URL.Query().Get("format") == "png" && strings.EqualFold(filepath.Ext(filePath), ".blp") {
+		data, err := ioutil.ReadAll(file)
+		if err == nil {
+			data, err = decodeTexture(data)
+		}
+
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("ETag", fmt.Sprintf("\"%x-%x-png\"", fileStat.Size(), fileStat.ModTime().UnixNano()))
+		http.ServeContent(rw, r, strings.TrimSuffix(fileStat.Name(), filepath.Ext(fileStat.Name()))+".png", fileStat.ModTime(), bytes.NewReader(data))
+		return
+	}
+
+	rw.Header().Set("ETag", fmt.Sprintf("\"%x-%x\"", fileStat.Size(), fileStat.ModTime().UnixNano()))
+
+	// ServeContent takes care of range requests, conditional requests and the content type
+	http.ServeContent(rw, r, fileStat.Name(), fileStat.ModTime(), file)
+}
+
+// resolveServedPath finds a file in the resources and falls back to the input folder for imported files
+func resolveServedPath(path string) (string, error) {
+	dataMutex.Lock()
+	defer dataMutex.Unlock()
+
+	resourcePath, err := resolveResourcePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	if flag, err := exists(resourcePath); err == nil && flag {
+		return resourcePath, nil
+	}
+
+	// The viewer lowercases every path it asks for, which only works out on case sensitive file systems
+	// if we look textures up the way the game would
+	relativePath := strings.TrimPrefix(strings.Replace(path, "\\", "/", -1), "resources/"+RESOURCE_FOLDER_NAME+"/")
+	if strings.HasSuffix(strings.ToLower(relativePath), ".blp") {
+		if texture, err := findTexture(relativePath); err == nil && texture != nil {
+			return texture.File, nil
+		}
+	}
+
+	if configuration.InDir == nil {
+		return resourcePath, nil
+	}
+
+	return resolveInputPath(relativePath)
+}
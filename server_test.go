@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const blp2TestHeaderSize = 148
+
+// newTestTexture returns an uncompressed BLP2 texture of a single red pixel
+func newTestTexture() []byte {
+	data := make([]byte, blp2TestHeaderSize, blp2TestHeaderSize+4)
+	copy(data, "BLP2")
+	binary.LittleEndian.PutUint32(data[4:], 1)
+	data[8] = 3
+	data[9] = 8
+	binary.LittleEndian.PutUint32(data[12:], 1)
+	binary.LittleEndian.PutUint32(data[16:], 1)
+	binary.LittleEndian.PutUint32(data[20:], blp2TestHeaderSize)
+	binary.LittleEndian.PutUint32(data[84:], 4)
+
+	return append(data, 0, 0, 255, 255)
+}
+
+func TestResourceServerConvertsTextures(t *testing.T) {
+	defer func(previous *config) { configuration = previous }(configuration)
+	defer func(previous *ResourceVersion) { commandLineResourceVersion = previous }(commandLineResourceVersion)
+
+	root := t.TempDir()
+	texture := newTestTexture()
+	for _, folder := range []string{"data", "Textures"} {
+		if err := os.MkdirAll(filepath.Join(root, folder), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(root, "Textures", "Red.blp"), texture, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configuration = &config{}
+	useCommandLineResources(&root)
+
+	handler := &resourceHandler{"/token/"}
+	get := func(target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		return recorder
+	}
+
+	response := get("/token/Textures/Red.blp")
+	if response.Code != http.StatusOK || !bytes.Equal(response.Body.Bytes(), texture) {
+		t.Errorf("got %d with %d bytes instead of the texture", response.Code, response.Body.Len())
+	}
+
+	response = get("/token/Textures/Red.blp" + TEXTURE_PNG_QUERY)
+	if response.Code != http.StatusOK || response.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("got %d with content type %s", response.Code, response.Header().Get("Content-Type"))
+	}
+
+	img, err := png.Decode(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r, g, b, a := img.At(0, 0).RGBA(); r != 0xFFFF || g != 0 || b != 0 || a != 0xFFFF {
+		t.Errorf("got the color %d,%d,%d,%d", r, g, b, a)
+	}
+
+	if response = get("/other/Textures/Red.blp"); response.Code != http.StatusNotFound {
+		t.Errorf("got %d without the token", response.Code)
+	}
+}
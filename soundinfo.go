@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+const (
+	CATEGORY_ANIM_SOUNDS     = "animsounds"
+	CATEGORY_ANIM_LOOKUPS    = "animlookups"
+	ANIM_SOUNDS_FILENAME     = "AnimSounds.slk"
+	ANIM_LOOKUPS_FILENAME    = "AnimLookups.slk"
+	BUNDLED_SOUND_INFO_PATH  = "resources/app/ui/soundinfo"
+	SOUND_LABEL_FIELD_RANDOM = "Unit-Randomsoundlabel"
+	SOUND_SET_FIELD          = "Unit-UnitSound"
+)
+
+var (
+	soundInfoFolder = filepath.Join("UI", "SoundInfo")
+
+	animSoundMap  = make(map[string]*SLKAnimSound)
+	animLookupMap = make(map[string]*SLKAnimLookup)
+
+	dirtyAnimSounds  = make(map[string]bool)
+	dirtyAnimLookups = make(map[string]bool)
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type SLKAnimSound struct {
+	SoundName      null.String `slk:"SoundName"`
+	FileNames      null.String `slk:"FileNames"`
+	DirectoryBase  null.String `slk:"DirectoryBase"`
+	Volume         null.String `slk:"Volume"`
+	Pitch          null.String `slk:"Pitch"`
+	PitchVariance  null.String `slk:"PitchVariance"`
+	Priority       null.String `slk:"Priority"`
+	Channel        null.String `slk:"Channel"`
+	Flags          null.String `slk:"Flags"`
+	MinDistance    null.String `slk:"MinDistance"`
+	MaxDistance    null.String `slk:"MaxDistance"`
+	DistanceCutoff null.String `slk:"DistanceCutoff"`
+	EAXFlags       null.String `slk:"EAXFlags"`
+	InBeta         null.String `slk:"InBeta"`
+	Version        null.String `slk:"version"`
+}
+
+type SLKAnimLookup struct {
+	AnimSoundEvent null.String `slk:"AnimSoundEvent"`
+	SoundLabel     null.String `slk:"SoundLabel"`
+	InBeta         null.String `slk:"InBeta"`
+}
+
+type NewSoundLabel struct {
+	SoundName     string
+	BaseSoundName null.String
+	FileNames     null.String
+	DirectoryBase null.String
+}
+
+// loadSoundInfo reads the animation sound tables that ship with the editor, tables in the input folder or
+// its UI\SoundInfo folder replace the rows they contain
+func loadSoundInfo(inputDirectory string, animSoundsFileInfo *FileInfo, animLookupsFileInfo *FileInfo) {
+	animSoundMap = make(map[string]*SLKAnimSound)
+	animLookupMap = make(map[string]*SLKAnimLookup)
+
+	loadBundledObjects(BUNDLED_SOUND_INFO_PATH+"/"+strings.ToLower(ANIM_SOUNDS_FILENAME), inputDirectory, soundInfoFolder, animSoundsFileInfo, animSoundMap, CATEGORY_ANIM_SOUNDS)
+	loadBundledObjects(BUNDLED_SOUND_INFO_PATH+"/"+strings.ToLower(ANIM_LOOKUPS_FILENAME), inputDirectory, soundInfoFolder, animLookupsFileInfo, animLookupMap, CATEGORY_ANIM_LOOKUPS)
+}
+
+// getSoundLabels returns the labels a unit sound field can be set to. The sound sets live in UnitAckSounds.slk
+// which doesn't ship with the editor so those are the ones the units already use
+func getSoundLabels(field string) ([]ListData, error) {
+	soundLabels := []ListData{}
+
+	switch field {
+	case SOUND_LABEL_FIELD_RANDOM:
+		for _, soundName := range sortedObjectKeys(animSoundMap) {
+			animSound := animSoundMap[soundName]
+			soundLabels = append(soundLabels, ListData{soundName, sylk.Unquote(animSound.FileNames.String), null.String{}})
+		}
+	case SOUND_SET_FIELD:
+		soundSets := make(map[string]int)
+		for _, unit := range unitMap {
+			if unit.UnitUI != nil && isSetValue(unit.UnitSound) {
+				soundSets[sylk.Unquote(unit.UnitSound.String)]++
+			}
+		}
+
+		for soundSet, count := range soundSets {
+			soundLabels = append(soundLabels, ListData{soundSet, fmt.Sprintf("%s (%d units)", soundSet, count), null.String{}})
+		}
+
+		sort.Slice(soundLabels, func(i, j int) bool {
+			return soundLabels[i].Id < soundLabels[j].Id
+		})
+	default:
+		return nil, fmt.Errorf("%s is not a sound label field", field)
+	}
+
+	return soundLabels, nil
+}
+
+// createSoundLabel adds a sound label to AnimSounds.slk, copying the settings of an existing label if one is given
+func createSoundLabel(newSoundLabel *NewSoundLabel) (*SLKAnimSound, error) {
+	if newSoundLabel.SoundName == "" || strings.ContainsAny(newSoundLabel.SoundName, "\";") {
+		return nil, fmt.Errorf("invalid sound label %q", newSoundLabel.SoundName)
+	}
+
+	if _, ok := animSoundMap[newSoundLabel.SoundName]; ok {
+		return nil, fmt.Errorf("sound label %s already exists", newSoundLabel.SoundName)
+	}
+
+	var animSound *SLKAnimSound
+	if newSoundLabel.BaseSoundName.Valid && newSoundLabel.BaseSoundName.String != "" {
+		base, ok := animSoundMap[newSoundLabel.BaseSoundName.String]
+		if !ok {
+			return nil, fmt.Errorf("sound label %s does not exist", newSoundLabel.BaseSoundName.String)
+		}
+
+		animSound = cloneObject(base).(*SLKAnimSound)
+	} else {
+		animSound = new(SLKAnimSound)
+	}
+
+	animSound.SoundName.SetValid(sylk.Quote(newSoundLabel.SoundName))
+	if newSoundLabel.FileNames.Valid {
+		animSound.FileNames.SetValid(sylk.Quote(newSoundLabel.FileNames.String))
+	}
+
+	if newSoundLabel.DirectoryBase.Valid {
+		animSound.DirectoryBase.SetValid(sylk.Quote(newSoundLabel.DirectoryBase.String))
+	}
+
+	animSoundMap[newSoundLabel.SoundName] = animSound
+	markDirty(CATEGORY_ANIM_SOUNDS, newSoundLabel.SoundName)
+
+	return animSound, nil
+}
+
+func loadAnimLookupListData() []ListData {
+	animLookupListData := []ListData{}
+	for _, animSoundEvent := range sortedObjectKeys(animLookupMap) {
+		animLookup := animLookupMap[animSoundEvent]
+		animLookupListData = append(animLookupListData, ListData{animSoundEvent, sylk.Unquote(animLookup.SoundLabel.String), null.String{}})
+	}
+
+	return animLookupListData
+}
+
+// saveSoundInfoToFile writes the animation sound tables that came from the input folder or have been edited to
+// the UI\SoundInfo folder where the game looks for them
+func saveSoundInfoToFile(location string) error {
+	tables := []struct {
+		fileName  string
+		objectMap interface{}
+		category  string
+	}{
+		{ANIM_SOUNDS_FILENAME, animSoundMap, CATEGORY_ANIM_SOUNDS},
+		{ANIM_LOOKUPS_FILENAME, animLookupMap, CATEGORY_ANIM_LOOKUPS},
+	}
+
+	soundInfoDirectory := filepath.Join(location, soundInfoFolder)
+	for _, table := range tables {
+		if !isTableToWrite(table.category) {
+			continue
+		}
+
+		err := os.MkdirAll(soundInfoDirectory, os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+		err = writeObjectsToSlkFile(table.objectMap, filepath.Join(soundInfoDirectory, table.fileName), table.category)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
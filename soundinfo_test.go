@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveSoundInfoWritesOnlyTablesThatChanged(t *testing.T) {
+	defer clearDirty(CATEGORY_ANIM_SOUNDS, CATEGORY_ANIM_LOOKUPS)
+	forgetSourceFiles()
+	loadSoundInfo("", &FileInfo{FileName: ANIM_SOUNDS_FILENAME}, &FileInfo{FileName: ANIM_LOOKUPS_FILENAME})
+	clearDirty(CATEGORY_ANIM_SOUNDS, CATEGORY_ANIM_LOOKUPS)
+	if len(animLookupMap) < 1 {
+		t.Fatal("the bundled animation lookups weren't read")
+	}
+
+	location := t.TempDir()
+	if err := saveSoundInfoToFile(location); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(location, soundInfoFolder)); !os.IsNotExist(err) {
+		t.Error("the bundled tables were written without any changes")
+	}
+
+	// Editing a lookup leaves AnimSounds.slk alone
+	if _, err := applySaveField(&SaveField{Id: sortedObjectKeys(animLookupMap)[0], Field: "AnimLookup-SoundLabel", Value: "\"HumanDissipate\""}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dirtyObjects(CATEGORY_ANIM_SOUNDS)) > 0 {
+		t.Error("editing a lookup marked a sound as edited")
+	}
+
+	if err := saveSoundInfoToFile(location); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(location, soundInfoFolder))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].Name() != ANIM_LOOKUPS_FILENAME {
+		t.Errorf("wrote %d files, want only %s", len(files), ANIM_LOOKUPS_FILENAME)
+	}
+}
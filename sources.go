@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"github.com/runi95/wts-parser/models"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+const (
+	SORT_KEY_ORIGINAL    = "original"
+	SORT_KEY_RAWCODE     = "rawcode"
+	NEW_FILE_LINE_ENDING = "\r\n"
+)
+
+// Every SLK and TXT file we've read or written is remembered by its lowercase file name. Saving merges what we
+// generate into the remembered file, which means that only the rows of objects that have been edited, added or
+// removed change while everything else, including columns and keys we don't know about, is kept as it was
+var sourceFiles = make(map[string]*sourceFile)
+
+/**
+*    PRIVATE STRUCTURES
+ */
+type sourceFile struct {
+	Name     string
+	Category string
+	Slk      *sylk.Document
+	Txt      *txtDocument
+}
+
+func forgetSourceFiles() {
+	sourceFiles = make(map[string]*sourceFile)
+}
+
+func rememberSourceFile(name string, category string, data []byte) {
+	source := &sourceFile{Name: name, Category: category}
+	if err := source.parse(data); err != nil {
+		log.Println(err)
+		return
+	}
+
+	sourceFiles[strings.ToLower(name)] = source
+}
+
+func (source *sourceFile) parse(data []byte) error {
+	if !strings.HasSuffix(strings.ToLower(source.Name), ".slk") {
+		source.Txt = parseTxtDocument(data)
+		return nil
+	}
+
+	document, err := sylk.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	source.Slk = document
+
+	return nil
+}
+
+// sort orders the rows or sections of the file by rawcode
+func (source *sourceFile) sort() ([]byte, error) {
+	if source.Txt != nil {
+		source.Txt.sortSections()
+		return source.Txt.bytes(), nil
+	}
+
+	if err := source.Slk.SortRows(); err != nil {
+		return nil, err
+	}
+
+	return source.Slk.Bytes(), nil
+}
+
+// normalizeLineEndings gives every line of a file the same line ending
+func normalizeLineEndings(data []byte, eol string) []byte {
+	data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+	data = bytes.Replace(data, []byte("\r"), []byte("\n"), -1)
+
+	return bytes.Replace(data, []byte("\n"), []byte(eol), -1)
+}
+
+// rememberInputFiles remembers the watched files of the input folder, all of them when no categories are given
+func rememberInputFiles(inputDirectory string, categories ...string) {
+	for _, file := range watchedInputFiles {
+		if (len(categories) > 0 && !containsString(categories, file.Category)) || !isObjectCategory(file.Category) {
+			continue
+		}
+
+		delete(sourceFiles, file.Name)
+		if fileBytes := readWatchedFile(inputDirectory, file); fileBytes != nil {
+			rememberSourceFile(file.Name, file.Category, fileBytes)
+		}
+	}
+}
+
+// readWatchedFile reads a watched file from the input folder or the folder the game keeps it in, and falls
+// back on the copy that ships with the editor
+func readWatchedFile(inputDirectory string, file watchedFile) []byte {
+	fileInfo := &FileInfo{FileName: file.Name}
+	if fileBytes := readInputFile(inputDirectory, fileInfo); fileBytes != nil {
+		return fileBytes
+	}
+
+	if folder := gameFolder(file.Category); folder != "" {
+		if flag, err := exists(filepath.Join(inputDirectory, folder)); err == nil && flag {
+			if fileBytes := readInputFile(filepath.Join(inputDirectory, folder), fileInfo); fileBytes != nil {
+				return fileBytes
+			}
+		}
+	}
+
+	if bundledName := bundledFile(file); bundledName != "" {
+		if fileBytes, err := readBundledFile(bundledName); err == nil {
+			return fileBytes
+		}
+	}
+
+	return nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeObjectFile writes a generated SLK or TXT file, merged into the file it was read from if we have it.
+// Files we don't have yet get the same line ending everywhere
+func writeObjectFile(path string, category string, generated []byte) error {
+	name := filepath.Base(path)
+	data := normalizeLineEndings(generated, NEW_FILE_LINE_ENDING)
+
+	source, ok := sourceFiles[strings.ToLower(name)]
+	if ok {
+		var err error
+		data, err = source.merge(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	if configuration.SortKey == SORT_KEY_RAWCODE {
+		if !ok {
+			source = &sourceFile{Name: name, Category: category}
+			if err := source.parse(data); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		data, err = source.sort()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := writeFileAtomically(path, data)
+	if err != nil {
+		return err
+	}
+
+	rememberSourceFile(name, category, data)
+
+	return nil
+}
+
+// writeGeneratedFiles moves the files the parser library generated into the output folder one by one so that
+// they're merged with the files they were read from
+func writeGeneratedFiles(generatedDirectory string, location string) error {
+	filesInDirectory, err := ioutil.ReadDir(generatedDirectory)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range filesInDirectory {
+		generated, err := ioutil.ReadFile(filepath.Join(generatedDirectory, file.Name()))
+		if err != nil {
+			return err
+		}
+
+		category := ""
+		for _, watchedFile := range watchedInputFiles {
+			if watchedFile.Name == strings.ToLower(file.Name()) {
+				category = watchedFile.Category
+			}
+		}
+
+		if category == "" {
+			err = writeFileAtomically(filepath.Join(location, file.Name()), generated)
+		} else {
+			err = writeObjectFile(filepath.Join(location, file.Name()), category, generated)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (source *sourceFile) merge(generated []byte) ([]byte, error) {
+	written := make(map[string]bool)
+	for _, other := range sourceFiles {
+		if other.Category == source.Category {
+			other.addObjectIds(written)
+		}
+	}
+
+	// The rows of an object are rewritten when it has been edited, removed or when we haven't written it before,
+	// rows of objects we don't load at all are left alone
+	dirty := dirtyObjects(source.Category)
+	touched := func(id string) bool {
+		return dirty[id] || !written[id]
+	}
+
+	if source.Slk != nil {
+		return source.mergeSlk(generated, touched)
+	}
+
+	return source.mergeTxt(generated, touched), nil
+}
+
+func (source *sourceFile) addObjectIds(ids map[string]bool) {
+	if source.Txt != nil {
+		order, _ := source.Txt.sections()
+		for _, section := range order {
+			ids[section.id] = true
+		}
+
+		return
+	}
+
+	_, rows := source.Slk.Size()
+	for y := 2; y <= rows; y++ {
+		if value, ok := source.Slk.Cell(1, y); ok {
+			ids[sylk.Unquote(value)] = true
+		}
+	}
+}
+
+func (source *sourceFile) objectMap() interface{} {
+	switch source.Category {
+	case CATEGORY_UNITS:
+		return unitMap
+	case CATEGORY_ITEMS:
+		return itemMap
+	case CATEGORY_ABILITIES:
+		return abilityMap
+	case CATEGORY_DESTRUCTABLES:
+		return destructableMap
+	case CATEGORY_DOODADS:
+		return doodadMap
+	case CATEGORY_SPLATS:
+		return splatMap
+	case CATEGORY_UBERSPLATS:
+		return uberSplatMap
+	case CATEGORY_SPAWNS:
+		return spawnMap
+	case CATEGORY_ANIM_SOUNDS:
+		return animSoundMap
+	case CATEGORY_ANIM_LOOKUPS:
+		return animLookupMap
+	}
+
+	return map[string]*models.SLKUnit{}
+}
+
+// keyValue returns the value an object has for a TXT key, ok is false for keys that don't belong to any field
+func (source *sourceFile) keyValue(id string, key string) (null.String, bool) {
+	object := reflect.ValueOf(source.objectMap()).MapIndex(reflect.ValueOf(id))
+	if !object.IsValid() || object.IsNil() {
+		return null.String{}, false
+	}
+
+	object = object.Elem()
+	for _, column := range objectColumns(object.Type()) {
+		if strings.EqualFold(column.Name, key) {
+			return object.Field(column.Field).Interface().(null.String), true
+		}
+	}
+
+	// The parser library finds fields by their title cased name, embedded structs that haven't been created
+	// would make FieldByName panic
+	for i := 0; i < object.NumField(); i++ {
+		if field := object.Field(i); field.Kind() == reflect.Ptr && field.IsNil() {
+			return null.String{}, false
+		}
+	}
+
+	field := object.FieldByName(strings.Title(strings.ToLower(key)))
+	if !field.IsValid() || field.Type() != nullStringType {
+		return null.String{}, false
+	}
+
+	return field.Interface().(null.String), true
+}
+
+func (source *sourceFile) mergeSlk(generated []byte, touched func(id string) bool) ([]byte, error) {
+	table, err := sylk.Read(generated)
+	if err != nil {
+		return nil, err
+	}
+
+	document := source.Slk
+	columns, rows := document.Size()
+
+	sourceColumns := make(map[string]int)
+	for x := 1; x <= columns; x++ {
+		if value, ok := document.Cell(x, 1); ok {
+			sourceColumns[strings.ToLower(sylk.Unquote(value))] = x
+		}
+	}
+
+	sourceRows := make(map[string]int)
+	for y := 2; y <= rows; y++ {
+		if value, ok := document.Cell(1, y); ok {
+			sourceRows[sylk.Unquote(value)] = y
+		}
+	}
+
+	generatedRows := make(map[string]bool)
+	for _, row := range table.Rows {
+		id := sylk.Unquote(row[0])
+		generatedRows[id] = true
+		if id == "" || !touched(id) {
+			continue
+		}
+
+		y, ok := sourceRows[id]
+		if !ok {
+			rows++
+			y = rows
+			sourceRows[id] = y
+			if err = document.SetCell(1, y, row[0]); err != nil {
+				return nil, err
+			}
+		}
+
+		for x, value := range row {
+			if x == 0 {
+				continue
+			}
+
+			if table.Columns[x] == "" {
+				continue
+			}
+
+			// An empty value only clears a cell when the object has the field and it's been cleared, columns
+			// the object doesn't have a field for keep what the source says
+			if value == "" {
+				if field, known := source.keyValue(id, table.Columns[x]); !known || field.Valid {
+					continue
+				}
+			}
+
+			sourceX, ok := sourceColumns[strings.ToLower(table.Columns[x])]
+			if !ok {
+				if value == "" {
+					continue
+				}
+
+				columns++
+				sourceX = columns
+				sourceColumns[strings.ToLower(table.Columns[x])] = sourceX
+				if err = document.SetCell(sourceX, 1, sylk.Quote(table.Columns[x])); err != nil {
+					return nil, err
+				}
+			}
+
+			// Numbers that haven't changed keep the way they were written
+			value = sylk.FormatNumber(value)
+			if current, _ := document.Cell(sourceX, y); sylk.FormatNumber(current) != value {
+				if err = document.SetCell(sourceX, y, value); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for id, y := range sourceRows {
+		if !generatedRows[id] && touched(id) {
+			if err = document.RemoveRow(y); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return document.Bytes(), nil
+}
+
+func (source *sourceFile) mergeTxt(generated []byte, touched func(id string) bool) []byte {
+	document := source.Txt
+	generatedDocument := parseTxtDocument(generated)
+	generatedOrder, generatedSections := generatedDocument.sections()
+
+	for _, generatedSection := range generatedOrder {
+		if !touched(generatedSection.id) {
+			continue
+		}
+
+		_, sections := document.sections()
+		section, ok := sections[generatedSection.id]
+		if !ok {
+			lines := []string{""}
+			if len(document.lines) < 1 || strings.TrimSpace(document.lines[len(document.lines)-1]) == "" {
+				lines = nil
+			}
+
+			lines = append(lines, generatedDocument.lines[generatedSection.header])
+			for line := generatedSection.header + 1; line <= generatedSection.lastKey; line++ {
+				if strings.TrimSpace(generatedDocument.lines[line]) != "" {
+					lines = append(lines, generatedDocument.lines[line])
+				}
+			}
+
+			document.insertLines(len(document.lines), lines...)
+			continue
+		}
+
+		for line := generatedSection.header + 1; line <= generatedSection.lastKey; line++ {
+			if strings.Index(generatedDocument.lines[line], "=") <= 0 {
+				continue
+			}
+
+			key, value := generatedDocument.value(line)
+			if sourceLine, ok := section.keys[strings.ToLower(key)]; ok {
+				if _, current := document.value(sourceLine); current != value {
+					document.setValue(sourceLine, value)
+				}
+			} else {
+				document.insertLines(section.lastKey+1, generatedDocument.lines[line])
+				_, sections = document.sections()
+				section = sections[generatedSection.id]
+			}
+		}
+
+		// Keys the object no longer has are removed, keys that don't belong to any field are left alone
+		for {
+			removed := false
+			for key, line := range section.keys {
+				if _, ok := generatedSection.keys[key]; ok {
+					continue
+				}
+
+				if value, known := source.keyValue(section.id, key); known && !value.Valid {
+					document.removeLines(line, line+1)
+					_, sections = document.sections()
+					section = sections[generatedSection.id]
+					removed = true
+					break
+				}
+			}
+
+			if !removed {
+				break
+			}
+		}
+	}
+
+	order, _ := document.sections()
+	for i := len(order) - 1; i >= 0; i-- {
+		section := order[i]
+		if _, ok := generatedSections[section.id]; !ok && touched(section.id) {
+			document.removeSection(section)
+		}
+	}
+
+	return document.bytes()
+}
+
+// saveWithLibrary lets the parser library generate its files in a temporary folder before they're merged
+// into the output folder
+func saveWithLibrary(location string, write func(generatedDirectory string)) error {
+	generatedDirectory, err := ioutil.TempDir("", VENDOR_NAME)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(generatedDirectory)
+
+	write(generatedDirectory)
+
+	return writeGeneratedFiles(generatedDirectory, location)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+const (
+	CATEGORY_SPLATS         = "splats"
+	CATEGORY_UBERSPLATS     = "ubersplats"
+	CATEGORY_SPAWNS         = "spawns"
+	SPLATS_FOLDER           = "Splats"
+	SPLAT_DATA_FILENAME     = "SplatData.slk"
+	UBERSPLAT_DATA_FILENAME = "UberSplatData.slk"
+	SPAWN_DATA_FILENAME     = "SpawnData.slk"
+	BUNDLED_SPLATS_PATH     = "resources/app/splats"
+)
+
+var (
+	splatMap     = make(map[string]*SLKSplat)
+	uberSplatMap = make(map[string]*SLKUberSplat)
+	spawnMap     = make(map[string]*SLKSpawn)
+
+	dirtySplats     = make(map[string]bool)
+	dirtyUberSplats = make(map[string]bool)
+	dirtySpawns     = make(map[string]bool)
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type SLKSplat struct {
+	Name            null.String `slk:"Name"`
+	Comment         null.String `slk:"comment"`
+	Dir             null.String `slk:"Dir"`
+	File            null.String `slk:"file"`
+	Rows            null.String `slk:"Rows"`
+	Columns         null.String `slk:"Columns"`
+	BlendMode       null.String `slk:"BlendMode"`
+	Scale           null.String `slk:"Scale"`
+	Lifespan        null.String `slk:"Lifespan"`
+	Decay           null.String `slk:"Decay"`
+	UVLifespanStart null.String `slk:"UVLifespanStart"`
+	UVLifespanEnd   null.String `slk:"UVLifespanEnd"`
+	LifespanRepeat  null.String `slk:"LifespanRepeat"`
+	UVDecayStart    null.String `slk:"UVDecayStart"`
+	UVDecayEnd      null.String `slk:"UVDecayEnd"`
+	DecayRepeat     null.String `slk:"DecayRepeat"`
+	StartR          null.String `slk:"StartR"`
+	StartG          null.String `slk:"StartG"`
+	StartB          null.String `slk:"StartB"`
+	StartA          null.String `slk:"StartA"`
+	MiddleR         null.String `slk:"MiddleR"`
+	MiddleG         null.String `slk:"MiddleG"`
+	MiddleB         null.String `slk:"MiddleB"`
+	MiddleA         null.String `slk:"MiddleA"`
+	EndR            null.String `slk:"EndR"`
+	EndG            null.String `slk:"EndG"`
+	EndB            null.String `slk:"EndB"`
+	EndA            null.String `slk:"EndA"`
+	Water           null.String `slk:"Water"`
+	Sound           null.String `slk:"Sound"`
+	Version         null.String `slk:"version"`
+}
+
+type SLKUberSplat struct {
+	Name      null.String `slk:"Name"`
+	Comment   null.String `slk:"comment"`
+	Dir       null.String `slk:"Dir"`
+	File      null.String `slk:"file"`
+	BlendMode null.String `slk:"BlendMode"`
+	Scale     null.String `slk:"Scale"`
+	BirthTime null.String `slk:"BirthTime"`
+	PauseTime null.String `slk:"PauseTime"`
+	Decay     null.String `slk:"Decay"`
+	StartR    null.String `slk:"StartR"`
+	StartG    null.String `slk:"StartG"`
+	StartB    null.String `slk:"StartB"`
+	StartA    null.String `slk:"StartA"`
+	MiddleR   null.String `slk:"MiddleR"`
+	MiddleG   null.String `slk:"MiddleG"`
+	MiddleB   null.String `slk:"MiddleB"`
+	MiddleA   null.String `slk:"MiddleA"`
+	EndR      null.String `slk:"EndR"`
+	EndG      null.String `slk:"EndG"`
+	EndB      null.String `slk:"EndB"`
+	EndA      null.String `slk:"EndA"`
+	Sound     null.String `slk:"Sound"`
+	Version   null.String `slk:"version"`
+	InBeta    null.String `slk:"InBeta"`
+}
+
+type SLKSpawn struct {
+	Name    null.String `slk:"Name"`
+	Model   null.String `slk:"Model"`
+	Version null.String `slk:"version"`
+	InBeta  null.String `slk:"InBeta"`
+}
+
+type SplatReferenceIssue struct {
+	UnitID  string
+	Field   string
+	Value   string
+	Message string
+}
+
+// loadSplats reads the splat tables that ship with the editor, tables in the input folder or its Splats
+// folder replace the rows they contain
+func loadSplats(inputDirectory string, splatDataFileInfo *FileInfo, uberSplatDataFileInfo *FileInfo, spawnDataFileInfo *FileInfo) {
+	splatMap = make(map[string]*SLKSplat)
+	uberSplatMap = make(map[string]*SLKUberSplat)
+	spawnMap = make(map[string]*SLKSpawn)
+
+	tables := []struct {
+		fileInfo  *FileInfo
+		objectMap interface{}
+		category  string
+	}{
+		{splatDataFileInfo, splatMap, CATEGORY_SPLATS},
+		{uberSplatDataFileInfo, uberSplatMap, CATEGORY_UBERSPLATS},
+		{spawnDataFileInfo, spawnMap, CATEGORY_SPAWNS},
+	}
+
+	for _, table := range tables {
+		bundledName := BUNDLED_SPLATS_PATH + "/" + strings.ToLower(table.fileInfo.FileName)
+		loadBundledObjects(bundledName, inputDirectory, SPLATS_FOLDER, table.fileInfo, table.objectMap, table.category)
+	}
+}
+
+func loadSplatListData(objectMap interface{}) []ListData {
+	listData := []ListData{}
+	for _, id := range sortedObjectKeys(objectMap) {
+		var comment string
+		switch object := reflect.ValueOf(objectMap).MapIndex(reflect.ValueOf(id)).Interface().(type) {
+		case *SLKSplat:
+			comment = object.Comment.String
+		case *SLKUberSplat:
+			comment = object.Comment.String
+		case *SLKSpawn:
+			comment = object.Model.String
+		}
+
+		listData = append(listData, ListData{id, sylk.Unquote(comment), null.String{}})
+	}
+
+	return listData
+}
+
+// validateSplatReferences finds units that use an ubersplat that doesn't exist
+func validateSplatReferences() []*SplatReferenceIssue {
+	issues := []*SplatReferenceIssue{}
+	for unitId, unit := range unitMap {
+		if unit.UnitUI == nil || !isSetValue(unit.UberSplat) {
+			continue
+		}
+
+		uberSplat := sylk.Unquote(unit.UberSplat.String)
+		if _, ok := uberSplatMap[uberSplat]; !ok {
+			issues = append(issues, &SplatReferenceIssue{unitId, "UberSplat", unit.UberSplat.String, fmt.Sprintf("ubersplat %s does not exist", uberSplat)})
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		return issues[i].UnitID < issues[j].UnitID
+	})
+
+	return issues
+}
+
+// saveSplatsToFile writes the splat tables that came from the input folder or have been edited to the Splats
+// folder where the game looks for them
+func saveSplatsToFile(location string) error {
+	tables := []struct {
+		fileName  string
+		objectMap interface{}
+		category  string
+	}{
+		{SPLAT_DATA_FILENAME, splatMap, CATEGORY_SPLATS},
+		{UBERSPLAT_DATA_FILENAME, uberSplatMap, CATEGORY_UBERSPLATS},
+		{SPAWN_DATA_FILENAME, spawnMap, CATEGORY_SPAWNS},
+	}
+
+	splatsDirectory := filepath.Join(location, SPLATS_FOLDER)
+	for _, table := range tables {
+		if !isTableToWrite(table.category) {
+			continue
+		}
+
+		err := os.MkdirAll(splatsDirectory, os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+		err = writeObjectsToSlkFile(table.objectMap, filepath.Join(splatsDirectory, table.fileName), table.category)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveSplatsWritesOnlyTablesThatChanged(t *testing.T) {
+	defer clearDirty(CATEGORY_SPLATS, CATEGORY_UBERSPLATS, CATEGORY_SPAWNS)
+	forgetSourceFiles()
+	loadSplats("", &FileInfo{FileName: SPLAT_DATA_FILENAME}, &FileInfo{FileName: UBERSPLAT_DATA_FILENAME}, &FileInfo{FileName: SPAWN_DATA_FILENAME})
+	clearDirty(CATEGORY_SPLATS, CATEGORY_UBERSPLATS, CATEGORY_SPAWNS)
+	if len(splatMap) < 1 {
+		t.Fatal("the bundled splats weren't read")
+	}
+
+	location := t.TempDir()
+	if err := saveSplatsToFile(location); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(location, SPLATS_FOLDER)); !os.IsNotExist(err) {
+		t.Error("the bundled tables were written without any changes")
+	}
+
+	markDirty(CATEGORY_UBERSPLATS, sortedObjectKeys(uberSplatMap)[0])
+	if err := saveSplatsToFile(location); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(location, SPLATS_FOLDER))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].Name() != UBERSPLAT_DATA_FILENAME {
+		t.Errorf("wrote %d files, want only %s", len(files), UBERSPLAT_DATA_FILENAME)
+	}
+}
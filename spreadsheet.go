@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"github.com/asticode/go-astilectron-demo/xlsx"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+const (
+	SPREADSHEET_FORMAT_CSV      = "csv"
+	SPREADSHEET_FORMAT_XLSX     = "xlsx"
+	SPREADSHEET_COLUMNS_ALL     = "all"
+	SPREADSHEET_COLUMNS_ENABLED = "enabled"
+	SPREADSHEET_ID_COLUMN       = "Id"
+)
+
+// The categories that can be balanced in a spreadsheet, each one gets a sheet named after it and its fields are
+// saved with the prefix saveField uses
+var spreadsheetCategories = []spreadsheetCategory{
+	{CATEGORY_UNITS, "Unit", func() interface{} { return unitMap }, []string{"UnitID", "UnitUIID", "UnitWeapID", "UnitBalanceID", "UnitAbilID", "UnitFuncId", "UnitStringId"}},
+	{CATEGORY_ITEMS, "Item", func() interface{} { return itemMap }, []string{"ItemID", "ItemFuncId", "ItemStringId"}},
+	{CATEGORY_ABILITIES, "Ability", func() interface{} { return abilityMap }, []string{"Alias", "AbilityFuncId", "AbilityStringId"}},
+}
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type SpreadsheetExport struct {
+	Path       string
+	Format     string
+	Categories []string
+	ColumnSet  string
+	Columns    []string
+}
+
+type SpreadsheetImport struct {
+	Path     string
+	Category string
+}
+
+type SpreadsheetChange struct {
+	Sheet    string
+	Cell     string
+	Id       string
+	Field    string
+	OldValue string
+	NewValue string
+}
+
+type SpreadsheetIssue struct {
+	Sheet   string
+	Cell    string
+	Value   string
+	Message string
+}
+
+type SpreadsheetImportPreview struct {
+	Changes []*SpreadsheetChange
+	Issues  []*SpreadsheetIssue
+}
+
+/**
+*    PRIVATE STRUCTURES
+ */
+type spreadsheetCategory struct {
+	Name     string
+	Prefix   string
+	Objects  func() interface{}
+	IdFields []string
+}
+
+func getSpreadsheetCategory(name string) (spreadsheetCategory, bool) {
+	for _, category := range spreadsheetCategories {
+		if strings.EqualFold(category.Name, name) {
+			return category, true
+		}
+	}
+
+	return spreadsheetCategory{}, false
+}
+
+// isSaved tells whether edits of the category make it into the output folder, items are read but never written
+func (category spreadsheetCategory) isSaved() bool {
+	return category.Name != CATEGORY_ITEMS
+}
+
+// fields returns the null.String fields of the category's objects other than the ids
+func (category spreadsheetCategory) fields() []reflect.StructField {
+	var fields []reflect.StructField
+	for _, field := range category.allFields() {
+		if !containsString(category.IdFields, field.Name) {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// allFields returns the null.String fields of the category's objects in the order they're declared in, fields
+// that several embedded structs share are left out since saveField can't tell them apart
+func (category spreadsheetCategory) allFields() []reflect.StructField {
+	objectType := reflect.TypeOf(category.Objects()).Elem().Elem()
+
+	var fields []reflect.StructField
+	for _, field := range reflect.VisibleFields(objectType) {
+		if field.Type != nullStringType {
+			continue
+		}
+
+		if found, ok := objectType.FieldByName(field.Name); !ok || !reflect.DeepEqual(found.Index, field.Index) {
+			continue
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// columns returns the fields that go into the category's sheet, either the ones asked for by name, every field
+// or the fields that haven't been disabled in the editor
+func (category spreadsheetCategory) columns(export *SpreadsheetExport) []reflect.StructField {
+	var disabled []string
+	if export.ColumnSet == SPREADSHEET_COLUMNS_ENABLED {
+		disabled = loadDisabledInputs()
+	}
+
+	var columns []reflect.StructField
+	for _, field := range category.fields() {
+		if len(export.Columns) > 0 && !containsString(export.Columns, field.Name) && !containsString(export.Columns, category.Prefix+"-"+field.Name) {
+			continue
+		}
+
+		if containsString(disabled, field.Name) || containsString(disabled, category.Prefix+"-"+field.Name) {
+			continue
+		}
+
+		columns = append(columns, field)
+	}
+
+	return columns
+}
+
+// loadDisabledInputs returns the inputs that have been disabled in the editor
+func loadDisabledInputs() []string {
+	config := loadConfigFile(DISABLED_INPUTS_FILENAME)
+	if config == nil {
+		return defaultDisabledUnits
+	}
+
+	file, err := ioutil.ReadFile(filepath.Join(config.Path, DISABLED_INPUTS_FILENAME))
+	if err != nil {
+		return defaultDisabledUnits
+	}
+
+	var disabledInputs []string
+	if err = json.Unmarshal(file, &disabledInputs); err != nil {
+		return defaultDisabledUnits
+	}
+
+	disabledInputs, _ = mergeDisabledInputs(disabledInputs)
+
+	return disabledInputs
+}
+
+// objectField returns a field of an object, ok is false when the embedded struct holding it hasn't been created
+func objectField(object reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 {
+			if object.Kind() == reflect.Ptr {
+				if object.IsNil() {
+					return reflect.Value{}, false
+				}
+
+				object = object.Elem()
+			}
+		}
+
+		object = object.Field(x)
+	}
+
+	return object, true
+}
+
+func fieldText(object reflect.Value, field reflect.StructField) (string, bool) {
+	value, ok := objectField(object, field.Index)
+	if !ok {
+		return "", false
+	}
+
+	if nullString := value.Interface().(null.String); nullString.Valid {
+		return nullString.String, true
+	}
+
+	return "", true
+}
+
+// buildSpreadsheet returns a sheet per category with the object id in the first column
+func buildSpreadsheet(export *SpreadsheetExport) ([]*xlsx.Sheet, error) {
+	categories := export.Categories
+	if len(categories) < 1 {
+		for _, category := range spreadsheetCategories {
+			categories = append(categories, category.Name)
+		}
+	}
+
+	var sheets []*xlsx.Sheet
+	for _, name := range categories {
+		category, ok := getSpreadsheetCategory(name)
+		if !ok {
+			return nil, fmt.Errorf("%s can't be exported to a spreadsheet", name)
+		}
+
+		columns := category.columns(export)
+		header := []string{SPREADSHEET_ID_COLUMN}
+		for _, column := range columns {
+			header = append(header, column.Name)
+		}
+
+		sheet := &xlsx.Sheet{Name: category.Name, Rows: [][]string{header}}
+		objects := reflect.ValueOf(category.Objects())
+		for _, id := range sortedObjectKeys(category.Objects()) {
+			object := objects.MapIndex(reflect.ValueOf(id)).Elem()
+
+			row := []string{id}
+			for _, column := range columns {
+				text, _ := fieldText(object, column)
+				row = append(row, text)
+			}
+
+			sheet.Rows = append(sheet.Rows, row)
+		}
+
+		sheets = append(sheets, sheet)
+	}
+
+	return sheets, nil
+}
+
+// exportSpreadsheet writes a workbook to the path or, since a CSV file only holds one sheet, a CSV file per
+// category to the folder at the path. The files that were written are returned
+func exportSpreadsheet(export *SpreadsheetExport) ([]string, error) {
+	format := strings.ToLower(export.Format)
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(export.Path)), ".")
+	}
+
+	if format != SPREADSHEET_FORMAT_XLSX {
+		format = SPREADSHEET_FORMAT_CSV
+	}
+
+	sheets, err := buildSpreadsheet(export)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == SPREADSHEET_FORMAT_XLSX {
+		var buffer bytes.Buffer
+		if err = xlsx.Write(&buffer, sheets); err != nil {
+			return nil, err
+		}
+
+		return []string{export.Path}, writeFileAtomically(export.Path, buffer.Bytes())
+	}
+
+	// A path that names a CSV file takes a single category
+	isFile := strings.EqualFold(filepath.Ext(export.Path), "."+SPREADSHEET_FORMAT_CSV)
+	if isFile && len(sheets) != 1 {
+		return nil, fmt.Errorf("a CSV file only holds one category, export %d categories to a folder instead", len(sheets))
+	} else if !isFile {
+		if err = os.MkdirAll(export.Path, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	var files []string
+	for _, sheet := range sheets {
+		var buffer bytes.Buffer
+		writer := csv.NewWriter(&buffer)
+		if err = writer.WriteAll(sheet.Rows); err != nil {
+			return nil, err
+		}
+
+		path := export.Path
+		if !isFile {
+			path = filepath.Join(export.Path, sheet.Name+"."+SPREADSHEET_FORMAT_CSV)
+		}
+
+		if err = writeFileAtomically(path, buffer.Bytes()); err != nil {
+			return nil, err
+		}
+
+		files = append(files, path)
+	}
+
+	return files, nil
+}
+
+// readSpreadsheet reads a workbook, a CSV file or a folder of CSV files. CSV files are named after their category
+// unless a category is given
+func readSpreadsheet(path string, category string) ([]*xlsx.Sheet, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() && strings.EqualFold(filepath.Ext(path), "."+SPREADSHEET_FORMAT_XLSX) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return xlsx.Read(data)
+	}
+
+	paths := []string{path}
+	if info.IsDir() {
+		if paths, err = filepath.Glob(filepath.Join(path, "*."+SPREADSHEET_FORMAT_CSV)); err != nil {
+			return nil, err
+		}
+	}
+
+	var sheets []*xlsx.Sheet
+	for _, csvPath := range paths {
+		data, err := ioutil.ReadFile(csvPath)
+		if err != nil {
+			return nil, err
+		}
+
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filepath.Base(csvPath), err)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(csvPath), filepath.Ext(csvPath))
+		if category != "" && !info.IsDir() {
+			name = category
+		}
+		sheets = append(sheets, &xlsx.Sheet{Name: name, Rows: rows})
+	}
+
+	return sheets, nil
+}
+
+func cellName(x int, y int) string {
+	return xlsx.ColumnName(x) + strconv.Itoa(y+1)
+}
+
+// previewSpreadsheet compares the sheets with the objects and returns the cells that would change along with
+// the cells that can't be imported
+func previewSpreadsheet(sheets []*xlsx.Sheet) *SpreadsheetImportPreview {
+	preview := &SpreadsheetImportPreview{Changes: []*SpreadsheetChange{}, Issues: []*SpreadsheetIssue{}}
+	addIssue := func(sheet *xlsx.Sheet, x int, y int, value string, format string, a ...interface{}) {
+		preview.Issues = append(preview.Issues, &SpreadsheetIssue{sheet.Name, cellName(x, y), value, fmt.Sprintf(format, a...)})
+	}
+
+	for _, sheet := range sheets {
+		category, ok := getSpreadsheetCategory(sheet.Name)
+		if !ok {
+			preview.Issues = append(preview.Issues, &SpreadsheetIssue{Sheet: sheet.Name, Message: "there is no category with this name"})
+			continue
+		}
+
+		if len(sheet.Rows) < 1 {
+			continue
+		}
+
+		fields := make(map[string]reflect.StructField)
+		for _, field := range category.fields() {
+			fields[strings.ToLower(field.Name)] = field
+		}
+
+		idColumn := -1
+		columns := make(map[int]reflect.StructField)
+		for x, name := range sheet.Rows[0] {
+			name = strings.TrimPrefix(strings.TrimSpace(name), category.Prefix+"-")
+			if name == "" {
+				continue
+			}
+
+			if strings.EqualFold(name, SPREADSHEET_ID_COLUMN) {
+				idColumn = x
+			} else if field, ok := fields[strings.ToLower(name)]; ok {
+				columns[x] = field
+			} else {
+				addIssue(sheet, x, 0, name, "%s is not a field", name)
+			}
+		}
+
+		if idColumn < 0 {
+			preview.Issues = append(preview.Issues, &SpreadsheetIssue{Sheet: sheet.Name, Message: "the sheet has no Id column"})
+			continue
+		}
+
+		objects := reflect.ValueOf(category.Objects())
+		seen := make(map[string]int)
+		for y, row := range sheet.Rows[1:] {
+			y++
+			if idColumn >= len(row) || strings.TrimSpace(row[idColumn]) == "" {
+				continue
+			}
+
+			id := strings.TrimSpace(row[idColumn])
+			if first, ok := seen[id]; ok {
+				addIssue(sheet, idColumn, y, id, "%s is already on row %d", id, first+1)
+				continue
+			}
+
+			seen[id] = y
+
+			object := objects.MapIndex(reflect.ValueOf(id))
+			if !object.IsValid() || object.IsNil() {
+				addIssue(sheet, idColumn, y, id, "%s does not exist", id)
+				continue
+			}
+
+			for x, value := range row {
+				field, ok := columns[x]
+				if !ok {
+					continue
+				}
+
+				current, ok := fieldText(object.Elem(), field)
+				if !ok {
+					addIssue(sheet, x, y, value, "%s does not have %s", id, field.Name)
+					continue
+				}
+
+				if value == current || sylk.FormatNumber(value) == sylk.FormatNumber(current) || (current == "" && isNullFieldValue(value)) {
+					continue
+				}
+
+				if strings.ContainsAny(value, "\r\n") {
+					addIssue(sheet, x, y, value, "values can't span several lines, use |n for line breaks")
+					continue
+				}
+
+				if _, err := sylk.Number(current); err == nil && !isNullFieldValue(value) {
+					if _, err = sylk.Number(value); err != nil {
+						addIssue(sheet, x, y, value, "%s has to be a number", field.Name)
+						continue
+					}
+				}
+
+				if !category.isSaved() {
+					addIssue(sheet, x, y, value, "%s can't be saved, the change would be lost", category.Name)
+					continue
+				}
+
+				preview.Changes = append(preview.Changes, &SpreadsheetChange{sheet.Name, cellName(x, y), id, category.Prefix + "-" + field.Name, current, value})
+			}
+		}
+	}
+
+	return preview
+}
+
+// importSpreadsheet applies the changes of a spreadsheet the same way saveField does, cells that can't be
+// imported are left out and returned as issues
+func importSpreadsheet(sheets []*xlsx.Sheet) *SpreadsheetImportPreview {
+	preview := previewSpreadsheet(sheets)
+
+	var applied []*SpreadsheetChange
+	for _, change := range preview.Changes {
+		saved, err := applySaveField(&SaveField{Id: change.Id, Field: change.Field, Value: change.NewValue})
+		if err == nil && !saved {
+			err = fmt.Errorf("%s does not exist", change.Id)
+		}
+
+		if err != nil {
+			preview.Issues = append(preview.Issues, &SpreadsheetIssue{change.Sheet, change.Cell, change.NewValue, err.Error()})
+			continue
+		}
+
+		applied = append(applied, change)
+	}
+
+	preview.Changes = append([]*SpreadsheetChange{}, applied...)
+
+	return preview
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/asticode/go-astilectron-demo/xlsx"
+	"github.com/runi95/wts-parser/models"
+)
+
+// useSpreadsheetObjects swaps in a couple of units and an item, the returned function puts the originals back
+func useSpreadsheetObjects() func() {
+	previousUnits, previousItems, previousAbilities, previousDirtyUnits := unitMap, itemMap, abilityMap, dirtyUnits
+
+	unitMap = map[string]*models.SLKUnit{"h000": newTestUnit("420"), "h001": newTestUnit("425")}
+	unitMap["h001"].UnitBalance.Goldcost.SetValid("135")
+	unitMap["h001"].UnitString.Ubertip.SetValid("Guards the \"gate\", <AHbz,DataA1> & more|n|cffffcc00Level 1|r")
+	item := newObject(reflect.TypeOf(models.SLKItem{})).Interface().(*models.SLKItem)
+	item.ItemData.Goldcost.SetValid("100")
+	itemMap = map[string]*models.SLKItem{"I000": item}
+	abilityMap = map[string]*models.SLKAbility{}
+	dirtyUnits = make(map[string]bool)
+
+	return func() {
+		unitMap, itemMap, abilityMap, dirtyUnits = previousUnits, previousItems, previousAbilities, previousDirtyUnits
+	}
+}
+
+func trimRow(row []string) []string {
+	for len(row) > 0 && row[len(row)-1] == "" {
+		row = row[:len(row)-1]
+	}
+
+	return row
+}
+
+func TestBuildSpreadsheet(t *testing.T) {
+	defer useSpreadsheetObjects()()
+
+	sheets, err := buildSpreadsheet(&SpreadsheetExport{Categories: []string{CATEGORY_UNITS}, Columns: []string{"Goldcost", "Unit-HP"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Columns keep the order the fields are declared in
+	expected := []*xlsx.Sheet{{Name: CATEGORY_UNITS, Rows: [][]string{
+		{SPREADSHEET_ID_COLUMN, "Goldcost", "HP"},
+		{"h000", "", "420"},
+		{"h001", "135", "425"},
+	}}}
+
+	if len(sheets) != 1 || !reflect.DeepEqual(sheets[0], expected[0]) {
+		t.Errorf("got %+v, expected %+v", sheets[0], expected[0])
+	}
+
+	if _, err = buildSpreadsheet(&SpreadsheetExport{Categories: []string{CATEGORY_DOODADS}}); err == nil {
+		t.Errorf("doodads were exported to a spreadsheet")
+	}
+}
+
+func TestSpreadsheetRoundTrip(t *testing.T) {
+	defer useSpreadsheetObjects()()
+
+	directory := t.TempDir()
+	tests := []struct {
+		name       string
+		export     *SpreadsheetExport
+		importPath string
+		category   string
+	}{
+		{"xlsx", &SpreadsheetExport{Path: filepath.Join(directory, "balance.xlsx")}, filepath.Join(directory, "balance.xlsx"), ""},
+		{"csv folder", &SpreadsheetExport{Path: filepath.Join(directory, "csv"), Format: SPREADSHEET_FORMAT_CSV}, filepath.Join(directory, "csv"), ""},
+		{"csv file", &SpreadsheetExport{Path: filepath.Join(directory, "balance.csv"), Categories: []string{CATEGORY_UNITS}}, filepath.Join(directory, "balance.csv"), CATEGORY_UNITS},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expected, err := buildSpreadsheet(test.export)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = exportSpreadsheet(test.export); err != nil {
+				t.Fatal(err)
+			}
+
+			sheets, err := readSpreadsheet(test.importPath, test.category)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Workbooks leave out empty cells at the end of a row and a folder of CSV files is read by file name
+			if len(sheets) != len(expected) {
+				t.Fatalf("read %d sheets, expected %d", len(sheets), len(expected))
+			}
+
+			for _, expectedSheet := range expected {
+				var sheet *xlsx.Sheet
+				for _, readSheet := range sheets {
+					if readSheet.Name == expectedSheet.Name {
+						sheet = readSheet
+					}
+				}
+
+				if sheet == nil || len(sheet.Rows) != len(expectedSheet.Rows) {
+					t.Errorf("%s wasn't read back with %d rows", expectedSheet.Name, len(expectedSheet.Rows))
+					continue
+				}
+
+				for y, row := range sheet.Rows {
+					if !reflect.DeepEqual(trimRow(row), trimRow(expectedSheet.Rows[y])) {
+						t.Errorf("read %q on row %d of %s, expected %q", row, y+1, sheet.Name, expectedSheet.Rows[y])
+					}
+				}
+			}
+
+			// Reading back what was just written changes nothing
+			if preview := previewSpreadsheet(sheets); len(preview.Changes) > 0 || len(preview.Issues) > 0 {
+				t.Errorf("got %d changes and %d issues", len(preview.Changes), len(preview.Issues))
+			}
+		})
+	}
+
+	if _, err := exportSpreadsheet(&SpreadsheetExport{Path: filepath.Join(directory, "all.csv")}); err == nil {
+		t.Errorf("several categories were written to a single CSV file")
+	}
+}
+
+func TestPreviewSpreadsheet(t *testing.T) {
+	defer useSpreadsheetObjects()()
+
+	sheets := []*xlsx.Sheet{
+		{Name: "Units", Rows: [][]string{
+			{"Id", "Unit-HP", "Goldcost", "Speed of light"},
+			{"h000", "450", "", ""},
+			{"h001", "425.0", "many", ""},
+			{"h001", "1", "", ""},
+			{"h999", "1", "", ""},
+			{"", "1", "", ""},
+			{"h000", "Line one\nLine two", "", ""},
+		}},
+		{Name: "Items", Rows: [][]string{
+			{"Id", "Goldcost"},
+			{"I000", "125"},
+		}},
+		{Name: "Heroes", Rows: [][]string{{"Id"}}},
+		{Name: "abilities", Rows: [][]string{{"HP"}}},
+	}
+
+	preview := previewSpreadsheet(sheets)
+
+	expectedChanges := []*SpreadsheetChange{{"Units", "B2", "h000", "Unit-HP", "420", "450"}}
+	if !reflect.DeepEqual(preview.Changes, expectedChanges) {
+		t.Errorf("got changes %v, expected %v", preview.Changes, expectedChanges)
+	}
+
+	expectedIssues := []*SpreadsheetIssue{
+		{"Units", "D1", "Speed of light", "Speed of light is not a field"},
+		{"Units", "C3", "many", "Goldcost has to be a number"},
+		{"Units", "A4", "h001", "h001 is already on row 3"},
+		{"Units", "A5", "h999", "h999 does not exist"},
+		{"Units", "A7", "h000", "h000 is already on row 2"},
+		{"Items", "B2", "125", "items can't be saved, the change would be lost"},
+		{Sheet: "Heroes", Message: "there is no category with this name"},
+		{"abilities", "A1", "HP", "HP is not a field"},
+		{Sheet: "abilities", Message: "the sheet has no Id column"},
+	}
+
+	if !reflect.DeepEqual(preview.Issues, expectedIssues) {
+		t.Errorf("got issues:")
+		for _, issue := range preview.Issues {
+			t.Errorf("%+v", issue)
+		}
+	}
+}
+
+func TestImportSpreadsheet(t *testing.T) {
+	defer useSpreadsheetObjects()()
+
+	preview := importSpreadsheet([]*xlsx.Sheet{
+		{Name: "Units", Rows: [][]string{{"Id", "HP"}, {"h000", "450"}}},
+		{Name: "Items", Rows: [][]string{{"Id", "Goldcost"}, {"I000", "125"}}},
+	})
+
+	if len(preview.Changes) != 1 || len(preview.Issues) != 1 {
+		t.Fatalf("got %d changes and %d issues", len(preview.Changes), len(preview.Issues))
+	}
+
+	if hp := unitMap["h000"].UnitBalance.HP.String; hp != "450" {
+		t.Errorf("h000 has %s HP", hp)
+	}
+
+	if !dirtyUnits["h000"] {
+		t.Errorf("h000 wasn't marked as edited")
+	}
+
+	if gold := itemMap["I000"].ItemData.Goldcost.String; gold != "100" {
+		t.Errorf("I000 costs %s gold", gold)
+	}
+}
@@ -0,0 +1,613 @@
+package sylk
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Record is a single line of a SYLK file, the first field tells what kind of record it is. Records that haven't
+// been changed are written back exactly the way they were read
+type Record struct {
+	Type   string
+	Fields []string
+
+	raw      string
+	eol      string
+	modified bool
+
+	// The cell a C or F record points at, records after the E record and other kinds of records point at 0,0
+	x, y int
+
+	// Records are linked to their neighbours so that cells can be added and removed without moving the others
+	prev, next *Record
+}
+
+// Document holds every record of a SYLK file so that it can be edited cell by cell and written back without
+// touching anything that wasn't edited
+type Document struct {
+	first, last *Record
+
+	// The C record of every cell by row and column, kept up to date as cells are added and removed
+	cells map[int]map[int]*Record
+
+	// The B records, which hold the size of the sheet
+	bounds []*Record
+
+	// The line ending most records use, which is what new records get
+	eol string
+
+	// The size of the sheet, only worked out again when a cell on its edge has been removed
+	maxX, maxY int
+	sized      bool
+}
+
+// Parse reads every record of a SYLK file
+func Parse(data []byte) (*Document, error) {
+	document := &Document{}
+
+	for len(data) > 0 {
+		end := bytes.IndexAny(data, "\r\n")
+		var line, eol string
+		if end < 0 {
+			line, data = string(data), nil
+		} else {
+			line = string(data[:end])
+			if data[end] == '\r' && end+1 < len(data) && data[end+1] == '\n' {
+				eol, data = "\r\n", data[end+2:]
+			} else {
+				eol, data = string(data[end]), data[end+1:]
+			}
+		}
+
+		fields := splitRecord(line)
+		document.link(document.last, &Record{Type: fields[0], Fields: fields[1:], raw: line, eol: eol})
+	}
+
+	if err := document.index(); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// index walks the records the way a reader would, C and F records without X or Y keep the previous value. This
+// is only done once a document has been read or reordered, edits keep the index up to date as they go
+func (document *Document) index() error {
+	document.cells = make(map[int]map[int]*Record)
+	document.bounds = nil
+	document.sized = false
+
+	counts := make(map[string]int)
+	x, y := 1, 1
+	ended := false
+	line := 1
+	for record := document.first; record != nil; record = record.next {
+		record.x, record.y = 0, 0
+		if record.eol != "" {
+			counts[record.eol]++
+		}
+
+		if ended {
+			line++
+			continue
+		}
+
+		switch record.Type {
+		case "E":
+			ended = true
+		case "B":
+			document.bounds = append(document.bounds, record)
+		case "C", "F":
+			for _, field := range record.Fields {
+				if field == "" || (field[0] != 'X' && field[0] != 'Y') {
+					continue
+				}
+
+				n, err := strconv.Atoi(field[1:])
+				if err != nil || n < 1 {
+					return fmt.Errorf("line %d has an invalid coordinate %q", line, field)
+				}
+
+				if field[0] == 'X' {
+					x = n
+				} else {
+					y = n
+				}
+			}
+
+			record.x, record.y = x, y
+			if _, ok := record.Field('K'); ok && record.Type == "C" {
+				document.addCell(record)
+			}
+		}
+
+		line++
+	}
+
+	document.eol = "\r\n"
+	for _, candidate := range []string{"\n", "\r"} {
+		if counts[candidate] > counts[document.eol] {
+			document.eol = candidate
+		}
+	}
+
+	return nil
+}
+
+// Records returns every record of the document in the order they're written
+func (document *Document) Records() []*Record {
+	var records []*Record
+	for record := document.first; record != nil; record = record.next {
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// Bytes returns the document as a SYLK file
+func (document *Document) Bytes() []byte {
+	var buffer bytes.Buffer
+	document.WriteTo(&buffer)
+
+	return buffer.Bytes()
+}
+
+// WriteTo writes the document as a SYLK file
+func (document *Document) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for record := document.first; record != nil; record = record.next {
+		n, err := io.WriteString(w, record.String()+record.eol)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// Size returns the number of columns and rows that have cells
+func (document *Document) Size() (int, int) {
+	if document.sized {
+		return document.maxX, document.maxY
+	}
+
+	document.maxX, document.maxY = 0, 0
+	for y, row := range document.cells {
+		for x := range row {
+			if x > document.maxX {
+				document.maxX = x
+			}
+		}
+
+		if y > document.maxY {
+			document.maxY = y
+		}
+	}
+
+	document.sized = true
+
+	return document.maxX, document.maxY
+}
+
+// Cell returns the value of a cell exactly as it appears in the file
+func (document *Document) Cell(x int, y int) (string, bool) {
+	record, ok := document.cells[y][x]
+	if !ok {
+		return "", false
+	}
+
+	return record.Field('K')
+}
+
+// SetCell changes the value of a cell, a cell that doesn't exist yet is added after the cell before it and
+// an empty value removes the cell
+func (document *Document) SetCell(x int, y int, value string) error {
+	if x < 1 || y < 1 {
+		return fmt.Errorf("invalid cell %d,%d", x, y)
+	}
+
+	if record, ok := document.cells[y][x]; ok {
+		if value == "" {
+			document.remove(record)
+			return nil
+		}
+
+		if current, _ := record.Field('K'); current != value {
+			record.SetField('K', value)
+		}
+
+		return nil
+	}
+
+	if value == "" {
+		return nil
+	}
+
+	record := &Record{Type: "C", Fields: []string{"X" + strconv.Itoa(x), "Y" + strconv.Itoa(y), "K" + value}, modified: true, x: x, y: y}
+	document.insert(document.predecessor(x, y), record)
+	document.addCell(record)
+	document.growBounds()
+
+	return nil
+}
+
+// RemoveRow removes every cell of a row, the rows below it keep their numbers
+func (document *Document) RemoveRow(y int) error {
+	for _, record := range document.cells[y] {
+		document.remove(record)
+	}
+
+	return nil
+}
+
+// predecessor finds the record a new cell should follow, files are written row by row so that's the last cell
+// before it along with any formatting records or cells without a value that come before it as well. Nil means
+// that the cell goes first
+func (document *Document) predecessor(x int, y int) *Record {
+	var previous *Record
+	for column, record := range document.cells[y] {
+		if column < x && (previous == nil || column > previous.x) {
+			previous = record
+		}
+	}
+
+	// Otherwise it follows the last cell of the closest row above it
+	_, maxY := document.Size()
+	if maxY > y-1 {
+		maxY = y - 1
+	}
+
+	for row := maxY; previous == nil && row >= 1; row-- {
+		for _, record := range document.cells[row] {
+			if previous == nil || record.x > previous.x {
+				previous = record
+			}
+		}
+	}
+
+	// Or the records that start the file when there are no cells before it at all
+	if previous == nil {
+		for record := document.first; record != nil; record = record.next {
+			if record.Type == "C" || record.Type == "F" || record.Type == "E" {
+				return record.prev
+			}
+		}
+
+		return document.last
+	}
+
+	for next := previous.next; next != nil && (next.Type == "C" || next.Type == "F"); next = next.next {
+		if next.y > y || (next.y == y && next.x >= x) {
+			break
+		}
+
+		previous = next
+	}
+
+	return previous
+}
+
+// insert adds a record after another one, nil adds it at the start
+func (document *Document) insert(previous *Record, record *Record) {
+	next := document.first
+	if previous != nil {
+		next = previous.next
+	}
+
+	document.pin(next)
+
+	// Only the last record can be missing its line ending, the new one takes its place
+	record.eol = document.eol
+	if previous != nil && previous.eol == "" {
+		previous.eol, record.eol = record.eol, ""
+	}
+
+	document.link(previous, record)
+}
+
+// link puts a record after another one without looking at its contents, nil puts it first
+func (document *Document) link(previous *Record, record *Record) {
+	record.prev = previous
+	if previous == nil {
+		record.next = document.first
+		document.first = record
+	} else {
+		record.next = previous.next
+		previous.next = record
+	}
+
+	if record.next == nil {
+		document.last = record
+	} else {
+		record.next.prev = record
+	}
+}
+
+func (document *Document) remove(record *Record) {
+	document.pin(record.next)
+
+	// The record that ends up last takes over the missing line ending
+	if record.next == nil && record.eol == "" && record.prev != nil {
+		record.prev.eol = ""
+	}
+
+	if record.prev == nil {
+		document.first = record.next
+	} else {
+		record.prev.next = record.next
+	}
+
+	if record.next == nil {
+		document.last = record.prev
+	} else {
+		record.next.prev = record.prev
+	}
+
+	record.prev, record.next = nil, nil
+
+	if document.cells[record.y][record.x] == record {
+		delete(document.cells[record.y], record.x)
+		if len(document.cells[record.y]) < 1 {
+			delete(document.cells, record.y)
+		}
+
+		if record.x >= document.maxX || record.y >= document.maxY {
+			document.sized = false
+		}
+	}
+}
+
+func (document *Document) addCell(record *Record) {
+	if document.cells[record.y] == nil {
+		document.cells[record.y] = make(map[int]*Record)
+	}
+
+	document.cells[record.y][record.x] = record
+	if document.sized {
+		if record.x > document.maxX {
+			document.maxX = record.x
+		}
+
+		if record.y > document.maxY {
+			document.maxY = record.y
+		}
+	}
+}
+
+// pin makes the coordinates of a record explicit so that it stays where it is when the record before it changes
+func (document *Document) pin(record *Record) {
+	if record == nil || (record.Type != "C" && record.Type != "F") || record.x < 1 {
+		return
+	}
+
+	if _, ok := record.Field('X'); !ok {
+		record.SetField('X', strconv.Itoa(record.x))
+	}
+
+	if _, ok := record.Field('Y'); !ok {
+		record.SetField('Y', strconv.Itoa(record.y))
+	}
+}
+
+// growBounds keeps the size in the B record in line with the cells
+func (document *Document) growBounds() {
+	maxX, maxY := document.Size()
+	for _, record := range document.bounds {
+		if value, ok := record.Field('X'); ok {
+			if n, err := strconv.Atoi(value); err == nil && n < maxX {
+				record.SetField('X', strconv.Itoa(maxX))
+			}
+		}
+
+		if value, ok := record.Field('Y'); ok {
+			if n, err := strconv.Atoi(value); err == nil && n < maxY {
+				record.SetField('Y', strconv.Itoa(maxY))
+			}
+		}
+	}
+}
+
+// SortRows orders the rows below the column names by their first cell, rows without one go last. The records of
+// every row move along with it, formatting records included, and only the records that no longer end up at
+// their row by themselves are given a new Y. A document that's already sorted is left alone
+func (document *Document) SortRows() error {
+	// Formatting records can point at rows without cells, empty rows have nothing to move and end up last
+	rowSet := make(map[int]bool)
+	for record := document.first; record != nil && record.Type != "E"; record = record.next {
+		if (record.Type == "C" || record.Type == "F") && record.y >= 2 {
+			rowSet[record.y] = true
+		}
+	}
+
+	rows := make([]int, 0, len(rowSet))
+	for y := range rowSet {
+		rows = append(rows, y)
+	}
+
+	sort.Ints(rows)
+
+	key := func(y int) string {
+		value, _ := document.Cell(1, y)
+		return Unquote(value)
+	}
+
+	less := func(i, j int) bool {
+		a, b := key(rows[i]), key(rows[j])
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+
+		return a < b
+	}
+
+	sorted := sort.SliceIsSorted(rows, less)
+	for i, y := range rows {
+		sorted = sorted && y == i+2
+	}
+
+	if sorted {
+		return nil
+	}
+
+	sort.SliceStable(rows, less)
+
+	sortedRows := make(map[int]int, len(rows))
+	for i, y := range rows {
+		sortedRows[y] = i + 2
+	}
+
+	// Records that don't point at a row of their own stay with the record before them, everything before the
+	// first row and from the E record onwards stays where it is
+	var header, trailer []*Record
+	groups := make(map[int][]*Record)
+	current := 0
+	for record := document.first; record != nil; record = record.next {
+		switch {
+		case len(trailer) > 0 || record.Type == "E":
+			trailer = append(trailer, record)
+		case (record.Type == "C" || record.Type == "F") && record.y >= 2:
+			current = record.y
+			groups[current] = append(groups[current], record)
+		case current == 0:
+			header = append(header, record)
+		default:
+			groups[current] = append(groups[current], record)
+		}
+	}
+
+	records := header
+	for _, y := range rows {
+		records = append(records, groups[y]...)
+	}
+
+	records = append(records, trailer...)
+
+	document.first, document.last = nil, nil
+	for _, record := range records {
+		document.link(document.last, record)
+	}
+
+	// Line endings belong to positions in the file rather than to records, the one without a line ending has to
+	// stay last
+	var previous *Record
+	for _, record := range records {
+		if previous != nil && previous.eol == "" {
+			previous.eol, record.eol = record.eol, previous.eol
+		}
+
+		previous = record
+	}
+
+	x, y := 1, 1
+	for record := document.first; record != nil && record.Type != "E"; record = record.next {
+		if record.Type != "C" && record.Type != "F" {
+			continue
+		}
+
+		wantX, wantY := record.x, record.y
+		if sortedY, ok := sortedRows[record.y]; ok {
+			wantY = sortedY
+		}
+
+		if value, ok := record.Field('X'); ok {
+			x, _ = strconv.Atoi(value)
+		}
+
+		if value, ok := record.Field('Y'); ok {
+			y, _ = strconv.Atoi(value)
+		}
+
+		if x != wantX {
+			record.SetField('X', strconv.Itoa(wantX))
+			x = wantX
+		}
+
+		if y != wantY {
+			record.SetField('Y', strconv.Itoa(wantY))
+			y = wantY
+		}
+	}
+
+	return document.index()
+}
+
+// Table returns the cells of the document as a table
+func (document *Document) Table() *Table {
+	maxX, maxY := document.Size()
+
+	table := &Table{Columns: make([]string, maxX)}
+	for x := 1; x <= maxX; x++ {
+		value, _ := document.Cell(x, 1)
+		table.Columns[x-1] = Unquote(value)
+	}
+
+	for y := 2; y <= maxY; y++ {
+		row := make([]string, maxX)
+		for x := range document.cells[y] {
+			row[x-1], _ = document.Cell(x, y)
+		}
+
+		table.Rows = append(table.Rows, row)
+	}
+
+	return table
+}
+
+// Field returns the value of the first field that starts with key
+func (record *Record) Field(key byte) (string, bool) {
+	for _, field := range record.Fields {
+		if field != "" && field[0] == key {
+			return field[1:], true
+		}
+	}
+
+	return "", false
+}
+
+// SetField changes the value of the first field that starts with key or adds the field, values are kept in
+// the X, Y, K order readers expect
+func (record *Record) SetField(key byte, value string) {
+	record.modified = true
+	for i, field := range record.Fields {
+		if field != "" && field[0] == key {
+			record.Fields[i] = string(key) + value
+			return
+		}
+	}
+
+	field := string(key) + value
+	for i, existing := range record.Fields {
+		if existing != "" && strings.IndexByte("XYK", key) >= 0 && strings.IndexByte("XYK", existing[0]) > strings.IndexByte("XYK", key) {
+			record.Fields = append(record.Fields[:i], append([]string{field}, record.Fields[i:]...)...)
+			return
+		}
+	}
+
+	record.Fields = append(record.Fields, field)
+}
+
+// String returns the record the way it appears in a file. Empty fields of edited records are left out, one
+// followed by another field would read back as an escaped semicolon
+func (record *Record) String() string {
+	if !record.modified {
+		return record.raw
+	}
+
+	var builder strings.Builder
+	builder.WriteString(record.Type)
+	for _, field := range record.Fields {
+		if field == "" {
+			continue
+		}
+
+		builder.WriteByte(';')
+		builder.WriteString(escape(field))
+	}
+
+	return builder.String()
+}
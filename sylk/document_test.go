@@ -0,0 +1,403 @@
+package sylk
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The SLK files that ship with the editor
+var bundledFiles = []string{
+	"../resources/app/splats/splatdata.slk",
+	"../resources/app/splats/ubersplatdata.slk",
+	"../resources/app/splats/spawndata.slk",
+	"../resources/app/ui/soundinfo/animsounds.slk",
+	"../resources/app/ui/soundinfo/animlookups.slk",
+}
+
+// A file the way Excel writes it, with formatting records, escaped semicolons and cells that leave out X or Y
+const formattedFile = "ID;PWXL;N;E\r\n" +
+	"P;PGeneral\r\n" +
+	"P;P0.00\r\n" +
+	"F;P0;DG0G8;M255\r\n" +
+	"B;X3;Y4;D0 0 3 2\r\n" +
+	"C;X1;Y1;K\"id\"\r\n" +
+	"C;X2;K\"name\"\r\n" +
+	"F;P1;FF2G;X3;Y1\r\n" +
+	"C;X3;K\"cost\"\r\n" +
+	"C;X1;Y2;K\"c\"\r\n" +
+	"C;X2;K\"semi;;colon\"\r\n" +
+	"F;P1;FF2G;X3\r\n" +
+	"C;X3;K3.5\r\n" +
+	"C;X1;Y3;K\"a\"\r\n" +
+	"C;X2;K\"first\"\r\n" +
+	"C;X3;K1\r\n" +
+	"C;X1;Y4;K\"b\"\r\n" +
+	"C;X3;K2\r\n" +
+	"E\r\n"
+
+func readBundledFiles(t testing.TB) map[string][]byte {
+	files := make(map[string][]byte)
+	for _, name := range bundledFiles {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[filepath.Base(name)] = data
+	}
+
+	return files
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	files := readBundledFiles(t)
+	files["formatted.slk"] = []byte(formattedFile)
+	files["lf.slk"] = []byte(strings.Replace(formattedFile, "\r\n", "\n", -1))
+	files["cr.slk"] = []byte(strings.Replace(formattedFile, "\r\n", "\r", -1))
+	files["no final line ending.slk"] = []byte(strings.TrimSuffix(formattedFile, "\r\n"))
+	files["mixed.slk"] = []byte("ID;P\nB;X1;Y1\r\nC;X1;Y1;K1\rE")
+	files["after end.slk"] = []byte(formattedFile + "C;X;Y;Kignored\r\n")
+
+	for name, data := range files {
+		document, err := Parse(data)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if written := document.Bytes(); !bytes.Equal(written, data) {
+			t.Errorf("%s: writing it back changed %d bytes into %d bytes", name, len(data), len(written))
+		}
+	}
+}
+
+func TestSetCellKeepsUnchangedRecords(t *testing.T) {
+	for name, data := range readBundledFiles(t) {
+		document, err := Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Setting every cell to what it already is changes nothing
+		table := document.Table()
+		for y, row := range table.Rows {
+			for x, value := range row {
+				if err = document.SetCell(x+1, y+2, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		if !bytes.Equal(document.Bytes(), data) {
+			t.Errorf("%s: setting cells to their own values changed the file", name)
+		}
+	}
+}
+
+func TestSetCell(t *testing.T) {
+	document, err := Parse([]byte(formattedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = document.SetCell(3, 3, "10"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cell of a row that's missing one goes between its neighbours
+	if err = document.SetCell(2, 4, "\"second\""); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new column grows the B record
+	if err = document.SetCell(4, 1, "\"extra\""); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "ID;PWXL;N;E\r\n" +
+		"P;PGeneral\r\n" +
+		"P;P0.00\r\n" +
+		"F;P0;DG0G8;M255\r\n" +
+		"B;X4;Y4;D0 0 3 2\r\n" +
+		"C;X1;Y1;K\"id\"\r\n" +
+		"C;X2;K\"name\"\r\n" +
+		"F;P1;FF2G;X3;Y1\r\n" +
+		"C;X3;K\"cost\"\r\n" +
+		"C;X4;Y1;K\"extra\"\r\n" +
+		"C;X1;Y2;K\"c\"\r\n" +
+		"C;X2;K\"semi;;colon\"\r\n" +
+		"F;P1;FF2G;X3\r\n" +
+		"C;X3;K3.5\r\n" +
+		"C;X1;Y3;K\"a\"\r\n" +
+		"C;X2;K\"first\"\r\n" +
+		"C;X3;K10\r\n" +
+		"C;X1;Y4;K\"b\"\r\n" +
+		"C;X2;Y4;K\"second\"\r\n" +
+		"C;X3;Y4;K2\r\n" +
+		"E\r\n"
+	if written := string(document.Bytes()); written != want {
+		t.Errorf("got\n%s\nwant\n%s", written, want)
+	}
+
+	reread, err := Parse(document.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(reread.Table(), document.Table()) {
+		t.Error("reading the file back gives different cells")
+	}
+}
+
+func TestRemoveRowKeepsOtherRowsInPlace(t *testing.T) {
+	document, err := Parse([]byte(formattedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = document.RemoveRow(2); err != nil {
+		t.Fatal(err)
+	}
+
+	// The formatting record that followed the removed cells now says where it is
+	want := "ID;PWXL;N;E\r\n" +
+		"P;PGeneral\r\n" +
+		"P;P0.00\r\n" +
+		"F;P0;DG0G8;M255\r\n" +
+		"B;X3;Y4;D0 0 3 2\r\n" +
+		"C;X1;Y1;K\"id\"\r\n" +
+		"C;X2;K\"name\"\r\n" +
+		"F;P1;FF2G;X3;Y1\r\n" +
+		"C;X3;K\"cost\"\r\n" +
+		"F;P1;FF2G;X3;Y2\r\n" +
+		"C;X1;Y3;K\"a\"\r\n" +
+		"C;X2;K\"first\"\r\n" +
+		"C;X3;K1\r\n" +
+		"C;X1;Y4;K\"b\"\r\n" +
+		"C;X3;K2\r\n" +
+		"E\r\n"
+	if written := string(document.Bytes()); written != want {
+		t.Errorf("got\n%s\nwant\n%s", written, want)
+	}
+
+	if _, rows := document.Size(); rows != 4 {
+		t.Errorf("rows = %d, want 4", rows)
+	}
+}
+
+func TestRemoveLastRecord(t *testing.T) {
+	data := "ID;P\nC;X1;Y1;K1\nC;X1;Y2;K2"
+	document, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = document.SetCell(1, 2, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if written := string(document.Bytes()); written != "ID;P\nC;X1;Y1;K1" {
+		t.Errorf("got %q", written)
+	}
+
+	if err = document.SetCell(1, 2, "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	if written := string(document.Bytes()); written != "ID;P\nC;X1;Y1;K1\nC;X1;Y2;K3" {
+		t.Errorf("got %q", written)
+	}
+}
+
+func TestSortRowsKeepsFormatting(t *testing.T) {
+	document, err := Parse([]byte(formattedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = document.SortRows(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rows move along with their formatting and only the first record of every row gets a new Y
+	want := "ID;PWXL;N;E\r\n" +
+		"P;PGeneral\r\n" +
+		"P;P0.00\r\n" +
+		"F;P0;DG0G8;M255\r\n" +
+		"B;X3;Y4;D0 0 3 2\r\n" +
+		"C;X1;Y1;K\"id\"\r\n" +
+		"C;X2;K\"name\"\r\n" +
+		"F;P1;FF2G;X3;Y1\r\n" +
+		"C;X3;K\"cost\"\r\n" +
+		"C;X1;Y2;K\"a\"\r\n" +
+		"C;X2;K\"first\"\r\n" +
+		"C;X3;K1\r\n" +
+		"C;X1;Y3;K\"b\"\r\n" +
+		"C;X3;K2\r\n" +
+		"C;X1;Y4;K\"c\"\r\n" +
+		"C;X2;K\"semi;;colon\"\r\n" +
+		"F;P1;FF2G;X3\r\n" +
+		"C;X3;K3.5\r\n" +
+		"E\r\n"
+	if written := string(document.Bytes()); written != want {
+		t.Errorf("got\n%s\nwant\n%s", written, want)
+	}
+
+	table := document.Table()
+	for i, id := range []string{"\"a\"", "\"b\"", "\"c\""} {
+		if table.Rows[i][0] != id {
+			t.Errorf("row %d is %s, want %s", i, table.Rows[i][0], id)
+		}
+	}
+
+	// Sorting it again changes nothing
+	sorted := document.Bytes()
+	if err = document.SortRows(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(document.Bytes(), sorted) {
+		t.Error("sorting a sorted document changed it")
+	}
+}
+
+func TestSortRowsKeepsTheMissingLineEndingLast(t *testing.T) {
+	document, err := Parse([]byte("ID;P\nC;X1;Y1;K\"id\"\nC;X1;Y2;K\"b\"\nC;X1;Y3;K\"a\""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = document.SortRows(); err != nil {
+		t.Fatal(err)
+	}
+
+	if written := string(document.Bytes()); written != "ID;P\nC;X1;Y1;K\"id\"\nC;X1;Y2;K\"a\"\nC;X1;Y3;K\"b\"" {
+		t.Errorf("got %q", written)
+	}
+}
+
+func TestSortRowsMatchesTheTable(t *testing.T) {
+	for name, data := range readBundledFiles(t) {
+		document, err := Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Reversing the rows makes sure that every one of them has to move
+		table := document.Table()
+		for y := range table.Rows {
+			for x := range table.Rows[y] {
+				if err = document.SetCell(x+1, len(table.Rows)+1-y, table.Rows[y][x]); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		if err = document.SortRows(); err != nil {
+			t.Fatal(err)
+		}
+
+		reread, err := Parse(document.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sorted := reread.Table()
+		for y := 1; y < len(sorted.Rows); y++ {
+			a, b := Unquote(sorted.Rows[y-1][0]), Unquote(sorted.Rows[y][0])
+			if b != "" && (a == "" || a > b) {
+				t.Errorf("%s: %s comes before %s", name, a, b)
+				break
+			}
+		}
+
+		if len(sorted.Rows) != len(table.Rows) {
+			t.Errorf("%s: %d rows, want %d", name, len(sorted.Rows), len(table.Rows))
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	// The start of every bundled file, whole files make every run of the fuzzer crawl
+	for _, data := range readBundledFiles(f) {
+		lines := bytes.SplitAfterN(data, []byte("\n"), 40)
+		f.Add(bytes.Join(lines[:len(lines)-1], nil))
+	}
+
+	f.Add([]byte(formattedFile))
+	f.Add([]byte("ID;P\rC;Y2;K1\nC;X2;K;;\r\nE\r\nC;X0"))
+	f.Add([]byte("ID;P\nC;X1;"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		document, err := Parse(data)
+		if err != nil {
+			return
+		}
+
+		if !bytes.Equal(document.Bytes(), data) {
+			t.Fatal("writing the document back changed it")
+		}
+
+		// Tables are dense so cells far out would take more memory than the fuzzer has
+		maxX, maxY := document.Size()
+		if maxX > 1000 || maxY > 1000 {
+			return
+		}
+
+		// Edits have to leave a file that reads back to the same cells
+		for _, cell := range [][2]int{{1, 1}, {maxX, maxY}, {maxX + 1, maxY + 1}, {1, maxY + 2}} {
+			if cell[0] < 1 || cell[1] < 1 {
+				continue
+			}
+
+			if err = document.SetCell(cell[0], cell[1], strconv.Itoa(cell[0]*cell[1])); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if maxY >= 2 {
+			if err = document.RemoveRow(2); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err = document.SortRows(); err != nil {
+			t.Fatal(err)
+		}
+
+		reread, err := Parse(document.Bytes())
+		if err != nil {
+			t.Fatalf("the edited document can't be read back: %v", err)
+		}
+
+		if !reflect.DeepEqual(reread.Table(), document.Table()) {
+			t.Fatal("the edited document reads back to different cells")
+		}
+	})
+}
+
+func BenchmarkAppendRows(b *testing.B) {
+	data, err := ioutil.ReadFile(bundledFiles[3])
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		document, err := Parse(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		_, rows := document.Size()
+		for y := rows + 1; y <= rows+1000; y++ {
+			for x := 1; x <= 10; x++ {
+				document.SetCell(x, y, strconv.Itoa(x))
+			}
+		}
+	}
+}
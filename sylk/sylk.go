@@ -0,0 +1,193 @@
+// Package sylk reads and writes the SYLK spreadsheets Warcraft III keeps its object data in.
+//
+// A SYLK file is made up of records, one per line, with fields separated by semicolons. The ID record starts the
+// file, the B record tells its size, C records hold cell values in their K field and F records formatting, both
+// use X and Y for the column and row and keep the previous ones when they're left out. The E record ends the file
+package sylk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Table holds a spreadsheet where the first row names the columns. Values are kept exactly as they appear
+// in the file, which means that text values keep their quotes
+type Table struct {
+	Columns []string
+	Rows    [][]string
+}
+
+// Read parses a SYLK file into a table
+func Read(data []byte) (*Table, error) {
+	document, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return document.Table(), nil
+}
+
+// ColumnIndex returns the index of a column regardless of its casing or -1 when there's no such column
+func (table *Table) ColumnIndex(name string) int {
+	for x, column := range table.Columns {
+		if strings.EqualFold(column, name) {
+			return x
+		}
+	}
+
+	return -1
+}
+
+// Reorder returns a copy of the table with the given columns first, columns it doesn't have are added empty
+// and the remaining columns follow in their original order
+func (table *Table) Reorder(columns []string) *Table {
+	var order []int
+	used := make(map[int]bool)
+	for _, column := range columns {
+		x := table.ColumnIndex(column)
+		if x >= 0 {
+			used[x] = true
+		}
+
+		order = append(order, x)
+	}
+
+	for x := range table.Columns {
+		if !used[x] {
+			order = append(order, x)
+		}
+	}
+
+	reordered := &Table{Columns: make([]string, len(order)), Rows: make([][]string, len(table.Rows))}
+	for i, x := range order {
+		if x < 0 {
+			reordered.Columns[i] = columns[i]
+		} else {
+			reordered.Columns[i] = table.Columns[x]
+		}
+	}
+
+	for y, row := range table.Rows {
+		reordered.Rows[y] = make([]string, len(order))
+		for i, x := range order {
+			if x >= 0 && x < len(row) {
+				reordered.Rows[y][i] = row[x]
+			}
+		}
+	}
+
+	return reordered
+}
+
+// splitRecord splits a record into its fields, semicolons within values are escaped by doubling them
+func splitRecord(line string) []string {
+	var fields []string
+	var current strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] != ';' {
+			current.WriteByte(line[i])
+		} else if i+1 < len(line) && line[i+1] == ';' {
+			current.WriteByte(';')
+			i++
+		} else {
+			fields = append(fields, current.String())
+			current.Reset()
+		}
+	}
+
+	return append(fields, current.String())
+}
+
+// Write writes a table as a SYLK file, empty values are left out
+func Write(w io.Writer, table *Table) error {
+	return write(w, table, "\r\n")
+}
+
+func write(w io.Writer, table *Table, eol string) error {
+	buffer := bufio.NewWriter(w)
+	fmt.Fprintf(buffer, "ID;PWXL;N;E%s", eol)
+	fmt.Fprintf(buffer, "B;X%d;Y%d;D0 0 %d %d%s", len(table.Columns), len(table.Rows)+1, len(table.Rows), len(table.Columns)-1, eol)
+
+	for x, column := range table.Columns {
+		if column == "" {
+			continue
+		}
+
+		if x == 0 {
+			fmt.Fprintf(buffer, "C;X%d;Y1;K%s%s", x+1, escape(Quote(column)), eol)
+		} else {
+			fmt.Fprintf(buffer, "C;X%d;K%s%s", x+1, escape(Quote(column)), eol)
+		}
+	}
+
+	for y, row := range table.Rows {
+		first := true
+		for x, value := range row {
+			if value == "" {
+				continue
+			}
+
+			if first {
+				fmt.Fprintf(buffer, "C;X%d;Y%d;K%s%s", x+1, y+2, escape(value), eol)
+				first = false
+			} else {
+				fmt.Fprintf(buffer, "C;X%d;K%s%s", x+1, escape(value), eol)
+			}
+		}
+	}
+
+	fmt.Fprintf(buffer, "E%s", eol)
+
+	return buffer.Flush()
+}
+
+// Quote turns text into a SYLK text value
+func Quote(text string) string {
+	return "\"" + text + "\""
+}
+
+// Unquote returns the text of a SYLK text value, numbers are returned as they are
+func Unquote(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
+
+// IsText tells whether a value is a quoted text value rather than a number, a boolean or an error
+func IsText(value string) bool {
+	return len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"")
+}
+
+// Number returns the numeric value of a cell
+func Number(value string) (float64, error) {
+	if IsText(value) {
+		return 0, fmt.Errorf("%s is not a number", value)
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
+
+// FormatNumber returns a number the way it's written to a file, which means that 1.50 and 1.5 are both written
+// as 1.5. Values that aren't plain numbers are returned as they are
+func FormatNumber(value string) string {
+	// Numbers with more digits than a float holds would lose some of them
+	if value == "" || strings.Trim(value, "+-.0123456789") != "" || len(strings.Trim(value, "+-.")) > 15 {
+		return value
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+func escape(value string) string {
+	return strings.Replace(value, ";", ";;", -1)
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+const (
+	CATEGORY_UPGRADES     = "upgrades"
+	TECH_TREE_FORMAT_DOT  = "dot"
+	TECH_TREE_FORMAT_JSON = "json"
+	TECH_EDGE_TRAINS      = "trains"
+	TECH_EDGE_BUILDS      = "builds"
+	TECH_EDGE_UPGRADES    = "upgrades"
+	TECH_EDGE_SELLS       = "sells"
+	TECH_EDGE_MAKES       = "makes"
+	TECH_EDGE_RESEARCHES  = "researches"
+	TECH_EDGE_REQUIRES    = "requires"
+	TECH_EDGE_EQUIVALENT  = "equivalent"
+)
+
+// Edges that put their target in the game, the others only tell what their source needs
+var productionEdges = map[string]bool{
+	TECH_EDGE_TRAINS:     true,
+	TECH_EDGE_BUILDS:     true,
+	TECH_EDGE_UPGRADES:   true,
+	TECH_EDGE_SELLS:      true,
+	TECH_EDGE_MAKES:      true,
+	TECH_EDGE_RESEARCHES: true,
+}
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type TechTreeOptions struct {
+	// Roots are the units the game starts with, they're worked out from the data when none are given
+	Roots  []string
+	Race   null.String
+	Path   null.String
+	Format null.String
+}
+
+type TechTreeNode struct {
+	Id        string
+	Category  string
+	Name      string
+	Race      string
+	Reachable bool
+}
+
+// TechTreeEdge points from a producer to what it produces and from an object to what it requires. Tier tells
+// which of Requires, Requires1 and Requires2 a requirement comes from and equivalent edges point from an object
+// to the one it counts as when requirements are checked
+type TechTreeEdge struct {
+	From string
+	To   string
+	Kind string
+	Tier int `json:",omitempty"`
+}
+
+type TechTree struct {
+	Nodes       []*TechTreeNode
+	Edges       []*TechTreeEdge
+	Roots       []string
+	Unreachable []string
+	Cycles      [][]string
+}
+
+// buildTechTree builds the graph of what produces and requires what. Upgrades only get their name, their own
+// requirements aren't read so they're taken to be available as soon as something that researches them is
+func buildTechTree(options *TechTreeOptions) *TechTree {
+	tree := &TechTree{Nodes: []*TechTreeNode{}, Edges: []*TechTreeEdge{}, Roots: []string{}, Unreachable: []string{}, Cycles: [][]string{}}
+
+	nodes := make(map[string]*TechTreeNode)
+	addNode := func(id string) {
+		if _, ok := nodes[id]; ok {
+			return
+		}
+
+		node := &TechTreeNode{Id: id, Category: CATEGORY_UPGRADES, Name: id}
+		if unit, ok := unitMap[id]; ok {
+			node.Category = CATEGORY_UNITS
+			if unit.UnitString != nil && unit.UnitString.Name.Valid {
+				node.Name = sylk.Unquote(unit.UnitString.Name.String)
+			}
+
+			if unit.UnitData != nil {
+				node.Race = sylk.Unquote(unit.UnitData.Race.String)
+			}
+		} else if item, ok := itemMap[id]; ok {
+			node.Category = CATEGORY_ITEMS
+			if item.ItemString != nil && item.ItemString.Name.Valid {
+				node.Name = sylk.Unquote(item.ItemString.Name.String)
+			}
+		} else if name := firstValue(upgradeValue(upgradeMap[id], "Name")); name != "" {
+			node.Name = name
+		}
+
+		nodes[id] = node
+		tree.Nodes = append(tree.Nodes, node)
+	}
+
+	addEdges := func(from string, field null.String, kind string, tier int) {
+		for _, to := range splitObjectIds(field) {
+			addNode(to)
+			tree.Edges = append(tree.Edges, &TechTreeEdge{from, to, kind, tier})
+		}
+	}
+
+	for _, id := range sortedObjectKeys(unitMap) {
+		unit := unitMap[id]
+		if options.Race.Valid && (unit.UnitData == nil || !strings.EqualFold(sylk.Unquote(unit.UnitData.Race.String), options.Race.String)) {
+			continue
+		}
+
+		addNode(id)
+		if unit.UnitFunc == nil {
+			continue
+		}
+
+		addEdges(id, unit.UnitFunc.Trains, TECH_EDGE_TRAINS, 0)
+		addEdges(id, unit.UnitFunc.Builds, TECH_EDGE_BUILDS, 0)
+		addEdges(id, unit.UnitFunc.Upgrade, TECH_EDGE_UPGRADES, 0)
+		addEdges(id, unit.UnitFunc.Sellunits, TECH_EDGE_SELLS, 0)
+		addEdges(id, unit.UnitFunc.Sellitems, TECH_EDGE_SELLS, 0)
+		addEdges(id, unit.UnitFunc.Makeitems, TECH_EDGE_MAKES, 0)
+		addEdges(id, unit.UnitFunc.Researches, TECH_EDGE_RESEARCHES, 0)
+		addEdges(id, unit.UnitFunc.Requires, TECH_EDGE_REQUIRES, 1)
+		addEdges(id, unit.UnitFunc.Requires1, TECH_EDGE_REQUIRES, 2)
+		addEdges(id, unit.UnitFunc.Requires2, TECH_EDGE_REQUIRES, 3)
+
+		for _, equivalent := range splitObjectIds(unit.UnitFunc.Dependencyor) {
+			addNode(equivalent)
+			tree.Edges = append(tree.Edges, &TechTreeEdge{equivalent, id, TECH_EDGE_EQUIVALENT, 0})
+		}
+	}
+
+	// Items only come along when they're filtered by race, the ones that no unit sells or makes are left out
+	for _, id := range sortedObjectKeys(itemMap) {
+		if _, ok := nodes[id]; !ok && options.Race.Valid {
+			continue
+		}
+
+		addNode(id)
+		if item := itemMap[id]; item.ItemFunc != nil {
+			addEdges(id, item.ItemFunc.Requires, TECH_EDGE_REQUIRES, 1)
+		}
+	}
+
+	tree.Roots = options.Roots
+	if len(tree.Roots) < 1 {
+		tree.Roots = findTechTreeRoots(tree)
+	}
+
+	reachable := findReachable(tree, tree.Roots)
+	for _, node := range tree.Nodes {
+		node.Reachable = reachable[node.Id]
+		if !node.Reachable && node.Category == CATEGORY_UNITS {
+			tree.Unreachable = append(tree.Unreachable, node.Id)
+		}
+	}
+
+	requirements := make(map[string][]string)
+	for _, edge := range tree.Edges {
+		if edge.Kind == TECH_EDGE_REQUIRES && edge.Tier == 1 {
+			requirements[edge.From] = append(requirements[edge.From], edge.To)
+		}
+	}
+
+	for _, component := range stronglyConnected(tree.Nodes, requirements) {
+		if len(component) > 1 || containsString(requirements[component[0]], component[0]) {
+			tree.Cycles = append(tree.Cycles, component)
+		}
+	}
+
+	sort.Strings(tree.Unreachable)
+	sort.Slice(tree.Cycles, func(i, j int) bool {
+		return tree.Cycles[i][0] < tree.Cycles[j][0]
+	})
+
+	return tree
+}
+
+// findTechTreeRoots returns the units the game starts with, which are the units of groups that produce each other,
+// such as a town hall and its workers, and that nothing outside of the group produces. Units that nothing
+// produces but that produce something themselves, such as shops, are placed on the map and count as well
+func findTechTreeRoots(tree *TechTree) []string {
+	produces := make(map[string][]string)
+	for _, edge := range tree.Edges {
+		if productionEdges[edge.Kind] {
+			produces[edge.From] = append(produces[edge.From], edge.To)
+		}
+	}
+
+	components := stronglyConnected(tree.Nodes, produces)
+	componentOf := make(map[string]int)
+	for i, component := range components {
+		for _, id := range component {
+			componentOf[id] = i
+		}
+	}
+
+	produced := make(map[int]bool)
+	producing := make(map[int]bool)
+	for from, targets := range produces {
+		for _, to := range targets {
+			if componentOf[from] != componentOf[to] {
+				produced[componentOf[to]] = true
+			}
+
+			producing[componentOf[from]] = true
+		}
+	}
+
+	roots := []string{}
+	for i, component := range components {
+		if produced[i] || !producing[i] {
+			continue
+		}
+
+		for _, id := range component {
+			if unit, ok := unitMap[id]; ok && unit != nil {
+				roots = append(roots, id)
+			}
+		}
+	}
+
+	sort.Strings(roots)
+
+	return roots
+}
+
+// findReachable returns everything that can be produced starting from the roots, something can only be produced
+// once the first tier of its requirements can be met by the requirement or by something that counts as it
+func findReachable(tree *TechTree, roots []string) map[string]bool {
+	requirements := make(map[string][]string)
+	equivalents := make(map[string][]string)
+	for _, edge := range tree.Edges {
+		if edge.Kind == TECH_EDGE_REQUIRES && edge.Tier == 1 {
+			requirements[edge.From] = append(requirements[edge.From], edge.To)
+		} else if edge.Kind == TECH_EDGE_EQUIVALENT {
+			equivalents[edge.To] = append(equivalents[edge.To], edge.From)
+		}
+	}
+
+	reachable := make(map[string]bool)
+	for _, root := range roots {
+		reachable[root] = true
+	}
+
+	isMet := func(requirement string) bool {
+		if reachable[requirement] {
+			return true
+		}
+
+		for _, equivalent := range equivalents[requirement] {
+			if reachable[equivalent] {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, edge := range tree.Edges {
+			if !productionEdges[edge.Kind] || !reachable[edge.From] || reachable[edge.To] {
+				continue
+			}
+
+			met := true
+			for _, requirement := range requirements[edge.To] {
+				met = met && isMet(requirement)
+			}
+
+			if met {
+				reachable[edge.To] = true
+				changed = true
+			}
+		}
+	}
+
+	return reachable
+}
+
+// stronglyConnected returns the groups of nodes that can all be reached from each other by following the edges,
+// the ids of every group are sorted
+func stronglyConnected(nodes []*TechTreeNode, edges map[string][]string) [][]string {
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+
+	var visit func(id string)
+	visit = func(id string) {
+		index[id] = len(index)
+		lowLink[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, to := range edges[id] {
+			if _, ok := index[to]; !ok {
+				visit(to)
+				if lowLink[to] < lowLink[id] {
+					lowLink[id] = lowLink[to]
+				}
+			} else if onStack[to] && index[to] < lowLink[id] {
+				lowLink[id] = index[to]
+			}
+		}
+
+		if lowLink[id] != index[id] {
+			return
+		}
+
+		var component []string
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == id {
+				break
+			}
+		}
+
+		sort.Strings(component)
+		components = append(components, component)
+	}
+
+	for _, node := range nodes {
+		if _, ok := index[node.Id]; !ok {
+			visit(node.Id)
+		}
+	}
+
+	return components
+}
+
+// dot writes the tree for Graphviz, requirements are dashed and what can't be reached is red
+func (tree *TechTree) dot() []byte {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+	var buffer bytes.Buffer
+	buffer.WriteString("digraph \"tech tree\" {\n")
+	buffer.WriteString("    rankdir=LR;\n")
+	for _, node := range tree.Nodes {
+		shape := "box"
+		switch node.Category {
+		case CATEGORY_ITEMS:
+			shape = "ellipse"
+		case CATEGORY_UPGRADES:
+			shape = "diamond"
+		}
+
+		label := quote.Replace(strings.TrimSpace(colorCodePattern.ReplaceAllString(node.Name, ""))) + `\n` + quote.Replace(node.Id)
+		color := ""
+		if !node.Reachable {
+			color = ", color=red"
+		}
+
+		fmt.Fprintf(&buffer, "    \"%s\" [label=\"%s\", shape=%s%s];\n", quote.Replace(node.Id), label, shape, color)
+	}
+
+	for _, edge := range tree.Edges {
+		label := edge.Kind
+		if edge.Tier > 1 {
+			label = fmt.Sprintf("%s tier %d", edge.Kind, edge.Tier)
+		}
+
+		style := ""
+		if !productionEdges[edge.Kind] {
+			style = ", style=dashed"
+		}
+
+		fmt.Fprintf(&buffer, "    \"%s\" -> \"%s\" [label=\"%s\"%s];\n", quote.Replace(edge.From), quote.Replace(edge.To), label, style)
+	}
+
+	buffer.WriteString("}\n")
+
+	return buffer.Bytes()
+}
+
+// exportTechTree writes the tree as DOT or JSON, the format follows the extension when none is given
+func exportTechTree(tree *TechTree, path string, format null.String) error {
+	if !format.Valid {
+		format = null.StringFrom(strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."))
+	}
+
+	var data []byte
+	switch format.String {
+	case TECH_TREE_FORMAT_DOT, "gv":
+		data = tree.dot()
+	case TECH_TREE_FORMAT_JSON:
+		var err error
+		if data, err = json.MarshalIndent(tree, "", "  "); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s is not a tech tree format", format.String)
+	}
+
+	return writeFileAtomically(path, data)
+}
+
+// getTechTree builds the tech tree and writes it to a file as well when a path is given
+func getTechTree(options *TechTreeOptions) (*TechTree, error) {
+	tree := buildTechTree(options)
+	if options.Path.Valid && options.Path.String != "" {
+		if err := exportTechTree(tree, options.Path.String, options.Format); err != nil {
+			return nil, err
+		}
+	}
+
+	return tree, nil
+}
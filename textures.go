@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/blp"
+)
+
+const (
+	MAX_TEAM_COLOR = 27
+	// Asks the resource server for a texture converted to PNG
+	TEXTURE_PNG_QUERY = "?format=png"
+)
+
+var (
+	// Every texture found in the resources and the input folder by lowercase game path, the index is built
+	// once for every combination of resources and input folder
+	textureIndexKey string
+	textureIndex    map[string]*textureEntry
+
+	// Textures the game picks at runtime, the team color and team glow textures also depend on the player
+	replaceableTextures = map[uint32]string{
+		1:  "ReplaceableTextures\\TeamColor\\TeamColor%02d.blp",
+		2:  "ReplaceableTextures\\TeamGlow\\TeamGlow%02d.blp",
+		11: "ReplaceableTextures\\Cliff\\Cliff0.blp",
+		31: "ReplaceableTextures\\LordaeronTree\\LordaeronSummerTree.blp",
+		32: "ReplaceableTextures\\AshenvaleTree\\AshenTree.blp",
+		33: "ReplaceableTextures\\BarrensTree\\BarrensTree.blp",
+		34: "ReplaceableTextures\\NorthrendTree\\NorthTree.blp",
+		35: "ReplaceableTextures\\Mushroom\\MushroomTree.blp",
+		36: "ReplaceableTextures\\RuinsTree\\RuinsTree.blp",
+		37: "ReplaceableTextures\\OutlandMushroomTree\\MushroomTree.blp",
+	}
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type ModelTextureRequest struct {
+	Path      string
+	TeamColor int
+	// Decode sends the textures as PNG instead of the original BLP
+	Decode bool
+}
+
+type ModelTexture struct {
+	Path          string
+	ReplaceableId uint32
+	Found         bool
+	// Url points at the texture on the resource server, Data holds the texture itself when the server isn't running
+	Url  string
+	Data string
+}
+
+/**
+*    PRIVATE STRUCTURES
+ */
+type textureEntry struct {
+	Path   string
+	File   string
+	Custom bool
+}
+
+func forgetTextureIndex() {
+	textureIndex = nil
+}
+
+// getTextureIndex returns the texture index along with the key it was built for
+func getTextureIndex() (map[string]*textureEntry, string, error) {
+	key := getModelCatalogKey(getResourceVersionName())
+	if configuration.InDir != nil {
+		key += "|" + *configuration.InDir
+	}
+
+	if textureIndex != nil && textureIndexKey == key {
+		return textureIndex, key, nil
+	}
+
+	root, err := getResourceRoot()
+	if err != nil {
+		return nil, "", err
+	}
+
+	index := make(map[string]*textureEntry)
+	err = scanTextures(index, root, false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Imported files replace the ones that come with the game
+	if configuration.InDir != nil {
+		err = scanTextures(index, *configuration.InDir, true)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	textureIndex = index
+	textureIndexKey = key
+
+	return textureIndex, key, nil
+}
+
+func scanTextures(index map[string]*textureEntry, root string, custom bool) error {
+	if flag, err := exists(root); err != nil || !flag {
+		return err
+	}
+
+	return filepath.Walk(root, func(currentPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !strings.HasSuffix(strings.ToLower(info.Name()), ".blp") {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(root, currentPath)
+		if err != nil {
+			return err
+		}
+
+		path := strings.Replace(relativePath, string(filepath.Separator), "\\", -1)
+		index[strings.ToLower(path)] = &textureEntry{path, currentPath, custom}
+
+		return nil
+	})
+}
+
+// findTexture looks up a texture by the path a model or an Art field uses for it
+func findTexture(path string) (*textureEntry, error) {
+	index, _, err := getTextureIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	lowercasePath := strings.ToLower(strings.Replace(strings.Trim(path, "\""), "/", "\\", -1))
+	if !strings.HasSuffix(lowercasePath, ".blp") {
+		lowercasePath += ".blp"
+	}
+
+	return index[lowercasePath], nil
+}
+
+// fetchModelTextures finds the textures used by a model, replaceable textures are resolved to the ones the
+// game would use for the requested team color
+func fetchModelTextures(request *ModelTextureRequest) ([]*ModelTexture, error) {
+	if request.TeamColor < 0 || request.TeamColor > MAX_TEAM_COLOR {
+		return nil, fmt.Errorf("team color has to be between 0 and %d", MAX_TEAM_COLOR)
+	}
+
+	model, err := readModelInfo(request.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := startResourceServer()
+	if err != nil {
+		serverURL = ""
+	}
+
+	modelTextures := []*ModelTexture{}
+	for _, texture := range model.Textures {
+		modelTexture := &ModelTexture{Path: texture.Path, ReplaceableId: texture.ReplaceableId}
+		if modelTexture.Path == "" {
+			replaceableTexture, ok := replaceableTextures[texture.ReplaceableId]
+			if !ok {
+				modelTextures = append(modelTextures, modelTexture)
+				continue
+			}
+
+			if strings.Contains(replaceableTexture, "%") {
+				replaceableTexture = fmt.Sprintf(replaceableTexture, request.TeamColor)
+			}
+
+			modelTexture.Path = replaceableTexture
+		}
+
+		textureEntry, err := findTexture(modelTexture.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if textureEntry != nil {
+			modelTexture.Found = true
+			if serverURL != "" {
+				modelTexture.Url = serverURL + (&url.URL{Path: strings.Replace(textureEntry.Path, "\\", "/", -1)}).EscapedPath()
+				if request.Decode {
+					modelTexture.Url += TEXTURE_PNG_QUERY
+				}
+			} else {
+				modelTexture.Data, err = readTexture(textureEntry.File, request.Decode)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		modelTextures = append(modelTextures, modelTexture)
+	}
+
+	return modelTextures, nil
+}
+
+// readTexture returns a texture base64 encoded, either as it is or converted to PNG
+func readTexture(file string, decode bool) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	if decode {
+		if data, err = decodeTexture(data); err != nil {
+			return "", err
+		}
+	}
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// decodeTexture converts a BLP texture to PNG
+func decodeTexture(data []byte) ([]byte, error) {
+	img, err := blp.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	err = png.Encode(&buffer, img)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
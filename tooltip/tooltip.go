@@ -0,0 +1,255 @@
+// Package tooltip reads the markup Warcraft III uses in names and tooltips.
+//
+// A color code is |c followed by eight hex digits, the alpha and the red, green and blue of the color, and the
+// color lasts until |r or the next color code. |n starts a new line and || is a pipe. Values of objects can be
+// placed in the text with <Id,Field> and <Id,Field,%> shows a fraction as a percentage
+package tooltip
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TokenType tells what a token of a tooltip is
+type TokenType int
+
+const (
+	TEXT TokenType = iota
+	COLOR
+	RESET
+	NEWLINE
+	REFERENCE
+	INVALID
+)
+
+// Token is a piece of a tooltip. Raw holds the token the way it's written and Offset is where it starts in bytes
+type Token struct {
+	Type   TokenType
+	Raw    string
+	Offset int
+
+	// Text of TEXT tokens, the RRGGBB of COLOR tokens and what's wrong with INVALID tokens
+	Text string
+
+	// The object and field a REFERENCE points at
+	Id      string
+	Field   string
+	Percent bool
+}
+
+// Issue is a problem with the markup of a tooltip that would show up in the game
+type Issue struct {
+	Offset  int
+	Raw     string
+	Message string
+}
+
+// Resolver returns the value of a field of an object, false means there is no such object or field
+type Resolver func(id string, field string) (string, bool)
+
+// Tokenize splits a tooltip into tokens, markup that can't be read becomes an INVALID token
+func Tokenize(text string) []Token {
+	var tokens []Token
+	var plain strings.Builder
+	plainOffset := 0
+
+	flush := func(offset int) {
+		if plain.Len() > 0 {
+			tokens = append(tokens, Token{Type: TEXT, Raw: text[plainOffset:offset], Offset: plainOffset, Text: plain.String()})
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		token, ok := readMarkup(text, i)
+		if !ok {
+			if plain.Len() == 0 {
+				plainOffset = i
+			}
+
+			plain.WriteByte(text[i])
+			i++
+			continue
+		}
+
+		if token.Type == TEXT {
+			if plain.Len() == 0 {
+				plainOffset = i
+			}
+
+			plain.WriteString(token.Text)
+			i += len(token.Raw)
+			continue
+		}
+
+		flush(i)
+		tokens = append(tokens, token)
+		i += len(token.Raw)
+	}
+
+	flush(len(text))
+
+	return tokens
+}
+
+// readMarkup reads the markup that starts at an offset, false means the offset is plain text
+func readMarkup(text string, offset int) (Token, bool) {
+	rest := text[offset:]
+	switch {
+	case strings.HasPrefix(rest, "||"):
+		return Token{Type: TEXT, Raw: "||", Offset: offset, Text: "|"}, true
+	case len(rest) > 1 && rest[0] == '|':
+		switch rest[1] {
+		case 'c', 'C':
+			if len(rest) < 10 || !isHex(rest[2:10]) {
+				end := 2
+				for end < len(rest) && end < 10 && isHex(rest[end:end+1]) {
+					end++
+				}
+
+				return Token{Type: INVALID, Raw: rest[:end], Offset: offset, Text: "a color code needs eight hex digits"}, true
+			}
+
+			return Token{Type: COLOR, Raw: rest[:10], Offset: offset, Text: strings.ToLower(rest[4:10])}, true
+		case 'r', 'R':
+			return Token{Type: RESET, Raw: rest[:2], Offset: offset}, true
+		case 'n', 'N':
+			return Token{Type: NEWLINE, Raw: rest[:2], Offset: offset}, true
+		}
+
+		return Token{Type: INVALID, Raw: rest[:2], Offset: offset, Text: fmt.Sprintf("|%c is not a known code", rest[1])}, true
+	case rest == "|":
+		return Token{Type: INVALID, Raw: rest, Offset: offset, Text: "the tooltip ends with a |"}, true
+	case rest[0] == '<':
+		end := strings.IndexAny(rest[1:], "<>")
+		if end < 0 || rest[1+end] != '>' {
+			return Token{}, false
+		}
+
+		parts := strings.Split(rest[1:1+end], ",")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" || (len(parts) == 3 && parts[2] != "%") {
+			return Token{}, false
+		}
+
+		return Token{Type: REFERENCE, Raw: rest[:end+2], Offset: offset, Id: parts[0], Field: parts[1], Percent: len(parts) == 3}, true
+	}
+
+	return Token{}, false
+}
+
+func isHex(text string) bool {
+	for _, c := range text {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Validate reports markup that would show up wrong in the game: codes that can't be read, colors that are
+// never reset or reset without being set and references the resolver doesn't know, which is skipped without one
+func Validate(text string, resolve Resolver) []*Issue {
+	issues := []*Issue{}
+
+	var open *Token
+	tokens := Tokenize(text)
+	for i := range tokens {
+		token := &tokens[i]
+		switch token.Type {
+		case INVALID:
+			issues = append(issues, &Issue{token.Offset, token.Raw, token.Text})
+		case COLOR:
+			// A color ends where the next one starts, which the game shows just fine
+			open = token
+		case RESET:
+			if open == nil {
+				issues = append(issues, &Issue{token.Offset, token.Raw, "there is no color to reset"})
+			}
+
+			open = nil
+		case REFERENCE:
+			if resolve == nil {
+				continue
+			}
+
+			if _, ok := resolve(token.Id, token.Field); !ok {
+				issues = append(issues, &Issue{token.Offset, token.Raw, fmt.Sprintf("%s has no %s", token.Id, token.Field)})
+			}
+		}
+	}
+
+	if open != nil {
+		issues = append(issues, &Issue{open.Offset, open.Raw, "the color is never reset"})
+	}
+
+	return issues
+}
+
+// Render turns a tooltip into HTML, colors become spans and new lines line breaks. References are replaced with
+// their values and the ones that can't be resolved are kept in a span with the tooltip-missing class
+func Render(text string, resolve Resolver) string {
+	var builder strings.Builder
+	colored := false
+	for _, token := range Tokenize(text) {
+		switch token.Type {
+		case TEXT:
+			builder.WriteString(html.EscapeString(token.Text))
+		case INVALID:
+			builder.WriteString(html.EscapeString(token.Raw))
+		case COLOR:
+			if colored {
+				builder.WriteString("</span>")
+			}
+
+			fmt.Fprintf(&builder, `<span style="color: #%s">`, token.Text)
+			colored = true
+		case RESET:
+			if colored {
+				builder.WriteString("</span>")
+			}
+
+			colored = false
+		case NEWLINE:
+			builder.WriteString("<br>")
+		case REFERENCE:
+			var value string
+			var ok bool
+			if resolve != nil {
+				value, ok = resolve(token.Id, token.Field)
+			}
+
+			if ok {
+				builder.WriteString(html.EscapeString(FormatValue(value, token.Percent)))
+			} else {
+				builder.WriteString(`<span class="tooltip-missing">` + html.EscapeString(token.Raw) + `</span>`)
+			}
+		}
+	}
+
+	if colored {
+		builder.WriteString("</span>")
+	}
+
+	return builder.String()
+}
+
+// FormatValue writes a value the way the game shows it in a tooltip, numbers lose trailing zeros and percentages
+// are multiplied by a hundred
+func FormatValue(value string, percent bool) string {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+
+	if percent {
+		number *= 100
+	}
+
+	return strconv.FormatFloat(math.Round(number*100)/100, 'f', -1, 64)
+}
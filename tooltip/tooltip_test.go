@@ -0,0 +1,114 @@
+package tooltip
+
+import (
+	"reflect"
+	"testing"
+)
+
+func resolveTestReference(id string, field string) (string, bool) {
+	if id == "AHbz" && field == "DataA1" {
+		return "0.25", true
+	}
+
+	return "", false
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text   string
+		tokens []Token
+	}{
+		{"", nil},
+		{"Footman", []Token{{Type: TEXT, Raw: "Footman", Offset: 0, Text: "Footman"}}},
+		{"a||b", []Token{{Type: TEXT, Raw: "a||b", Offset: 0, Text: "a|b"}}},
+		{"|cffFF0000Red|r", []Token{
+			{Type: COLOR, Raw: "|cffFF0000", Offset: 0, Text: "ff0000"},
+			{Type: TEXT, Raw: "Red", Offset: 10, Text: "Red"},
+			{Type: RESET, Raw: "|r", Offset: 13},
+		}},
+		{"A|NB", []Token{
+			{Type: TEXT, Raw: "A", Offset: 0, Text: "A"},
+			{Type: NEWLINE, Raw: "|N", Offset: 1},
+			{Type: TEXT, Raw: "B", Offset: 3, Text: "B"},
+		}},
+		{"<AHbz,DataA1,%>", []Token{{Type: REFERENCE, Raw: "<AHbz,DataA1,%>", Offset: 0, Id: "AHbz", Field: "DataA1", Percent: true}}},
+		{"<AHbz>", []Token{{Type: TEXT, Raw: "<AHbz>", Offset: 0, Text: "<AHbz>"}}},
+		{"|cff00", []Token{{Type: INVALID, Raw: "|cff00", Offset: 0, Text: "a color code needs eight hex digits"}}},
+		{"|x", []Token{{Type: INVALID, Raw: "|x", Offset: 0, Text: "|x is not a known code"}}},
+		{"End|", []Token{
+			{Type: TEXT, Raw: "End", Offset: 0, Text: "End"},
+			{Type: INVALID, Raw: "|", Offset: 3, Text: "the tooltip ends with a |"},
+		}},
+	}
+
+	for _, test := range tests {
+		if tokens := Tokenize(test.text); !reflect.DeepEqual(tokens, test.tokens) {
+			t.Errorf("Tokenize(%q) = %+v, expected %+v", test.text, tokens, test.tokens)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		text    string
+		resolve Resolver
+		issues  []*Issue
+	}{
+		{"|cffff0000Red|r", resolveTestReference, []*Issue{}},
+		{"|cffff0000Red|cff00ff00Green|r", resolveTestReference, []*Issue{}},
+		{"|cffff0000Red", resolveTestReference, []*Issue{{0, "|cffff0000", "the color is never reset"}}},
+		{"Red|r", resolveTestReference, []*Issue{{3, "|r", "there is no color to reset"}}},
+		{"<AHbz,DataA1> <AHbz,DataZ9>", resolveTestReference, []*Issue{{14, "<AHbz,DataZ9>", "AHbz has no DataZ9"}}},
+		{"<AHbz,DataZ9>", nil, []*Issue{}},
+		{"|q|cff00", nil, []*Issue{{0, "|q", "|q is not a known code"}, {2, "|cff00", "a color code needs eight hex digits"}}},
+	}
+
+	for _, test := range tests {
+		if issues := Validate(test.text, test.resolve); !reflect.DeepEqual(issues, test.issues) {
+			t.Errorf("Validate(%q) reported:", test.text)
+			for _, issue := range issues {
+				t.Errorf("%+v", issue)
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		text string
+		html string
+	}{
+		{"|cffff0000Red|r and <b>", `<span style="color: #ff0000">Red</span> and &lt;b&gt;`},
+		{"|cffff0000Red|cff00ff00Green", `<span style="color: #ff0000">Red</span><span style="color: #00ff00">Green</span>`},
+		{"A|nB||C", "A<br>B|C"},
+		{"Deals <AHbz,DataA1,%>% damage", "Deals 25% damage"},
+		{"<AHbz,DataZ9>", `<span class="tooltip-missing">&lt;AHbz,DataZ9&gt;</span>`},
+		{"|x", "|x"},
+	}
+
+	for _, test := range tests {
+		if html := Render(test.text, resolveTestReference); html != test.html {
+			t.Errorf("Render(%q) = %q, expected %q", test.text, html, test.html)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value   string
+		percent bool
+		text    string
+	}{
+		{"0.250", false, "0.25"},
+		{"0.25", true, "25"},
+		{"3.14159", false, "3.14"},
+		{" 12 ", false, "12"},
+		{`"Text"`, false, "Text"},
+	}
+
+	for _, test := range tests {
+		if text := FormatValue(test.value, test.percent); text != test.text {
+			t.Errorf("FormatValue(%q, %v) = %q, expected %q", test.value, test.percent, text, test.text)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/asticode/go-astilectron-demo/sylk"
+	"github.com/asticode/go-astilectron-demo/tooltip"
+	"gopkg.in/volatiletech/null.v6"
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type TooltipPreview struct {
+	Html   string
+	Issues []*tooltip.Issue
+}
+
+type TooltipIssue struct {
+	Category string
+	Id       string
+	Field    string
+	Offset   int
+	Raw      string
+	Message  string
+}
+
+// resolveTooltipReference returns the value an <Id,Field> reference of a tooltip points at. Tooltips mostly refer
+// to abilities but units and items can be referred to as well, fields are matched without regard to case since the
+// game doesn't care either
+func resolveTooltipReference(id string, field string) (string, bool) {
+	for _, name := range []string{CATEGORY_ABILITIES, CATEGORY_UNITS, CATEGORY_ITEMS} {
+		category, _ := getSpreadsheetCategory(name)
+		object := reflect.ValueOf(category.Objects()).MapIndex(reflect.ValueOf(id))
+		if !object.IsValid() {
+			continue
+		}
+
+		for _, candidate := range category.allFields() {
+			if !strings.EqualFold(candidate.Name, field) {
+				continue
+			}
+
+			value, ok := objectField(object.Elem(), candidate.Index)
+			if !ok {
+				return "", false
+			}
+
+			nullString := value.Interface().(null.String)
+
+			return sylk.Unquote(nullString.String), nullString.Valid
+		}
+
+		return "", false
+	}
+
+	return "", false
+}
+
+// previewTooltip renders a tooltip for the preview pane along with whatever is wrong with its markup
+func previewTooltip(text string) *TooltipPreview {
+	text = sylk.Unquote(text)
+
+	return &TooltipPreview{tooltip.Render(text, resolveTooltipReference), tooltip.Validate(text, resolveTooltipReference)}
+}
+
+// validateTooltips checks the markup of every string of the units, items and abilities
+func validateTooltips() []*TooltipIssue {
+	issues := []*TooltipIssue{}
+	for _, category := range spreadsheetCategories {
+		objects := reflect.ValueOf(category.Objects())
+		fields := localizedFields(category)
+		for _, id := range sortedObjectKeys(category.Objects()) {
+			object := objects.MapIndex(reflect.ValueOf(id)).Elem()
+			for _, field := range fields {
+				text, _ := fieldText(object, field)
+				for _, issue := range tooltip.Validate(sylk.Unquote(text), resolveTooltipReference) {
+					issues = append(issues, &TooltipIssue{category.Name, id, field.Name, issue.Offset, issue.Raw, issue.Message})
+				}
+			}
+		}
+	}
+
+	return issues
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+/**
+*    PRIVATE STRUCTURES
+ */
+// txtDocument keeps every line of a TXT file so that it can be edited without losing comments, blank lines or
+// the order of sections and keys
+type txtDocument struct {
+	lines []string
+	eols  []string
+}
+
+type txtSection struct {
+	id      string
+	header  int
+	lastKey int
+	keys    map[string]int
+}
+
+func parseTxtDocument(data []byte) *txtDocument {
+	document := &txtDocument{}
+	for len(data) > 0 {
+		end := bytes.IndexAny(data, "\r\n")
+		if end < 0 {
+			document.lines = append(document.lines, string(data))
+			document.eols = append(document.eols, "")
+			break
+		}
+
+		document.lines = append(document.lines, string(data[:end]))
+		if data[end] == '\r' && end+1 < len(data) && data[end+1] == '\n' {
+			document.eols = append(document.eols, "\r\n")
+			data = data[end+2:]
+		} else {
+			document.eols = append(document.eols, string(data[end]))
+			data = data[end+1:]
+		}
+	}
+
+	return document
+}
+
+func (document *txtDocument) bytes() []byte {
+	var buffer bytes.Buffer
+	for i, line := range document.lines {
+		buffer.WriteString(line)
+		buffer.WriteString(document.eols[i])
+	}
+
+	return buffer.Bytes()
+}
+
+// lineEnding returns the line ending most of the lines use
+func (document *txtDocument) lineEnding() string {
+	counts := make(map[string]int)
+	for _, eol := range document.eols {
+		if eol != "" {
+			counts[eol]++
+		}
+	}
+
+	eol := "\r\n"
+	if counts["\n"] > counts[eol] {
+		eol = "\n"
+	}
+
+	return eol
+}
+
+// sections reads the sections in the order they appear, lines are interpreted the same way readTxtSections does
+func (document *txtDocument) sections() ([]*txtSection, map[string]*txtSection) {
+	var order []*txtSection
+	byId := make(map[string]*txtSection)
+
+	var current *txtSection
+	for i, rawLine := range document.lines {
+		line := strings.TrimSpace(rawLine)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		if id, ok := txtSectionId(line); ok {
+			current = byId[id]
+			if current == nil {
+				current = &txtSection{id: id, header: i, lastKey: i, keys: make(map[string]int)}
+				byId[id] = current
+				order = append(order, current)
+			}
+
+			continue
+		}
+
+		index := strings.Index(line, "=")
+		if current != nil && index > 0 {
+			key := strings.ToLower(line[:index])
+			if _, ok := current.keys[key]; !ok {
+				current.keys[key] = i
+			}
+
+			current.lastKey = i
+		}
+	}
+
+	return order, byId
+}
+
+func txtSectionId(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+		return line[1 : len(line)-1], true
+	}
+
+	return "", false
+}
+
+// sortSections orders the sections by their id, every section takes the lines up to the next section along with it
+func (document *txtDocument) sortSections() {
+	type block struct {
+		id    string
+		lines []string
+		eols  []string
+	}
+
+	var blocks []*block
+	start := len(document.lines)
+	for i, line := range document.lines {
+		if id, ok := txtSectionId(line); ok {
+			if len(blocks) < 1 {
+				start = i
+			}
+
+			blocks = append(blocks, &block{id: id})
+		}
+
+		if len(blocks) > 0 {
+			current := blocks[len(blocks)-1]
+			current.lines = append(current.lines, line)
+			current.eols = append(current.eols, document.eols[i])
+		}
+	}
+
+	less := func(i, j int) bool {
+		return blocks[i].id < blocks[j].id
+	}
+
+	if sort.SliceIsSorted(blocks, less) {
+		return
+	}
+
+	sort.SliceStable(blocks, less)
+
+	eol := document.lineEnding()
+	lines, eols := document.lines[:start:start], document.eols[:start:start]
+	for i, current := range blocks {
+		if last := len(current.eols) - 1; current.eols[last] == "" {
+			current.eols[last] = eol
+		}
+
+		// Sections that end up next to each other are kept apart by a blank line
+		if i < len(blocks)-1 && strings.TrimSpace(current.lines[len(current.lines)-1]) != "" {
+			current.lines = append(current.lines, "")
+			current.eols = append(current.eols, eol)
+		}
+
+		lines = append(lines, current.lines...)
+		eols = append(eols, current.eols...)
+	}
+
+	document.lines, document.eols = lines, eols
+}
+
+// value returns the key and the value of a key=value line
+func (document *txtDocument) value(line int) (string, string) {
+	rawLine := document.lines[line]
+	index := strings.Index(rawLine, "=")
+
+	return strings.TrimSpace(rawLine[:index]), strings.TrimSpace(rawLine[index+1:])
+}
+
+// setValue replaces the value of a key=value line and keeps the key the way it was written
+func (document *txtDocument) setValue(line int, value string) {
+	rawLine := document.lines[line]
+	document.lines[line] = rawLine[:strings.Index(rawLine, "=")+1] + value
+}
+
+func (document *txtDocument) insertLines(index int, lines ...string) {
+	eol := document.lineEnding()
+	if index > 0 && document.eols[index-1] == "" {
+		document.eols[index-1] = eol
+	}
+
+	eols := make([]string, len(lines))
+	for i := range eols {
+		eols[i] = eol
+	}
+
+	document.lines = append(document.lines[:index], append(lines, document.lines[index:]...)...)
+	document.eols = append(document.eols[:index], append(eols, document.eols[index:]...)...)
+}
+
+func (document *txtDocument) removeLines(start int, end int) {
+	document.lines = append(document.lines[:start], document.lines[end:]...)
+	document.eols = append(document.eols[:start], document.eols[end:]...)
+}
+
+// removeSection removes a section with its keys and the blank lines after it, comments that come after the last
+// key are left alone since those usually belong to the next section
+func (document *txtDocument) removeSection(section *txtSection) {
+	end := section.lastKey + 1
+	for end < len(document.lines) && strings.TrimSpace(document.lines[end]) == "" {
+		end++
+	}
+
+	document.removeLines(section.header, end)
+}
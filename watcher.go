@@ -0,0 +1,750 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilectron"
+	"github.com/runi95/wts-parser/models"
+	"github.com/runi95/wts-parser/parser"
+)
+
+const (
+	INPUT_WATCH_INTERVAL = 2 * time.Second
+	CATEGORY_UNITS       = "units"
+	CATEGORY_ITEMS       = "items"
+	CATEGORY_ABILITIES   = "abilities"
+)
+
+var (
+	// Guards the object maps and dirty flags, the message handler and the input watcher both touch them
+	dataMutex sync.Mutex
+
+	dirtyUnits     = make(map[string]bool)
+	dirtyItems     = make(map[string]bool)
+	dirtyAbilities = make(map[string]bool)
+
+	activeInputWatcher *inputWatcher = nil
+
+	// Every file loadSLK knows about, listed in the order loadSLK parses them
+	watchedInputFiles = []watchedFile{
+		{"abilitydata.slk", CATEGORY_ABILITIES, true},
+		{"unitdata.slk", CATEGORY_UNITS, true},
+		{"unitabilities.slk", CATEGORY_UNITS, true},
+		{"unitui.slk", CATEGORY_UNITS, true},
+		{"unitweapons.slk", CATEGORY_UNITS, true},
+		{"unitbalance.slk", CATEGORY_UNITS, true},
+		{"campaignabilityfunc.txt", CATEGORY_ABILITIES, false},
+		{"campaignabilitystrings.txt", CATEGORY_ABILITIES, false},
+		{"campaignunitfunc.txt", CATEGORY_UNITS, false},
+		{"campaignunitstrings.txt", CATEGORY_UNITS, false},
+		{"commonabilityfunc.txt", CATEGORY_ABILITIES, false},
+		{"commonabilitystrings.txt", CATEGORY_ABILITIES, false},
+		{"humanabilityfunc.txt", CATEGORY_ABILITIES, false},
+		{"humanabilitystrings.txt", CATEGORY_ABILITIES, false},
+		{"humanunitfunc.txt", CATEGORY_UNITS, false},
+		{"humanunitstrings.txt", CATEGORY_UNITS, false},
+		{"neutralabilityfunc.txt", CATEGORY_ABILITIES, false},
+		{"neutralabilitystrings.txt", CATEGORY_ABILITIES, false},
+		{"neutralunitfunc.txt", CATEGORY_UNITS, false},
+		{"neutralunitstrings.txt", CATEGORY_UNITS, false},
+		{"nightelfabilityfunc.txt", CATEGORY_ABILITIES, false},
+		{"nightelfabilitystrings.txt", CATEGORY_ABILITIES, false},
+		{"nightelfunitfunc.txt", CATEGORY_UNITS, false},
+		{"nightelfunitstrings.txt", CATEGORY_UNITS, false},
+		{"orcabilityfunc.txt", CATEGORY_ABILITIES, false},
+		{"orcabilitystrings.txt", CATEGORY_ABILITIES, false},
+		{"orcunitfunc.txt", CATEGORY_UNITS, false},
+		{"orcunitstrings.txt", CATEGORY_UNITS, false},
+		{"undeadabilityfunc.txt", CATEGORY_ABILITIES, false},
+		{"undeadabilitystrings.txt", CATEGORY_ABILITIES, false},
+		{"undeadunitfunc.txt", CATEGORY_UNITS, false},
+		{"undeadunitstrings.txt", CATEGORY_UNITS, false},
+		{"itemabilityfunc.txt", CATEGORY_ABILITIES, false},
+		{"itemabilitystrings.txt", CATEGORY_ABILITIES, false},
+		{"itemdata.slk", CATEGORY_ITEMS, true},
+		{"itemfunc.txt", CATEGORY_ITEMS, false},
+		{"itemstrings.txt", CATEGORY_ITEMS, false},
+		{"war3map.wts", CATEGORY_STRINGS, false},
+		{"destructabledata.slk", CATEGORY_DESTRUCTABLES, true},
+		{"worldeditstrings.txt", CATEGORY_WORLD_EDIT_STRINGS, false},
+		{"doodads.slk", CATEGORY_DOODADS, true},
+		{"doodadfunc.txt", CATEGORY_DOODADS, false},
+		{"doodadstrings.txt", CATEGORY_DOODADS, false},
+		{"splatdata.slk", CATEGORY_SPLATS, true},
+		{"ubersplatdata.slk", CATEGORY_UBERSPLATS, true},
+		{"spawndata.slk", CATEGORY_SPAWNS, true},
+		{"animsounds.slk", CATEGORY_ANIM_SOUNDS, true},
+		{"animlookups.slk", CATEGORY_ANIM_LOOKUPS, true},
+	}
+)
+
+/**
+*    PUBLIC STRUCTURES
+ */
+type InputFileChanges struct {
+	Category  string
+	Files     []string
+	Added     []string
+	Changed   []string
+	Removed   []string
+	Conflicts []string
+}
+
+/**
+*    PRIVATE STRUCTURES
+ */
+type watchedFile struct {
+	Name     string
+	Category string
+	IsSlk    bool
+}
+
+type fileStamp struct {
+	Name    string
+	ModTime time.Time
+	Size    int64
+}
+
+type inputWatcher struct {
+	window    *astilectron.Window
+	directory string
+	stamps    map[string]fileStamp
+	baseline  map[string]map[string]string
+	stop      chan struct{}
+}
+
+// startInputWatcher replaces any running watcher with one that polls the given input directory
+func startInputWatcher(w *astilectron.Window, directory string) {
+	stopInputWatcher()
+
+	watcher := &inputWatcher{
+		window:    w,
+		directory: directory,
+		stop:      make(chan struct{}),
+	}
+
+	activeInputWatcher = watcher
+	go watcher.run()
+}
+
+func stopInputWatcher() {
+	if activeInputWatcher != nil {
+		close(activeInputWatcher.stop)
+		activeInputWatcher = nil
+	}
+}
+
+// resyncInputWatcher makes the watcher accept the current state of the input directory without reporting it,
+// this is used after we've written to the directory ourselves. The caller must hold dataMutex.
+func resyncInputWatcher() {
+	if activeInputWatcher != nil {
+		activeInputWatcher.resync()
+	}
+}
+
+func markDirty(category string, id string) {
+	switch category {
+	case CATEGORY_UNITS:
+		dirtyUnits[id] = true
+	case CATEGORY_ITEMS:
+		dirtyItems[id] = true
+	case CATEGORY_ABILITIES:
+		dirtyAbilities[id] = true
+	case CATEGORY_DESTRUCTABLES:
+		dirtyDestructables[id] = true
+	case CATEGORY_DOODADS:
+		dirtyDoodads[id] = true
+	case CATEGORY_SPLATS:
+		dirtySplats[id] = true
+	case CATEGORY_UBERSPLATS:
+		dirtyUberSplats[id] = true
+	case CATEGORY_SPAWNS:
+		dirtySpawns[id] = true
+	case CATEGORY_ANIM_SOUNDS:
+		dirtyAnimSounds[id] = true
+	case CATEGORY_ANIM_LOOKUPS:
+		dirtyAnimLookups[id] = true
+	}
+}
+
+// dirtyObjects returns the objects of a category that have been edited since they were last saved
+func dirtyObjects(category string) map[string]bool {
+	switch category {
+	case CATEGORY_UNITS:
+		return dirtyUnits
+	case CATEGORY_ITEMS:
+		return dirtyItems
+	case CATEGORY_ABILITIES:
+		return dirtyAbilities
+	case CATEGORY_DESTRUCTABLES:
+		return dirtyDestructables
+	case CATEGORY_DOODADS:
+		return dirtyDoodads
+	case CATEGORY_SPLATS:
+		return dirtySplats
+	case CATEGORY_UBERSPLATS:
+		return dirtyUberSplats
+	case CATEGORY_SPAWNS:
+		return dirtySpawns
+	case CATEGORY_ANIM_SOUNDS:
+		return dirtyAnimSounds
+	case CATEGORY_ANIM_LOOKUPS:
+		return dirtyAnimLookups
+	}
+
+	return map[string]bool{}
+}
+
+func clearDirty(categories ...string) {
+	for _, category := range categories {
+		switch category {
+		case CATEGORY_UNITS:
+			dirtyUnits = make(map[string]bool)
+		case CATEGORY_ITEMS:
+			dirtyItems = make(map[string]bool)
+		case CATEGORY_ABILITIES:
+			dirtyAbilities = make(map[string]bool)
+		case CATEGORY_DESTRUCTABLES:
+			dirtyDestructables = make(map[string]bool)
+		case CATEGORY_DOODADS:
+			dirtyDoodads = make(map[string]bool)
+		case CATEGORY_SPLATS:
+			dirtySplats = make(map[string]bool)
+		case CATEGORY_UBERSPLATS:
+			dirtyUberSplats = make(map[string]bool)
+		case CATEGORY_SPAWNS:
+			dirtySpawns = make(map[string]bool)
+		case CATEGORY_ANIM_SOUNDS:
+			dirtyAnimSounds = make(map[string]bool)
+		case CATEGORY_ANIM_LOOKUPS:
+			dirtyAnimLookups = make(map[string]bool)
+		}
+	}
+}
+
+// isObjectCategory tells whether the files of a category hold objects, the other ones hold strings
+func isObjectCategory(category string) bool {
+	return category != CATEGORY_STRINGS && category != CATEGORY_WORLD_EDIT_STRINGS
+}
+
+// gameFolder returns the folder below the input folder that the game keeps the tables of a category in,
+// a table in the input folder itself takes precedence
+func gameFolder(category string) string {
+	switch category {
+	case CATEGORY_SPLATS, CATEGORY_UBERSPLATS, CATEGORY_SPAWNS:
+		return SPLATS_FOLDER
+	case CATEGORY_ANIM_SOUNDS, CATEGORY_ANIM_LOOKUPS:
+		return soundInfoFolder
+	}
+
+	return ""
+}
+
+// bundledFile returns the name of the copy of a watched file that ships with the editor, if there is one
+func bundledFile(file watchedFile) string {
+	switch gameFolder(file.Category) {
+	case SPLATS_FOLDER:
+		return BUNDLED_SPLATS_PATH + "/" + file.Name
+	case soundInfoFolder:
+		return BUNDLED_SOUND_INFO_PATH + "/" + file.Name
+	}
+
+	return ""
+}
+
+// isWatchedIn tells whether a file is watched in a folder below the input folder
+func isWatchedIn(name string, folder string) bool {
+	for _, file := range watchedInputFiles {
+		if file.Name == name && gameFolder(file.Category) == folder {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watchedCategories returns every category of the watched files once
+func watchedCategories() []string {
+	categories := []string{}
+	for _, file := range watchedInputFiles {
+		if !containsString(categories, file.Category) {
+			categories = append(categories, file.Category)
+		}
+	}
+
+	return categories
+}
+
+func (watcher *inputWatcher) run() {
+	dataMutex.Lock()
+	if activeInputWatcher == watcher {
+		watcher.resync()
+	}
+	dataMutex.Unlock()
+
+	ticker := time.NewTicker(INPUT_WATCH_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-watcher.stop:
+			return
+		case <-ticker.C:
+			watcher.poll()
+		}
+	}
+}
+
+func (watcher *inputWatcher) resync() {
+	stamps, err := watcher.scan()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	watcher.stamps = stamps
+	watcher.baseline = make(map[string]map[string]string)
+	for _, category := range watchedCategories() {
+		watcher.baseline[category] = fingerprintObjects(watcher.parseCategory(category))
+	}
+}
+
+func (watcher *inputWatcher) poll() {
+	dataMutex.Lock()
+	defer dataMutex.Unlock()
+
+	// The watcher might have been replaced while we were waiting for the lock
+	if activeInputWatcher != watcher {
+		return
+	}
+
+	stamps, err := watcher.scan()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	changedFiles := make(map[string][]string)
+	for _, file := range watchedInputFiles {
+		newStamp, newOk := stamps[file.Name]
+		oldStamp, oldOk := watcher.stamps[file.Name]
+		if newOk != oldOk || (newOk && (!newStamp.ModTime.Equal(oldStamp.ModTime) || newStamp.Size != oldStamp.Size)) {
+			name := newStamp.Name
+			if !newOk {
+				name = oldStamp.Name
+			}
+
+			changedFiles[file.Category] = append(changedFiles[file.Category], name)
+		}
+	}
+
+	if len(changedFiles) < 1 {
+		return
+	}
+
+	watcher.stamps = stamps
+
+	categories := make([]string, 0, len(changedFiles))
+	for category := range changedFiles {
+		categories = append(categories, category)
+	}
+
+	sort.Strings(categories)
+
+	for _, category := range categories {
+		log.Printf("Reloading %v after external changes to %v\n", category, changedFiles[category])
+
+		changes := watcher.reloadCategory(category)
+		changes.Files = changedFiles[category]
+		rememberInputFiles(watcher.directory, category)
+
+		if len(changes.Added) > 0 || len(changes.Changed) > 0 || len(changes.Removed) > 0 || len(changes.Conflicts) > 0 {
+			watcher.window.SendMessage(EventMessage{"inputFilesChanged", changes})
+		}
+	}
+}
+
+func (watcher *inputWatcher) scan() (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp)
+
+	// Files in the input folder itself come first, the game folders are only looked at for the rest
+	for _, folder := range []string{"", SPLATS_FOLDER, soundInfoFolder} {
+		filesInDirectory, err := ioutil.ReadDir(filepath.Join(watcher.directory, folder))
+		if err != nil {
+			if folder == "" {
+				return stamps, err
+			}
+
+			continue
+		}
+
+		for _, file := range filesInDirectory {
+			name := strings.ToLower(file.Name())
+			if _, ok := stamps[name]; ok || file.IsDir() || (folder != "" && !isWatchedIn(name, folder)) {
+				continue
+			}
+
+			stamps[name] = fileStamp{filepath.Join(folder, file.Name()), file.ModTime(), file.Size()}
+		}
+	}
+
+	return stamps, nil
+}
+
+// parseCategory reads every file belonging to a single category into a fresh object map
+func (watcher *inputWatcher) parseCategory(category string) interface{} {
+	var objectMap interface{}
+	switch category {
+	case CATEGORY_UNITS:
+		objectMap = make(map[string]*models.SLKUnit)
+	case CATEGORY_ITEMS:
+		objectMap = make(map[string]*models.SLKItem)
+	case CATEGORY_ABILITIES:
+		objectMap = make(map[string]*models.SLKAbility)
+	case CATEGORY_DESTRUCTABLES:
+		objectMap = make(map[string]*SLKDestructable)
+	case CATEGORY_DOODADS:
+		objectMap = make(map[string]*SLKDoodad)
+	case CATEGORY_SPLATS:
+		objectMap = make(map[string]*SLKSplat)
+	case CATEGORY_UBERSPLATS:
+		objectMap = make(map[string]*SLKUberSplat)
+	case CATEGORY_SPAWNS:
+		objectMap = make(map[string]*SLKSpawn)
+	case CATEGORY_ANIM_SOUNDS:
+		objectMap = make(map[string]*SLKAnimSound)
+	case CATEGORY_ANIM_LOOKUPS:
+		objectMap = make(map[string]*SLKAnimLookup)
+	case CATEGORY_STRINGS, CATEGORY_WORLD_EDIT_STRINGS:
+		objectMap = make(map[string]string)
+	default:
+		return nil
+	}
+
+	for _, file := range watchedInputFiles {
+		if file.Category != category {
+			continue
+		}
+
+		// Rows of the input folder replace the rows of the tables that ship with the editor
+		if bundledName := bundledFile(file); bundledName != "" {
+			if fileBytes, err := readBundledFile(bundledName); err != nil {
+				log.Println(err)
+			} else if err = populateObjectMapWithSlkFileData(fileBytes, objectMap); err != nil {
+				log.Println(err)
+			}
+		}
+
+		stamp, ok := watcher.stamps[file.Name]
+		if !ok {
+			continue
+		}
+
+		fileBytes, err := ioutil.ReadFile(filepath.Join(watcher.directory, stamp.Name))
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		switch category {
+		case CATEGORY_UNITS:
+			if file.IsSlk {
+				parser.PopulateUnitMapWithSlkFileData(fileBytes, objectMap.(map[string]*models.SLKUnit))
+			} else {
+				parser.PopulateUnitMapWithTxtFileData(fileBytes, objectMap.(map[string]*models.SLKUnit))
+			}
+		case CATEGORY_ITEMS:
+			if file.IsSlk {
+				parser.PopulateItemMapWithSlkFileData(fileBytes, objectMap.(map[string]*models.SLKItem))
+			} else {
+				parser.PopulateItemMapWithTxtFileData(fileBytes, objectMap.(map[string]*models.SLKItem))
+			}
+		case CATEGORY_ABILITIES:
+			if file.IsSlk {
+				parser.PopulateAbilityMapWithSlkFileData(fileBytes, objectMap.(map[string]*models.SLKAbility))
+			} else {
+				parser.PopulateAbilityMapWithTxtFileData(fileBytes, objectMap.(map[string]*models.SLKAbility))
+			}
+		case CATEGORY_DESTRUCTABLES, CATEGORY_SPLATS, CATEGORY_UBERSPLATS, CATEGORY_SPAWNS, CATEGORY_ANIM_SOUNDS, CATEGORY_ANIM_LOOKUPS:
+			if err = populateObjectMapWithSlkFileData(fileBytes, objectMap); err != nil {
+				log.Println(err)
+			}
+		case CATEGORY_DOODADS:
+			if !file.IsSlk {
+				populateObjectMapWithTxtFileData(fileBytes, objectMap)
+			} else if err = populateObjectMapWithSlkFileData(fileBytes, objectMap); err != nil {
+				log.Println(err)
+			}
+		case CATEGORY_STRINGS:
+			for id, text := range parseWtsDocument(fileBytes).texts() {
+				objectMap.(map[string]string)[id] = text
+			}
+		case CATEGORY_WORLD_EDIT_STRINGS:
+			for key, value := range readWorldEditStrings(fileBytes) {
+				objectMap.(map[string]string)[key] = value
+			}
+		}
+	}
+
+	return objectMap
+}
+
+// reloadCategory compares the category on disk with what we saw last time and applies the differences
+// to the in-memory map, objects that have been edited locally are left alone and reported as conflicts
+func (watcher *inputWatcher) reloadCategory(category string) *InputFileChanges {
+	switch category {
+	case CATEGORY_STRINGS:
+		return watcher.reloadStringTable()
+	case CATEGORY_WORLD_EDIT_STRINGS:
+		return watcher.reloadWorldEditStrings()
+	}
+
+	changes := newInputFileChanges(category)
+
+	reloaded := watcher.parseCategory(category)
+	fingerprints := fingerprintObjects(reloaded)
+	previous := watcher.baseline[category]
+	watcher.baseline[category] = fingerprints
+
+	var current reflect.Value
+	var dirty map[string]bool
+	switch category {
+	case CATEGORY_UNITS:
+		if unitMap == nil {
+			unitMap = make(map[string]*models.SLKUnit)
+		}
+
+		current = reflect.ValueOf(unitMap)
+		dirty = dirtyUnits
+	case CATEGORY_ITEMS:
+		if itemMap == nil {
+			itemMap = make(map[string]*models.SLKItem)
+		}
+
+		current = reflect.ValueOf(itemMap)
+		dirty = dirtyItems
+	case CATEGORY_ABILITIES:
+		if abilityMap == nil {
+			abilityMap = make(map[string]*models.SLKAbility)
+		}
+
+		current = reflect.ValueOf(abilityMap)
+		dirty = dirtyAbilities
+	case CATEGORY_DESTRUCTABLES:
+		if destructableMap == nil {
+			destructableMap = make(map[string]*SLKDestructable)
+		}
+
+		current = reflect.ValueOf(destructableMap)
+		dirty = dirtyDestructables
+	case CATEGORY_DOODADS:
+		if doodadMap == nil {
+			doodadMap = make(map[string]*SLKDoodad)
+		}
+
+		current = reflect.ValueOf(doodadMap)
+		dirty = dirtyDoodads
+	case CATEGORY_SPLATS:
+		current = reflect.ValueOf(splatMap)
+		dirty = dirtySplats
+	case CATEGORY_UBERSPLATS:
+		current = reflect.ValueOf(uberSplatMap)
+		dirty = dirtyUberSplats
+	case CATEGORY_SPAWNS:
+		current = reflect.ValueOf(spawnMap)
+		dirty = dirtySpawns
+	case CATEGORY_ANIM_SOUNDS:
+		current = reflect.ValueOf(animSoundMap)
+		dirty = dirtyAnimSounds
+	case CATEGORY_ANIM_LOOKUPS:
+		current = reflect.ValueOf(animLookupMap)
+		dirty = dirtyAnimLookups
+	default:
+		return changes
+	}
+
+	reloadedValue := reflect.ValueOf(reloaded)
+	for id, fingerprint := range fingerprints {
+		previousFingerprint, existed := previous[id]
+		if existed && previousFingerprint == fingerprint {
+			continue
+		}
+
+		if dirty[id] {
+			changes.Conflicts = append(changes.Conflicts, id)
+			continue
+		}
+
+		current.SetMapIndex(reflect.ValueOf(id), reloadedValue.MapIndex(reflect.ValueOf(id)))
+		if existed {
+			changes.Changed = append(changes.Changed, id)
+		} else {
+			changes.Added = append(changes.Added, id)
+		}
+	}
+
+	for id := range previous {
+		if _, ok := fingerprints[id]; ok {
+			continue
+		}
+
+		if dirty[id] {
+			changes.Conflicts = append(changes.Conflicts, id)
+			continue
+		}
+
+		current.SetMapIndex(reflect.ValueOf(id), reflect.Value{})
+		changes.Removed = append(changes.Removed, id)
+	}
+
+	// Reloaded objects come with the references of the string table rather than the text
+	resolveStringReferences()
+
+	sortInputFileChanges(changes)
+
+	return changes
+}
+
+// reloadStringTable applies the strings that changed on disk to the string table, strings that have been
+// edited since the table was read are left alone and reported as conflicts
+func (watcher *inputWatcher) reloadStringTable() *InputFileChanges {
+	changes := newInputFileChanges(CATEGORY_STRINGS)
+
+	fingerprints := fingerprintObjects(watcher.parseCategory(CATEGORY_STRINGS))
+	previous := watcher.baseline[CATEGORY_STRINGS]
+	watcher.baseline[CATEGORY_STRINGS] = fingerprints
+
+	// Edits of the fields that reference the table have to be in the table before we can compare it
+	restoreStringReferences()
+	if stringTable == nil {
+		stringTable = parseWtsDocument(nil)
+	}
+
+	local := fingerprintObjects(stringTable.texts())
+
+	for id, fingerprint := range fingerprints {
+		previousFingerprint, existed := previous[id]
+		if existed && previousFingerprint == fingerprint {
+			continue
+		}
+
+		localFingerprint, ok := local[id]
+		if ok && localFingerprint == fingerprint {
+			continue
+		}
+
+		stringId, _ := strconv.Atoi(id)
+		if ok != existed || localFingerprint != previousFingerprint {
+			changes.Conflicts = append(changes.Conflicts, fmt.Sprintf("TRIGSTR_%03d", stringId))
+			continue
+		}
+
+		var text string
+		json.Unmarshal([]byte(fingerprint), &text)
+		if existed {
+			stringTable.setText(stringId, text)
+			changes.Changed = append(changes.Changed, fmt.Sprintf("TRIGSTR_%03d", stringId))
+		} else {
+			stringTable.insert(stringId, text)
+			changes.Added = append(changes.Added, fmt.Sprintf("TRIGSTR_%03d", stringId))
+		}
+	}
+
+	for id, previousFingerprint := range previous {
+		if _, ok := fingerprints[id]; ok {
+			continue
+		}
+
+		localFingerprint, ok := local[id]
+		if !ok {
+			continue
+		}
+
+		stringId, _ := strconv.Atoi(id)
+		if localFingerprint != previousFingerprint {
+			changes.Conflicts = append(changes.Conflicts, fmt.Sprintf("TRIGSTR_%03d", stringId))
+			continue
+		}
+
+		stringTable.remove(stringId)
+		changes.Removed = append(changes.Removed, fmt.Sprintf("TRIGSTR_%03d", stringId))
+	}
+
+	resolveStringReferences()
+	sortInputFileChanges(changes)
+
+	return changes
+}
+
+// reloadWorldEditStrings puts the strings of the input folder back on top of the ones of the base data,
+// nothing edits these strings so there are never any conflicts
+func (watcher *inputWatcher) reloadWorldEditStrings() *InputFileChanges {
+	changes := newInputFileChanges(CATEGORY_WORLD_EDIT_STRINGS)
+
+	reloaded := watcher.parseCategory(CATEGORY_WORLD_EDIT_STRINGS).(map[string]string)
+	fingerprints := fingerprintObjects(reloaded)
+	previous := watcher.baseline[CATEGORY_WORLD_EDIT_STRINGS]
+	watcher.baseline[CATEGORY_WORLD_EDIT_STRINGS] = fingerprints
+
+	for key, fingerprint := range fingerprints {
+		if previousFingerprint, existed := previous[key]; !existed {
+			changes.Added = append(changes.Added, key)
+		} else if previousFingerprint != fingerprint {
+			changes.Changed = append(changes.Changed, key)
+		}
+	}
+
+	for key := range previous {
+		if _, ok := fingerprints[key]; !ok {
+			changes.Removed = append(changes.Removed, key)
+		}
+	}
+
+	applyWorldEditStrings(reloaded)
+	sortInputFileChanges(changes)
+
+	return changes
+}
+
+func newInputFileChanges(category string) *InputFileChanges {
+	return &InputFileChanges{Category: category, Added: []string{}, Changed: []string{}, Removed: []string{}, Conflicts: []string{}}
+}
+
+func sortInputFileChanges(changes *InputFileChanges) {
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Changed)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Conflicts)
+}
+
+// fingerprintObjects serializes every object in a map so that we can detect changes without holding on to the
+// objects themselves, those end up in the in-memory maps and get modified by the editor
+func fingerprintObjects(objectMap interface{}) map[string]string {
+	fingerprints := make(map[string]string)
+	if objectMap == nil {
+		return fingerprints
+	}
+
+	value := reflect.ValueOf(objectMap)
+	for _, key := range value.MapKeys() {
+		data, err := json.Marshal(value.MapIndex(key).Interface())
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		fingerprints[key.String()] = string(data)
+	}
+
+	return fingerprints
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	WATCHED_STRING_TABLE = "STRING 1\r\n{\r\nFootman\r\n}\r\n\r\nSTRING 2\r\n{\r\nKnight\r\n}\r\n\r\nSTRING 3\r\n{\r\nPeasant\r\n}\r\n\r\nSTRING 4\r\n{\r\nRifleman\r\n}\r\n"
+	CHANGED_STRING_TABLE = "STRING 1\r\n{\r\nFootman\r\n}\r\n\r\nSTRING 2\r\n{\r\nPaladin\r\n}\r\n\r\nSTRING 4\r\n{\r\nMortar Team\r\n}\r\n\r\nSTRING 5\r\n{\r\nPriest\r\n}\r\n"
+)
+
+func TestReloadStringTableKeepsLocalEdits(t *testing.T) {
+	defer func(previous *wtsDocument) { stringTable = previous }(stringTable)
+	defer func(previous map[stringReference]*resolvedString) { stringReferences = previous }(stringReferences)
+
+	inputDirectory := t.TempDir()
+	path := filepath.Join(inputDirectory, STRING_TABLE_FILENAME)
+	if err := ioutil.WriteFile(path, []byte(WATCHED_STRING_TABLE), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stringTable = parseWtsDocument([]byte(WATCHED_STRING_TABLE))
+	stringReferences = make(map[stringReference]*resolvedString)
+
+	watcher := &inputWatcher{directory: inputDirectory}
+	watcher.resync()
+
+	stringTable.setText(4, "Edited here")
+	if err := ioutil.WriteFile(path, []byte(CHANGED_STRING_TABLE), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := watcher.reloadCategory(CATEGORY_STRINGS)
+	expected := &InputFileChanges{
+		Category:  CATEGORY_STRINGS,
+		Added:     []string{"TRIGSTR_005"},
+		Changed:   []string{"TRIGSTR_002"},
+		Removed:   []string{"TRIGSTR_003"},
+		Conflicts: []string{"TRIGSTR_004"},
+	}
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("got changes %+v, expected %+v", changes, expected)
+	}
+
+	texts := map[string]string{"1": "Footman", "2": "Paladin", "4": "Edited here", "5": "Priest"}
+	if !reflect.DeepEqual(stringTable.texts(), texts) {
+		t.Errorf("got strings %v, expected %v", stringTable.texts(), texts)
+	}
+
+	if strings.Contains(string(stringTable.bytes()), "STRING 3") {
+		t.Errorf("the removed string is still in the table:\n%s", stringTable.bytes())
+	}
+}
+
+func TestReloadWorldEditStrings(t *testing.T) {
+	defer func(previous map[string]string) { baseWorldEditStrings = previous }(baseWorldEditStrings)
+	defer func(previous map[string]string) { worldEditStrings = previous }(worldEditStrings)
+
+	inputDirectory := t.TempDir()
+	path := filepath.Join(inputDirectory, WORLD_EDIT_STRINGS_FILENAME)
+	if err := ioutil.WriteFile(path, []byte("[WorldEditStrings]\r\nWESTRING_A=Tree\r\nWESTRING_B=Rock\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	baseWorldEditStrings = map[string]string{"WESTRING_A": "Base tree", "WESTRING_C": "Bridge"}
+	applyWorldEditStrings(nil)
+
+	watcher := &inputWatcher{directory: inputDirectory}
+	watcher.resync()
+
+	if err := ioutil.WriteFile(path, []byte("[WorldEditStrings]\r\nWESTRING_B=Boulder\r\nWESTRING_D=Gate\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := watcher.reloadCategory(CATEGORY_WORLD_EDIT_STRINGS)
+	expected := &InputFileChanges{
+		Category:  CATEGORY_WORLD_EDIT_STRINGS,
+		Added:     []string{"WESTRING_D"},
+		Changed:   []string{"WESTRING_B"},
+		Removed:   []string{"WESTRING_A"},
+		Conflicts: []string{},
+	}
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("got changes %+v, expected %+v", changes, expected)
+	}
+
+	// Strings the input folder no longer has fall back on the base data
+	strings := map[string]string{"WESTRING_A": "Base tree", "WESTRING_B": "Boulder", "WESTRING_C": "Bridge", "WESTRING_D": "Gate"}
+	if !reflect.DeepEqual(worldEditStrings, strings) {
+		t.Errorf("got strings %v, expected %v", worldEditStrings, strings)
+	}
+}
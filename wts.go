@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/volatiletech/null.v6"
+)
+
+const (
+	CATEGORY_STRINGS        = "strings"
+	STRING_TABLE_FILENAME   = "war3map.wts"
+	STRING_REFERENCE_PREFIX = "TRIGSTR_"
+	STRING_LINE_BREAK       = "|n"
+)
+
+var (
+	// The string table of the map in the input folder, nil when there isn't one
+	stringTable *wtsDocument
+
+	// Fields that held a TRIGSTR reference are shown with the text of the string instead, the reference is put
+	// back whenever the objects are written and edits to the text go into the string table
+	stringReferences = make(map[stringReference]*resolvedString)
+
+	stringReferencePattern = regexp.MustCompile(`(?i)^"?TRIGSTR_(\d+)"?$`)
+)
+
+/**
+*    PRIVATE STRUCTURES
+ */
+// wtsDocument keeps every line of a string table so that strings can be edited without touching the comments,
+// blank lines or strings around them
+type wtsDocument struct {
+	*txtDocument
+
+	// Where every string is, rebuilt after the strings have been edited
+	cache map[int]wtsEntry
+}
+
+// wtsEntry is a string of the table, its text is made up of the lines between the braces
+type wtsEntry struct {
+	header int
+	start  int
+	end    int
+}
+
+type stringReference struct {
+	Category string
+	Id       string
+	Field    string
+}
+
+type resolvedString struct {
+	Object interface{}
+	Raw    string
+	Text   string
+}
+
+func parseWtsDocument(data []byte) *wtsDocument {
+	return &wtsDocument{txtDocument: parseTxtDocument(data)}
+}
+
+// entries finds every string of the table, a string starts with a STRING line followed by its text in braces
+// and comments may come in between
+func (document *wtsDocument) entries() map[int]wtsEntry {
+	if document.cache != nil {
+		return document.cache
+	}
+
+	entries := make(map[int]wtsEntry)
+	for i := 0; i < len(document.lines); i++ {
+		fields := strings.Fields(strings.TrimPrefix(document.lines[i], "\ufeff"))
+		if len(fields) != 2 || fields[0] != "STRING" {
+			continue
+		}
+
+		id, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+
+		open := i + 1
+		for open < len(document.lines) && strings.TrimSpace(document.lines[open]) != "{" {
+			if strings.HasPrefix(strings.TrimSpace(document.lines[open]), "STRING ") {
+				break
+			}
+
+			open++
+		}
+
+		if open >= len(document.lines) || strings.TrimSpace(document.lines[open]) != "{" {
+			continue
+		}
+
+		end := open + 1
+		for end < len(document.lines) && strings.TrimSpace(document.lines[end]) != "}" {
+			end++
+		}
+
+		if end >= len(document.lines) {
+			break
+		}
+
+		entries[id] = wtsEntry{i, open + 1, end}
+		i = end
+	}
+
+	document.cache = entries
+
+	return entries
+}
+
+// text returns a string with its line breaks written the way TXT files write them
+func (document *wtsDocument) text(id int) (string, bool) {
+	entry, ok := document.entries()[id]
+	if !ok {
+		return "", false
+	}
+
+	return strings.Join(document.lines[entry.start:entry.end], STRING_LINE_BREAK), true
+}
+
+func (document *wtsDocument) setText(id int, text string) bool {
+	entry, ok := document.entries()[id]
+	if !ok {
+		return false
+	}
+
+	current := strings.Join(document.lines[entry.start:entry.end], STRING_LINE_BREAK)
+	if current == text {
+		return true
+	}
+
+	document.cache = nil
+	document.removeLines(entry.start, entry.end)
+	if text != "" {
+		document.insertLines(entry.start, strings.Split(text, STRING_LINE_BREAK)...)
+	}
+
+	return true
+}
+
+// remove takes a string out of the table along with the blank lines after it
+func (document *wtsDocument) remove(id int) bool {
+	entry, ok := document.entries()[id]
+	if !ok {
+		return false
+	}
+
+	end := entry.end + 1
+	for end < len(document.lines) && strings.TrimSpace(document.lines[end]) == "" {
+		end++
+	}
+
+	document.cache = nil
+	document.removeLines(entry.header, end)
+
+	return true
+}
+
+// texts returns the text of every string by its id
+func (document *wtsDocument) texts() map[string]string {
+	texts := make(map[string]string)
+	for id := range document.entries() {
+		texts[strconv.Itoa(id)], _ = document.text(id)
+	}
+
+	return texts
+}
+
+// add appends a string to the table and returns its id, which is one higher than the highest one in use
+func (document *wtsDocument) add(text string) int {
+	id := 0
+	for existing := range document.entries() {
+		if existing >= id {
+			id = existing + 1
+		}
+	}
+
+	document.insert(id, text)
+
+	return id
+}
+
+// insert appends a string with an id that isn't in use yet
+func (document *wtsDocument) insert(id int, text string) {
+	lines := []string{"STRING " + strconv.Itoa(id), "{"}
+	if text != "" {
+		lines = append(lines, strings.Split(text, STRING_LINE_BREAK)...)
+	}
+
+	lines = append(lines, "}", "")
+	if last := len(document.lines) - 1; last >= 0 && strings.TrimSpace(document.lines[last]) != "" {
+		lines = append([]string{""}, lines...)
+	}
+
+	document.cache = nil
+	document.insertLines(len(document.lines), lines...)
+}
+
+func stringReferenceId(value string) (int, bool) {
+	match := stringReferencePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(match[1])
+
+	return id, err == nil
+}
+
+// loadStringTable reads the string table of the input folder, the references are resolved once the objects
+// have been loaded
+func loadStringTable(inputDirectory string, fileInfo *FileInfo) {
+	stringTable = nil
+	stringReferences = make(map[stringReference]*resolvedString)
+
+	if fileBytes := readInputFile(inputDirectory, fileInfo); fileBytes != nil {
+		log.Printf("Parsing %s...\n", fileInfo.FileName)
+		stringTable = parseWtsDocument(fileBytes)
+		fileInfo.StatusClass = "text-success"
+		fileInfo.StatusIconClass = "fa-check"
+	}
+}
+
+// resolveStringReferences replaces the TRIGSTR references of units, items and abilities with the text they refer
+// to, references to strings that aren't in the table are left the way they are
+func resolveStringReferences() {
+	if stringTable == nil {
+		return
+	}
+
+	for _, category := range spreadsheetCategories {
+		objects := reflect.ValueOf(category.Objects())
+		fields := category.allFields()
+		for _, key := range objects.MapKeys() {
+			object := objects.MapIndex(key)
+			for _, field := range fields {
+				value, ok := objectField(object.Elem(), field.Index)
+				if !ok {
+					continue
+				}
+
+				nullString := value.Interface().(null.String)
+				if !nullString.Valid {
+					continue
+				}
+
+				id, ok := stringReferenceId(nullString.String)
+				if !ok {
+					continue
+				}
+
+				text, ok := stringTable.text(id)
+				if !ok {
+					continue
+				}
+
+				stringReferences[stringReference{category.Name, key.String(), field.Name}] = &resolvedString{object.Interface(), nullString.String, text}
+				value.Set(reflect.ValueOf(null.StringFrom(text)))
+			}
+		}
+	}
+}
+
+// restoreStringReferences puts the references back in place of the text so that the objects can be written,
+// text that has been edited since it was resolved is written to the string table first. Fields that have been
+// cleared and objects that have been removed or reloaded lose their reference
+func restoreStringReferences() {
+	for reference, resolved := range stringReferences {
+		category, _ := getSpreadsheetCategory(reference.Category)
+		object := reflect.ValueOf(category.Objects()).MapIndex(reflect.ValueOf(reference.Id))
+		if !object.IsValid() || object.Interface() != resolved.Object {
+			delete(stringReferences, reference)
+			continue
+		}
+
+		field, _ := object.Elem().Type().FieldByName(reference.Field)
+		value, ok := objectField(object.Elem(), field.Index)
+		if !ok {
+			delete(stringReferences, reference)
+			continue
+		}
+
+		nullString := value.Interface().(null.String)
+		if !nullString.Valid {
+			delete(stringReferences, reference)
+			continue
+		}
+
+		if nullString.String != resolved.Text {
+			id, _ := stringReferenceId(resolved.Raw)
+			stringTable.setText(id, nullString.String)
+			resolved.Text = nullString.String
+		}
+
+		value.Set(reflect.ValueOf(null.StringFrom(resolved.Raw)))
+	}
+}
+
+// createStringReference moves the text of a field into a new string of the table, the table is created if the
+// input folder didn't have one
+func createStringReference(categoryName string, id string, fieldName string) error {
+	category, ok := getSpreadsheetCategory(categoryName)
+	if !ok {
+		return fmt.Errorf("%s can't be stored in the string table", categoryName)
+	}
+
+	object := reflect.ValueOf(category.Objects()).MapIndex(reflect.ValueOf(id))
+	if !object.IsValid() {
+		return fmt.Errorf("%s does not exist", id)
+	}
+
+	field, ok := object.Elem().Type().FieldByName(fieldName)
+	if !ok || field.Type != nullStringType {
+		return fmt.Errorf("%s can't be stored in the string table", fieldName)
+	}
+
+	value, ok := objectField(object.Elem(), field.Index)
+	if !ok {
+		return fmt.Errorf("%s does not have a %s", id, fieldName)
+	}
+
+	nullString := value.Interface().(null.String)
+	if !nullString.Valid {
+		return nil
+	}
+
+	if stringTable == nil {
+		stringTable = &wtsDocument{txtDocument: &txtDocument{}}
+	}
+
+	raw := fmt.Sprintf("%s%03d", STRING_REFERENCE_PREFIX, stringTable.add(nullString.String))
+	stringReferences[stringReference{category.Name, id, fieldName}] = &resolvedString{object.Interface(), raw, nullString.String}
+
+	return nil
+}
+
+// saveStringTable writes the string table next to the objects
+func saveStringTable(location string) error {
+	if stringTable == nil {
+		return nil
+	}
+
+	return writeChangedFile(filepath.Join(location, STRING_TABLE_FILENAME), stringTable.bytes())
+}
+
+// writeChangedFile leaves a file alone when it already has the data
+func writeChangedFile(path string, data []byte) error {
+	if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+
+	return writeFileAtomically(path, data)
+}
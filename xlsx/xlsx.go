@@ -0,0 +1,325 @@
+// Package xlsx reads and writes the plain cell values of Office Open XML workbooks.
+//
+// A workbook is a zip archive, xl/workbook.xml lists the sheets and xl/_rels/workbook.xml.rels tells which file
+// holds each of them. Text is either kept in xl/sharedStrings.xml and referenced by its index or written inline
+// in the cell, numbers are written as they are. Formatting, formulas and everything else is ignored
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Sheet is a named grid of cell values, rows may have different lengths
+type Sheet struct {
+	Name string
+	Rows [][]string
+}
+
+type workbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		Id   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationships struct {
+	Relationships []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type text struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t text) String() string {
+	var builder strings.Builder
+	builder.WriteString(t.Text)
+	for _, run := range t.Runs {
+		builder.WriteString(run.Text)
+	}
+
+	return builder.String()
+}
+
+type sharedStrings struct {
+	Items []text `xml:"si"`
+}
+
+type worksheet struct {
+	Rows []struct {
+		Index int `xml:"r,attr"`
+		Cells []struct {
+			Reference string `xml:"r,attr"`
+			Type      string `xml:"t,attr"`
+			Value     string `xml:"v"`
+			Inline    text   `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// Read reads every sheet of a workbook in the order the workbook lists them
+func Read(data []byte) ([]*Sheet, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[strings.TrimPrefix(file.Name, "/")] = file
+	}
+
+	var book workbook
+	if err = readXml(files, "xl/workbook.xml", &book); err != nil {
+		return nil, err
+	}
+
+	var rels relationships
+	if err = readXml(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]string)
+	for _, rel := range rels.Relationships {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.Id] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.Id] = path.Join("xl", rel.Target)
+		}
+	}
+
+	var shared sharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err = readXml(files, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	var sheets []*Sheet
+	for _, bookSheet := range book.Sheets {
+		target, ok := targets[bookSheet.Id]
+		if !ok {
+			return nil, fmt.Errorf("sheet %s has no file", bookSheet.Name)
+		}
+
+		var ws worksheet
+		if err = readXml(files, target, &ws); err != nil {
+			return nil, err
+		}
+
+		sheet := &Sheet{Name: bookSheet.Name}
+		for i, row := range ws.Rows {
+			y := row.Index - 1
+			if row.Index < 1 {
+				y = i
+			}
+
+			for len(sheet.Rows) <= y {
+				sheet.Rows = append(sheet.Rows, nil)
+			}
+
+			for x, cell := range row.Cells {
+				if cell.Reference != "" {
+					if x, err = column(cell.Reference); err != nil {
+						return nil, err
+					}
+				}
+
+				var value string
+				switch cell.Type {
+				case "s":
+					index, err := strconv.Atoi(cell.Value)
+					if err != nil || index < 0 || index >= len(shared.Items) {
+						return nil, fmt.Errorf("cell %s refers to a shared string that doesn't exist", cell.Reference)
+					}
+
+					value = shared.Items[index].String()
+				case "inlineStr":
+					value = cell.Inline.String()
+				case "b":
+					value = "FALSE"
+					if cell.Value == "1" {
+						value = "TRUE"
+					}
+				default:
+					value = cell.Value
+				}
+
+				for len(sheet.Rows[y]) <= x {
+					sheet.Rows[y] = append(sheet.Rows[y], "")
+				}
+
+				sheet.Rows[y][x] = value
+			}
+		}
+
+		sheets = append(sheets, sheet)
+	}
+
+	return sheets, nil
+}
+
+func readXml(files map[string]*zip.File, name string, v interface{}) error {
+	file, ok := files[name]
+	if !ok {
+		return fmt.Errorf("the workbook has no %s", name)
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	return xml.Unmarshal(data, v)
+}
+
+// column returns the zero based column of a cell reference such as AB12
+func column(reference string) (int, error) {
+	x := 0
+	letters := 0
+	for _, c := range strings.ToUpper(reference) {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+
+		x = x*26 + int(c-'A'+1)
+		letters++
+	}
+
+	if letters < 1 {
+		return 0, fmt.Errorf("invalid cell reference %q", reference)
+	}
+
+	return x - 1, nil
+}
+
+// ColumnName returns the letters of a zero based column
+func ColumnName(x int) string {
+	name := ""
+	for x++; x > 0; x = (x - 1) / 26 {
+		name = string(rune('A'+(x-1)%26)) + name
+	}
+
+	return name
+}
+
+// Write writes the sheets as a workbook. Values that are numbers, written the way a number would be written,
+// become numeric cells so that they can be calculated with and everything else is written as text
+func Write(w io.Writer, sheets []*Sheet) error {
+	archive := zip.NewWriter(w)
+
+	var contentTypes, workbookSheets, workbookRels bytes.Buffer
+	for i, sheet := range sheets {
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&workbookSheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(sheet.Name), i+1, i+1)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			contentTypes.String() + `</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + workbookSheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			workbookRels.String() + `</Relationships>`},
+	}
+
+	for _, part := range parts {
+		writer, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+
+		if _, err = io.WriteString(writer, part.content); err != nil {
+			return err
+		}
+	}
+
+	for i, sheet := range sheets {
+		writer, err := archive.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+
+		if err = writeSheet(writer, sheet); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func writeSheet(w io.Writer, sheet *Sheet) error {
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header)
+	buffer.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for y, row := range sheet.Rows {
+		fmt.Fprintf(&buffer, `<row r="%d">`, y+1)
+		for x, value := range row {
+			if value == "" {
+				continue
+			}
+
+			reference := ColumnName(x) + strconv.Itoa(y+1)
+			if isNumber(value) {
+				fmt.Fprintf(&buffer, `<c r="%s"><v>%s</v></c>`, reference, value)
+			} else {
+				fmt.Fprintf(&buffer, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, reference, escape(value))
+			}
+		}
+
+		buffer.WriteString(`</row>`)
+	}
+
+	buffer.WriteString(`</sheetData></worksheet>`)
+
+	_, err := buffer.WriteTo(w)
+
+	return err
+}
+
+// isNumber tells whether a value would be written the same way after being read as a number, 007 or 1.50
+// are kept as text so that they come back the way they were
+func isNumber(value string) bool {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+
+	return strconv.FormatFloat(number, 'f', -1, 64) == value
+}
+
+func escape(value string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(value))
+
+	return buffer.String()
+}